@@ -57,6 +57,7 @@ func exit(err error) {
 
 // Close will gracefully stop the database connection, and log file
 func Close() {
+	services.StopServices()
 	utils.CloseLogs()
 	confgs.Close()
 	fmt.Println("Shutting down Statping")
@@ -116,7 +116,9 @@ func (it *Db) ChunkSize() int {
 	case "postgres":
 		return 3000
 	default:
-		return 100
+		// sqlite is limited to 999 variables per statement, a batch of 100 failures has more than 999 since
+		// the category column was added as every column of each failure is a variable
+		return 50
 	}
 }
 
@@ -52,6 +52,18 @@ class Api {
     return axios.post('api/services/' + data.id, data).then(response => (response.data))
   }
 
+  async service_check(id) {
+    return axios.post('api/services/' + id + '/check').then(response => (response.data))
+  }
+
+  async services_check_all() {
+    return axios.post('api/services/check').then(response => (response.data))
+  }
+
+  async checker() {
+    return axios.get('api/checker').then(response => (response.data))
+  }
+
   async service_hits(id, start, end, group, fill = true) {
     return axios.get('api/services/' + id + '/hits_data?start=' + start + '&end=' + end + '&group=' + group + '&fill=' + fill).then(response => (response.data))
   }
@@ -76,6 +88,10 @@ class Api {
     return axios.get('api/services/' + id + '/failures?start=' + start + '&end=' + end + '&limit=' + limit + '&offset=' + offset).then(response => (response.data))
   }
 
+  async service_responses(id) {
+    return axios.get('api/services/' + id + '/responses').then(response => (response.data))
+  }
+
   async service_failures_delete(service) {
     return axios.delete('api/services/' + service.id + '/failures').then(response => (response.data))
   }
@@ -187,6 +187,13 @@ func TestMainApiRoutes(t *testing.T) {
 			ExpectedStatus:   200,
 			ExpectedContains: []string{`"online":true`, `"setup":true`},
 		},
+		{
+			Name:             "Checker Stats endpoint",
+			URL:              "/api/checker",
+			Method:           "GET",
+			ExpectedStatus:   200,
+			ExpectedContains: []string{`"routines":`, `"running":`, `"queued":`, `"average_duration":`},
+		},
 		{
 			Name:             "Logs endpoint",
 			URL:              "/api/logs",
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/statping/statping/types/services"
+	"github.com/statping/statping/utils"
+)
+
+const (
+	// eventsBuffer is the amount of events kept for a client before events are dropped
+	eventsBuffer = 64
+	// eventsPing is how often a comment is sent to keep idle connections open
+	eventsPing = 15 * time.Second
+	// eventsRetry is the delay in milliseconds for EventSource clients to reconnect
+	eventsRetry = 1000
+)
+
+// apiEventsHandler will stream the results of checks as server-sent events, optionally for a single
+// service with the 'service' query. Private services are only sent to authenticated users. The stream
+// is closed before the server's write timeout and EventSource clients will reconnect.
+func apiEventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		sendErrorJson(errors.New("streaming events is not supported"), w, r)
+		return
+	}
+	serviceId := utils.ToInt(r.URL.Query().Get("service"))
+	authenticated := IsReadAuthenticated(r)
+
+	events, unsubscribe := services.SubscribeEvents(eventsBuffer)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprintf(w, "retry: %d\n\n", eventsRetry)
+	flusher.Flush()
+
+	end := time.NewTimer(timeout - 5*time.Second)
+	defer end.Stop()
+	ping := time.NewTicker(eventsPing)
+	defer ping.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-end.C:
+			return
+		case <-ping.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case event := <-events:
+			if serviceId != 0 && event.Service != serviceId {
+				continue
+			}
+			service, err := services.Find(event.Service)
+			if err != nil || (!service.Public.Bool && !authenticated) {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Errorln(err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: check\ndata: %s\n\n", data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
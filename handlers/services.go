@@ -114,6 +114,29 @@ func apiServicePatchHandler(w http.ResponseWriter, r *http.Request) {
 	sendJsonAction(service, "update", w, r)
 }
 
+func apiCheckAllServicesHandler(w http.ResponseWriter, r *http.Request) {
+	output := apiResponse{
+		Status: "success",
+		Method: "check",
+		Output: map[string]int{"services": services.CheckAllNow()},
+	}
+	returnJson(output, w, r)
+}
+
+func apiCheckerHandler(w http.ResponseWriter, r *http.Request) {
+	returnJson(services.Checker(), w, r)
+}
+
+func apiServiceCheckHandler(w http.ResponseWriter, r *http.Request) {
+	service, err := findService(r)
+	if err != nil {
+		sendErrorJson(err, w, r)
+		return
+	}
+	service.ForceCheck()
+	sendJsonAction(service, "check", w, r)
+}
+
 func apiServiceUpdateHandler(w http.ResponseWriter, r *http.Request) {
 	service, err := findService(r)
 	if err != nil {
@@ -128,7 +151,7 @@ func apiServiceUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		sendErrorJson(err, w, r)
 		return
 	}
-	go service.CheckService(true)
+	go service.ForceCheck()
 	sendJsonAction(service, "update", w, r)
 }
 
@@ -296,7 +319,11 @@ func apiServiceFailuresHandler(r *http.Request) interface{} {
 		return err
 	}
 	var fails []*failures.Failure
-	query, err := database.ParseQueries(r, service.AllFailures())
+	allFailures := service.AllFailures()
+	if category := r.URL.Query().Get("category"); category != "" {
+		allFailures = allFailures.Category(category)
+	}
+	query, err := database.ParseQueries(r, allFailures)
 	if err != nil {
 		return err
 	}
@@ -304,6 +331,14 @@ func apiServiceFailuresHandler(r *http.Request) interface{} {
 	return fails
 }
 
+func apiServiceResponsesHandler(r *http.Request) interface{} {
+	service, err := findService(r)
+	if err != nil {
+		return err
+	}
+	return service.Responses()
+}
+
 func apiServiceHitsHandler(r *http.Request) interface{} {
 	service, err := findService(r)
 	if err != nil {
@@ -38,6 +38,27 @@ func TestUnAuthenticatedServicesRoutes(t *testing.T) {
 			ExpectedStatus: 401,
 			BeforeTest:     UnsetTestENV,
 		},
+		{
+			Name:           "No Authentication - Check Service",
+			URL:            "/api/services/1/check",
+			Method:         "POST",
+			ExpectedStatus: 401,
+			BeforeTest:     UnsetTestENV,
+		},
+		{
+			Name:           "No Authentication - Check All Services",
+			URL:            "/api/services/check",
+			Method:         "POST",
+			ExpectedStatus: 401,
+			BeforeTest:     UnsetTestENV,
+		},
+		{
+			Name:           "No Authentication - Checker Stats",
+			URL:            "/api/checker",
+			Method:         "GET",
+			ExpectedStatus: 401,
+			BeforeTest:     UnsetTestENV,
+		},
 	}
 
 	for _, v := range tests {
@@ -154,6 +175,12 @@ func TestApiServiceRoutes(t *testing.T) {
 			GreaterThan:    8580,
 			ExpectedStatus: 200,
 		},
+		{
+			Name:           "Statping Service 1 Responses",
+			URL:            "/api/services/1/responses",
+			Method:         "GET",
+			ExpectedStatus: 200,
+		},
 		{
 			Name:           "Statping Service Failures Limited",
 			URL:            "/api/services/1/failures?limit=1",
@@ -17,6 +17,10 @@ func TestReplaceTemplate(t *testing.T) {
 	temp = `{"id":{{.Service.Id}},"name":"{{.Service.Name}}","failure":"{{.Failure.Issue}}"}`
 	replaced = ReplaceTemplate(temp, replacer{Service: services.Example(false), Failure: failures.Example()})
 	assert.Equal(t, `{"id":6283,"name":"Statping Example","failure":"Response did not response a 200 status code"}`, replaced)
+
+	temp = `{"team":"{{.Service.Labels.team}}","region":"{{index .Service.Labels "region"}}"}`
+	replaced = ReplaceTemplate(temp, replacer{Service: services.Example(false), Failure: failures.Example()})
+	assert.Equal(t, `{"team":"statping","region":"us-west"}`, replaced)
 }
 
 func TestPushover_Select(t *testing.T) {
@@ -2,7 +2,9 @@ package notifiers
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -15,6 +17,9 @@ import (
 )
 
 var _ notifier.Notifier = (*slack)(nil)
+var _ notifier.Recoverer = (*slack)(nil)
+var _ notifier.Flapper = (*slack)(nil)
+var _ notifier.StatusChanger = (*slack)(nil)
 
 const (
 	slackMethod = "slack"
@@ -88,6 +93,41 @@ func (s *slack) OnSuccess(srv services.Service) (string, error) {
 	return out, err
 }
 
+// OnRecover will send a single message with the downtime when the service is back online
+func (s *slack) OnRecover(srv services.Service, downtime time.Duration) (string, error) {
+	msg, err := json.Marshal(map[string]string{
+		"text": fmt.Sprintf("The service %s is back online after %s.", srv.Name, utils.DurationReadable(downtime)),
+	})
+	if err != nil {
+		return "", err
+	}
+	return s.sendSlack(string(msg))
+}
+
+// OnFlapping will send a single message when the service starts flapping
+func (s *slack) OnFlapping(srv services.Service, changes int) (string, error) {
+	msg, err := json.Marshal(map[string]string{
+		"text": fmt.Sprintf("The service %s is flapping, it changed state %d times recently. Notifications are paused until it is stable.", srv.Name, changes),
+	})
+	if err != nil {
+		return "", err
+	}
+	return s.sendSlack(string(msg))
+}
+
+// OnStatusChange will send a message when the service changes between up, degraded and down
+func (s *slack) OnStatusChange(srv services.Service, previous, status string) (string, error) {
+	text := fmt.Sprintf("The service %s changed from %s to %s.", srv.Name, previous, status)
+	if srv.DegradedReason != "" {
+		text += " " + srv.DegradedReason
+	}
+	msg, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return "", err
+	}
+	return s.sendSlack(string(msg))
+}
+
 // OnSave will trigger when this notifier is saved
 func (s *slack) OnSave() (string, error) {
 	return "", nil
@@ -67,4 +67,19 @@ func TestSlackNotifier(t *testing.T) {
 		assert.Nil(t, err)
 	})
 
+	t.Run("slack OnRecover", func(t *testing.T) {
+		_, err := slacker.OnRecover(services.Example(true), 4*time.Minute)
+		assert.Nil(t, err)
+	})
+
+	t.Run("slack OnFlapping", func(t *testing.T) {
+		_, err := slacker.OnFlapping(services.Example(true), 6)
+		assert.Nil(t, err)
+	})
+
+	t.Run("slack OnStatusChange", func(t *testing.T) {
+		_, err := slacker.OnStatusChange(services.Example(true), services.StatusUp, services.StatusDegraded)
+		assert.Nil(t, err)
+	})
+
 }
@@ -20,3 +20,14 @@ func TestInit(t *testing.T) {
 		require.Nil(t, Samples())
 	})
 }
+
+func TestRender(t *testing.T) {
+	f := &Failure{Issue: "HTTP Status Code 500 did not match 200", Reason: "status_code", Expected: "200", Actual: "500"}
+	require.Equal(t, "HTTP Status Code 500 did not match 200", f.Render())
+
+	f.Issue = ""
+	require.Equal(t, "status_code: expected '200', got '500'", f.Render())
+
+	f = &Failure{Reason: "lookup", Category: CategoryDns, Error: "no such host"}
+	require.Equal(t, "lookup, no such host", f.Render())
+}
@@ -48,6 +48,11 @@ func (f Failurer) Since(t time.Time) []*Failure {
 	return fails
 }
 
+// Category will only return failures of the category
+func (f Failurer) Category(category string) Failurer {
+	return Failurer{f.db.Where("category = ?", category)}
+}
+
 func (f Failurer) Count() int {
 	var amount int
 	f.db.Count(&amount)
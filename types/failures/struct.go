@@ -1,20 +1,60 @@
 package failures
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // Failure is a failed attempt to check a service. Any a service does not meet the expected requirements,
 // a new Failure will be inserted into Db.
 type Failure struct {
-	Id        int64     `gorm:"primary_key;column:id" json:"id"`
-	Issue     string    `gorm:"column:issue" json:"issue"`
-	Method    string    `gorm:"column:method" json:"method,omitempty"`
-	MethodId  int64     `gorm:"column:method_id" json:"method_id,omitempty"`
-	ErrorCode int       `gorm:"column:error_code" json:"error_code"`
-	Service   int64     `gorm:"index;column:service" json:"-"`
-	Checkin   int64     `gorm:"index;column:checkin" json:"-"`
-	PingTime  int64     `gorm:"column:ping_time"  json:"ping"`
-	Reason    string    `gorm:"column:reason" json:"reason,omitempty"`
-	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+	Id         int64     `gorm:"primary_key;column:id" json:"id"`
+	Issue      string    `gorm:"column:issue" json:"issue"`
+	Method     string    `gorm:"column:method" json:"method,omitempty"`
+	MethodId   int64     `gorm:"column:method_id" json:"method_id,omitempty"`
+	ErrorCode  int       `gorm:"column:error_code" json:"error_code"`
+	Service    int64     `gorm:"index;column:service" json:"-"`
+	Checkin    int64     `gorm:"index;column:checkin" json:"-"`
+	PingTime   int64     `gorm:"column:ping_time"  json:"ping"`
+	Reason     string    `gorm:"column:reason" json:"reason,omitempty"`
+	Category   string    `gorm:"column:category" json:"category,omitempty"`
+	Expected   string    `gorm:"column:expected" json:"expected,omitempty"`
+	Actual     string    `gorm:"column:actual" json:"actual,omitempty"`
+	Error      string    `gorm:"column:error" json:"error,omitempty"`
+	Status     string    `gorm:"column:status" json:"status,omitempty"`
+	PacketLoss float64   `gorm:"column:packet_loss" json:"packet_loss,omitempty"`
+	CreatedAt  time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+// Categories of a Failure, used to filter failures by the type of issue
+const (
+	CategoryDns     = "dns"
+	CategoryConnect = "connect"
+	CategoryTls     = "tls"
+	CategoryStatus  = "status"
+	CategoryBody    = "body"
+	CategoryTimeout = "timeout"
+	CategoryLatency = "latency"
+	CategoryTrigger = "trigger"
+	CategoryOther   = "other"
+)
+
+// Render returns the Issue of the Failure, or a message built from the structured fields if it has no Issue
+func (f *Failure) Render() string {
+	if f.Issue != "" {
+		return f.Issue
+	}
+	msg := f.Reason
+	if msg == "" {
+		msg = f.Category
+	}
+	if f.Expected != "" || f.Actual != "" {
+		msg = fmt.Sprintf("%v: expected '%v', got '%v'", msg, f.Expected, f.Actual)
+	}
+	if f.Error != "" {
+		msg = fmt.Sprintf("%v, %v", msg, f.Error)
+	}
+	return msg
 }
 
 type FailSort []Failure
@@ -4,11 +4,21 @@ import "time"
 
 // Hit struct is a 'successful' ping or web response entry for a service.
 type Hit struct {
-	Id        int64     `gorm:"primary_key;column:id" json:"id"`
-	Service   int64     `gorm:"index;column:service" json:"-"`
-	Latency   int64     `gorm:"column:latency" json:"latency"`
-	PingTime  int64     `gorm:"column:ping_time" json:"ping_time"`
-	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+	Id         int64     `gorm:"primary_key;column:id" json:"id"`
+	Service    int64     `gorm:"index;column:service" json:"-"`
+	Latency    int64     `gorm:"column:latency" json:"latency"`
+	PingTime   int64     `gorm:"column:ping_time" json:"ping_time"`
+	PacketLoss float64   `gorm:"column:packet_loss" json:"packet_loss,omitempty"`
+	DNS        int64     `gorm:"column:dns_latency" json:"dns_latency,omitempty"`
+	Connect    int64     `gorm:"column:connect_latency" json:"connect_latency,omitempty"`
+	TLS        int64     `gorm:"column:tls_latency" json:"tls_latency,omitempty"`
+	FirstByte  int64     `gorm:"column:first_byte_latency" json:"first_byte_latency,omitempty"`
+	Size       int64     `gorm:"column:response_size" json:"response_size,omitempty"`
+	Throughput float64   `gorm:"column:throughput" json:"throughput,omitempty"`
+	Protocol   string    `gorm:"column:protocol" json:"protocol,omitempty"`
+	RemoteIP   string    `gorm:"column:remote_ip" json:"remote_ip,omitempty"`
+	Status     string    `gorm:"column:status" json:"status,omitempty"`
+	CreatedAt  time.Time `gorm:"column:created_at" json:"created_at"`
 }
 
 // BeforeCreate for Hit will set CreatedAt to UTC
@@ -0,0 +1,56 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	// go routines checking a service
+	checkerRoutines = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "statping",
+			Name:      "checker_routines",
+			Help:      "Amount of go routines checking a service",
+		},
+	)
+
+	// checks that are currently running
+	checkerRunning = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "statping",
+			Name:      "checker_running",
+			Help:      "Amount of checks that are currently running",
+		},
+	)
+
+	// checks that are waiting for a slot of the worker pool
+	checkerQueued = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "statping",
+			Name:      "checker_queued",
+			Help:      "Amount of checks waiting for a slot of MAX_CONCURRENT_CHECKS",
+		},
+	)
+
+	// duration of every check
+	checkerDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: "statping",
+			Name:      "checker_duration_seconds",
+			Help:      "Duration of the checks of every service",
+		},
+	)
+)
+
+func CheckerGauge(method string, value float64) {
+	switch method {
+	case "routines":
+		checkerRoutines.Set(value)
+	case "running":
+		checkerRunning.Set(value)
+	case "queued":
+		checkerQueued.Set(value)
+	}
+}
+
+func CheckerTimer() prometheus.Observer {
+	return checkerDuration
+}
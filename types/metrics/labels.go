@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// serviceInfo exports the labels of every service as the statping_service_info metric, the labels
+// of each service are different so the metric is created when it is collected
+type serviceInfo struct {
+	mu       sync.RWMutex
+	services map[int64]serviceLabels
+}
+
+type serviceLabels struct {
+	name   string
+	labels map[string]string
+}
+
+var serviceInfoLabels = &serviceInfo{services: make(map[int64]serviceLabels)}
+
+// SetServiceLabels will export the labels of the service, every series has the id and name of the service
+// to join it with the other service metrics
+func SetServiceLabels(id int64, name string, labels map[string]string) {
+	copied := make(map[string]string, len(labels))
+	for k, v := range labels {
+		copied[k] = v
+	}
+	serviceInfoLabels.mu.Lock()
+	defer serviceInfoLabels.mu.Unlock()
+	serviceInfoLabels.services[id] = serviceLabels{name: name, labels: copied}
+}
+
+// DeleteServiceLabels will stop exporting the labels of the service
+func DeleteServiceLabels(id int64) {
+	serviceInfoLabels.mu.Lock()
+	defer serviceInfoLabels.mu.Unlock()
+	delete(serviceInfoLabels.services, id)
+}
+
+// Describe sends no descriptions, the collector is unchecked because the label names depend on the services
+func (c *serviceInfo) Describe(chan<- *prometheus.Desc) {}
+
+// Collect will send a statping_service_info series with the labels of each service
+func (c *serviceInfo) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for id, service := range c.services {
+		keys := make([]string, 0, len(service.labels))
+		for k := range service.labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		names := append([]string{"id", "name"}, keys...)
+		values := []string{strconv.FormatInt(id, 10), service.name}
+		for _, k := range keys {
+			values = append(values, service.labels[k])
+		}
+		desc := prometheus.NewDesc("statping_service_info", "Labels of a service, set to 1", names, nil)
+		metric, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, 1, values...)
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(desc, err)
+			continue
+		}
+		ch <- metric
+	}
+}
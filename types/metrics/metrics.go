@@ -39,11 +39,19 @@ func InitMetrics() {
 		serviceSuccess,
 		serviceStatusCode,
 		serviceDuration,
+		serviceUp,
+		serviceLatency,
+		serviceFailuresTotal,
 		utilsHttpRequestDur,
 		utilsHttpRequestBytes,
 		httpDuration,
 		databaseStats,
 		queryStats,
+		serviceInfoLabels,
+		checkerRoutines,
+		checkerRunning,
+		checkerQueued,
+		checkerDuration,
 	)
 }
 
@@ -70,6 +78,10 @@ func Gauge(method string, value float64, labels ...interface{}) {
 		serviceStatusCode.WithLabelValues(convert(labels)...).Set(value)
 	case "online":
 		serviceOnline.WithLabelValues(convert(labels)...).Set(value)
+	case "up":
+		serviceUp.WithLabelValues(convert(labels)...).Set(value)
+	case "latency":
+		serviceLatency.WithLabelValues(convert(labels)...).Set(value)
 	}
 }
 
@@ -79,6 +91,8 @@ func Inc(method string, labels ...interface{}) {
 		serviceFailures.WithLabelValues(convert(labels)...).Inc()
 	case "success":
 		serviceSuccess.WithLabelValues(convert(labels)...).Inc()
+	case "failures_total":
+		serviceFailuresTotal.WithLabelValues(convert(labels)...).Inc()
 	}
 }
 
@@ -1,6 +1,10 @@
 package metrics
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
 
 var (
 	// service is online if set to 1, offline if 0
@@ -52,4 +56,42 @@ var (
 		},
 		[]string{"service"},
 	)
+
+	// service is up if set to 1, down if 0
+	serviceUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "statping",
+			Name:      "service_up",
+			Help:      "If the service is up from the latest check",
+		},
+		[]string{"id", "name"},
+	)
+
+	// latency of the latest check for a service
+	serviceLatency = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "statping",
+			Name:      "service_latency_seconds",
+			Help:      "Latency of the latest check for a service",
+		},
+		[]string{"id", "name"},
+	)
+
+	// total failures recorded for a service
+	serviceFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "statping",
+			Name:      "service_failures_total",
+			Help:      "Total failures recorded for a service",
+		},
+		[]string{"id", "name"},
+	)
 )
+
+// DeleteServiceMetrics will stop exporting the metrics of the service with the id and name
+func DeleteServiceMetrics(id int64, name string) {
+	idLabel := strconv.FormatInt(id, 10)
+	serviceUp.DeleteLabelValues(idLabel, name)
+	serviceLatency.DeleteLabelValues(idLabel, name)
+	serviceFailuresTotal.DeleteLabelValues(idLabel, name)
+}
@@ -3,6 +3,7 @@ package notifier
 import (
 	"github.com/statping/statping/types/failures"
 	"github.com/statping/statping/types/services"
+	"time"
 )
 
 // Notifier interface is required to create a new Notifier
@@ -12,3 +13,18 @@ type Notifier interface {
 	OnTest() (string, error)                                      // OnTest is triggered for testing
 	OnSave() (string, error)                                      // OnSave is triggered for when saved
 }
+
+// Recoverer interface is optional for a Notifier to send a single message when a service is back online
+type Recoverer interface {
+	OnRecover(services.Service, time.Duration) (string, error) // OnRecover is triggered with the downtime when a service recovers
+}
+
+// Flapper interface is optional for a Notifier to send a single message when a service starts flapping
+type Flapper interface {
+	OnFlapping(services.Service, int) (string, error) // OnFlapping is triggered with the amount of state changes within the flap window
+}
+
+// StatusChanger interface is optional for a Notifier to send a message when a service changes between up, degraded and down
+type StatusChanger interface {
+	OnStatusChange(services.Service, string, string) (string, error) // OnStatusChange is triggered with the previous and the new status
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/statping/statping/types/metrics"
+)
+
+// builtinTypes are the service types checked by Statping, they can't be replaced by a custom checker
+var builtinTypes = map[string]bool{
+	"http":      true,
+	"tcp":       true,
+	"udp":       true,
+	"icmp":      true,
+	"grpc":      true,
+	"dns":       true,
+	"websocket": true,
+	"smtp":      true,
+	"imap":      true,
+	"pop3":      true,
+	"ntp":       true,
+	"mqtt":      true,
+	"static":    true,
+}
+
+var (
+	checkersMu     sync.RWMutex
+	customCheckers = make(map[string]func(s *Service, record bool))
+)
+
+// RegisterChecker will register a custom checker for services of the type, such as an internal protocol.
+// The checker must set the Online, Latency and LastResponse of the service, and when record is true create
+// the result with RecordSuccess or RecordFailure. A checker can't be registered for a built-in type.
+func RegisterChecker(typeName string, fn func(s *Service, record bool)) {
+	if typeName == "" || fn == nil {
+		log.Errorln("custom checker must have a type name and a check function")
+		return
+	}
+	if builtinTypes[typeName] {
+		log.Errorf("custom checker can't replace the built-in service type '%v'", typeName)
+		return
+	}
+	checkersMu.Lock()
+	defer checkersMu.Unlock()
+	customCheckers[typeName] = fn
+}
+
+// customChecker returns the custom checker registered for the service type, or nil
+func customChecker(typeName string) func(s *Service, record bool) {
+	checkersMu.RLock()
+	defer checkersMu.RUnlock()
+	return customCheckers[typeName]
+}
+
+// supportedType returns true if the service type is built-in or has a custom checker
+func supportedType(typeName string) bool {
+	return builtinTypes[typeName] || customChecker(typeName) != nil
+}
+
+// runCustomCheck will run the custom checker of the service like the built-in checks
+func (s *Service) runCustomCheck(record bool, fn func(s *Service, record bool)) {
+	defer s.updateLastCheck()
+	timer := prometheus.NewTimer(metrics.ServiceTimer(s.Name))
+	defer timer.ObserveDuration()
+	fn(s, record)
+}
@@ -6,6 +6,8 @@ import (
 	"github.com/statping/statping/types/errors"
 	"github.com/statping/statping/types/metrics"
 	"github.com/statping/statping/utils"
+	"net"
+	"net/url"
 	"sort"
 )
 
@@ -15,6 +17,7 @@ var (
 	allServices map[int64]*Service
 )
 
+// Validate returns an error if the service is misconfigured in a way it can not be checked
 func (s *Service) Validate() error {
 	if s.Name == "" {
 		return errors.New("missing service name")
@@ -25,15 +28,158 @@ func (s *Service) Validate() error {
 	} else if s.Interval == 0 && s.Type != "static" {
 		return errors.New("missing check interval")
 	}
+	if !supportedType(s.Type) {
+		return errors.New(fmt.Sprintf("service type '%v' is not supported", s.Type))
+	}
+	if s.Port < 0 || s.Port > 65535 {
+		return errors.New("port must be between 0 and 65535")
+	}
+	for _, port := range s.Ports {
+		if port < 1 || port > 65535 {
+			return errors.New(fmt.Sprintf("port %d must be between 1 and 65535", port))
+		}
+	}
+	for _, id := range s.DependsOn {
+		if id == s.Id && s.Id != 0 {
+			return errors.New("service can't depend on itself")
+		}
+	}
+	if s.IntervalDown < 0 {
+		return errors.New("offline check interval must not be negative")
+	}
+	if s.ReadLimit < 0 {
+		return errors.New("read limit must not be negative")
+	}
+	if s.MaxClockOffset < 0 {
+		return errors.New("max clock offset must not be negative")
+	}
+	if s.DegradedLatency < 0 {
+		return errors.New("degraded latency must not be negative")
+	}
+	if s.AnomalyDeviations < 0 || s.AnomalyMinSamples < 0 {
+		return errors.New("anomaly deviations and minimum samples must not be negative")
+	}
+	if s.LatencyAlpha < 0 || s.LatencyAlpha > 1 {
+		return errors.New("latency alpha must be between 0 and 1")
+	}
+	if s.Quorum < 0 || (len(s.Endpoints) > 0 && s.Quorum > len(s.Endpoints)) {
+		return errors.New("quorum must be between 0 and the amount of endpoints")
+	}
+	if _, err := matchStatusCode(s.RetryStatuses, 0); err != nil {
+		return errors.New(fmt.Sprintf("retry statuses are invalid, %v", err))
+	}
+	switch s.IntervalUnit {
+	case "", "s", "ms", "us":
+	default:
+		return errors.New("check interval unit must be 's', 'ms' or 'us'")
+	}
+	switch s.LatencyMode {
+	case "", "first", "min", "avg":
+	default:
+		return errors.New("latency mode must be 'first', 'min' or 'avg'")
+	}
+	switch s.ExpectedMatch {
+	case "", "all", "any":
+	default:
+		return errors.New("expected match must be 'all' or 'any'")
+	}
+	if _, ok := httpProtocols[s.ExpectedProtocol]; s.ExpectedProtocol != "" && !ok {
+		return errors.New("expected protocol must be '1.0', '1.1' or '2'")
+	}
+	if s.SourceIP != "" && net.ParseIP(s.SourceIP) == nil {
+		return errors.New(fmt.Sprintf("source address '%v' is not an IP address", s.SourceIP))
+	}
+	if s.PreRequestURL != "" {
+		if u, err := url.Parse(s.PreRequestURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("pre-request URL must be a http:// or https:// URL")
+		}
+	}
+	if err := s.validateExpectedSource(); err != nil {
+		return err
+	}
+	if err := s.validateExpectedRegex(); err != nil {
+		return err
+	}
+	if err := s.validateExpectedRedirect(); err != nil {
+		return err
+	}
+	if err := s.validateSteps(); err != nil {
+		return err
+	}
+	switch s.GrpcMode {
+	case "", "health", "reflection":
+	default:
+		return errors.New("grpc mode must be 'health' or 'reflection'")
+	}
+	if _, err := parseSchedule(s.ActiveSchedule); err != nil {
+		return err
+	}
+	if s.ActiveSchedule != "" {
+		if _, err := s.scheduleLocation(); err != nil {
+			return errors.New(fmt.Sprintf("schedule timezone is invalid, %v", err))
+		}
+	}
+	if err := s.validateExpectedChecksum(); err != nil {
+		return err
+	}
+	if err := s.validateExpectedSchema(); err != nil {
+		return err
+	}
+	if err := s.validateLabels(); err != nil {
+		return err
+	}
+	if s.AllResolvedIPs.Bool && s.Proxy != "" {
+		return errors.New("all resolved IP addresses can't be checked through a proxy")
+	}
+	if transport, _ := resolverAddress(s.DnsResolver.String); transport != "" && transport != "tcp" && transport != "udp" {
+		return errors.New("dns resolver transport must be 'tcp' or 'udp'")
+	}
 	return nil
 }
 
+// Warnings returns misconfigurations that do not stop the service from being checked, such as a
+// timeout that is not shorter than the interval, which makes every slow check overrun the next one
+func (s *Service) Warnings() []string {
+	var warnings []string
+	if s.Type != "static" && s.Interval > 0 && s.TimeoutDuration() >= s.Duration() {
+		warnings = append(warnings, fmt.Sprintf("timeout of %v is not shorter than the interval of %v, checks will overlap", s.TimeoutDuration(), s.Duration()))
+	}
+	if s.DegradedLatency > 0 && s.MaxLatency > 0 && s.DegradedLatency >= s.MaxLatency {
+		warnings = append(warnings, fmt.Sprintf("degraded latency of %0.2fs is not lower than the max latency of %0.2fs, the service is never degraded by latency", s.DegradedLatency, s.MaxLatency))
+	}
+	if len(s.Ports) > 0 && (s.PostData.String != "" || s.Expected.String != "") {
+		warnings = append(warnings, "the sent data and expected response are not used when checking multiple ports")
+	}
+	if s.Inverted.Bool && s.ExpectClosed.Bool {
+		warnings = append(warnings, "the service is inverted and expects a closed port, it is only online if the port is open")
+	}
+	if s.ExpectedChecksum != "" && s.ReadLimit > 0 {
+		warnings = append(warnings, fmt.Sprintf("expected checksum is compared to the first %d bytes of the response with the read limit", s.ReadLimit))
+	}
+	return warnings
+}
+
+// logWarnings will log the Warnings of the service
+func (s *Service) logWarnings() {
+	for _, warning := range s.Warnings() {
+		log.Warnf("Service %v: %v", s.Name, warning)
+	}
+}
+
 func (s *Service) BeforeCreate() error {
-	return s.Validate()
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	s.logWarnings()
+	return nil
 }
 
 func (s *Service) BeforeUpdate() error {
-	return s.Validate()
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	s.logWarnings()
+	return nil
 }
 
 func (s *Service) AfterFind() {
@@ -44,6 +190,7 @@ func (s *Service) AfterFind() {
 func (s *Service) AfterCreate() error {
 	s.prevOnline = true
 	allServices[s.Id] = s
+	s.exportLabels()
 	metrics.Query("service", "create")
 	return nil
 }
@@ -109,7 +256,9 @@ func (s *Service) Create() error {
 func (s *Service) Update() error {
 	q := db.Update(s)
 	s.Close()
+	s.loadLatencies()
 	allServices[s.Id] = s
+	s.exportLabels()
 	s.SleepDuration = s.Duration()
 	go ServiceCheckQueue(allServices[s.Id], true)
 	return q.Error()
@@ -137,6 +286,8 @@ func (s *Service) Delete() error {
 	db.Model(s).Association("Messages").Clear()
 
 	delete(allServices, s.Id)
+	metrics.DeleteServiceLabels(s.Id)
+	metrics.DeleteServiceMetrics(s.Id, s.Name)
 	q := db.Model(&Service{}).Delete(s)
 	return q.Error()
 }
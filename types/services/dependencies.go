@@ -0,0 +1,57 @@
+package services
+
+import (
+	"database/sql/driver"
+)
+
+// IdList is a list of service ids stored in the database as a JSON array
+type IdList []int64
+
+// Value will store the list as a JSON array
+func (l IdList) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return "", nil
+	}
+	return jsonValue(l)
+}
+
+// Scan will parse the list from the JSON array in the database
+func (l *IdList) Scan(value interface{}) error {
+	*l = nil
+	return scanJSON(value, l)
+}
+
+// offlineDependency returns the first service of DependsOn that is currently offline, or nil. Only the
+// dependencies are consulted, a service further upstream suppresses the alerts of the dependency itself.
+// Services in a dependency cycle are ignored: if a dependency depends back on the service, directly or
+// through other services, it is never the reason to suppress an alert, so a cycle can't silence all of them.
+func (s *Service) offlineDependency() *Service {
+	for _, id := range s.DependsOn {
+		dependency := allServices[id]
+		if dependency == nil || dependency.Id == s.Id || dependency.Status != StatusDown {
+			continue
+		}
+		if dependsOn(dependency, s.Id, map[int64]bool{}) {
+			continue
+		}
+		return dependency
+	}
+	return nil
+}
+
+// dependsOn returns true if the service depends on the id directly or through its dependencies
+func dependsOn(s *Service, id int64, visited map[int64]bool) bool {
+	if visited[s.Id] {
+		return false
+	}
+	visited[s.Id] = true
+	for _, dependencyId := range s.DependsOn {
+		if dependencyId == id {
+			return true
+		}
+		if dependency := allServices[dependencyId]; dependency != nil && dependsOn(dependency, id, visited) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/statping/statping/utils"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// dnsTypeRRSIG is the type of DNSSEC signature records, which dnsmessage has no constant for
+	dnsTypeRRSIG = dnsmessage.Type(46)
+	// dnsBitAD and dnsBitCD are the authenticated data and checking disabled header flags
+	dnsBitAD = 1 << 5
+	dnsBitCD = 1 << 4
+)
+
+var dnssecTypes = map[string]dnsmessage.Type{
+	"":      dnsmessage.TypeA,
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"MX":    dnsmessage.TypeMX,
+	"TXT":   dnsmessage.TypeTXT,
+	"NS":    dnsmessage.TypeNS,
+}
+
+// dnssecAnswer is the DNSSEC status of the response to a query
+type dnssecAnswer struct {
+	rcode         dnsmessage.RCode
+	authenticated bool
+	answers       int
+	signatures    int
+}
+
+// dnssecNameserver returns the transport and address of the nameserver to validate the answers, the DNS
+// resolver of the service or DNS_RESOLVER, or the first nameserver of /etc/resolv.conf
+func dnssecNameserver(s *Service) (string, string, error) {
+	resolver := s.DnsResolver.String
+	if resolver == "" && utils.Params != nil {
+		resolver = utils.Params.GetString("DNS_RESOLVER")
+	}
+	if resolver == "" {
+		resolver = systemNameserver("/etc/resolv.conf")
+	}
+	if resolver == "" {
+		return "", "", errors.New("no DNS resolver is set to validate DNSSEC answers")
+	}
+	transport, address := resolverAddress(resolver)
+	return transport, address, nil
+}
+
+// systemNameserver returns the first nameserver of the resolv.conf file
+func systemNameserver(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// checkDnssec will query the nameserver with the DNSSEC OK bit and return an error if the answer
+// was not authenticated, and whether the signatures were missing or invalid
+func checkDnssec(ctx context.Context, s *Service) error {
+	recordType, ok := dnssecTypes[strings.ToUpper(s.DnsRecordType)]
+	if !ok {
+		return fmt.Errorf("DNSSEC validation is not supported for %v records", strings.ToUpper(s.DnsRecordType))
+	}
+	transport, address, err := dnssecNameserver(s)
+	if err != nil {
+		return err
+	}
+	answer, err := dnssecQuery(ctx, s, transport, address, recordType, false)
+	if err != nil {
+		return err
+	}
+	if answer.rcode == dnsmessage.RCodeServerFailure {
+		// a validating resolver fails bogus answers, they are only returned when checking is disabled
+		unchecked, err := dnssecQuery(ctx, s, transport, address, recordType, true)
+		if err == nil && unchecked.rcode == dnsmessage.RCodeSuccess {
+			return fmt.Errorf("DNSSEC signatures are invalid, the answer only resolves with checking disabled")
+		}
+	}
+	if answer.rcode != dnsmessage.RCodeSuccess {
+		return fmt.Errorf("nameserver %v returned %v", address, answer.rcode)
+	}
+	if answer.signatures == 0 {
+		return fmt.Errorf("DNSSEC signatures are missing, no RRSIG records in %d answers", answer.answers)
+	}
+	if !answer.authenticated {
+		return fmt.Errorf("DNSSEC signatures are invalid, nameserver %v did not authenticate the answer", address)
+	}
+	return nil
+}
+
+// dnssecQuery will send the query for the service's domain with the DNSSEC OK bit set, using TCP if the
+// UDP response was truncated
+func dnssecQuery(ctx context.Context, s *Service, transport, address string, recordType dnsmessage.Type, checkingDisabled bool) (*dnssecAnswer, error) {
+	name, err := dnsmessage.NewName(strings.TrimSuffix(s.Domain, ".") + ".")
+	if err != nil {
+		return nil, err
+	}
+	id := uint16(rand.Intn(1 << 16))
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	builder.EnableCompression()
+	builder.StartQuestions()
+	builder.Question(dnsmessage.Question{Name: name, Type: recordType, Class: dnsmessage.ClassINET})
+	builder.StartAdditionals()
+	var opt dnsmessage.ResourceHeader
+	opt.SetEDNS0(4096, dnsmessage.RCodeSuccess, true)
+	builder.OPTResource(opt, dnsmessage.OPTResource{})
+	query, err := builder.Finish()
+	if err != nil {
+		return nil, err
+	}
+	// dnsmessage has no fields for the AD and CD flags, set them in the packed header
+	flags := binary.BigEndian.Uint16(query[2:]) | dnsBitAD
+	if checkingDisabled {
+		flags |= dnsBitCD
+	}
+	binary.BigEndian.PutUint16(query[2:], flags)
+
+	if transport == "" {
+		transport = "udp"
+	}
+	response, err := dnsExchange(ctx, s, transport, address, query)
+	if err == nil && transport == "udp" && len(response) > 2 && binary.BigEndian.Uint16(response[2:])&(1<<9) != 0 {
+		response, err = dnsExchange(ctx, s, "tcp", address, query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseDnssecAnswer(response, id)
+}
+
+// dnsExchange will send the packed query to the nameserver and return the packed response
+func dnsExchange(ctx context.Context, s *Service, transport, address string, query []byte) ([]byte, error) {
+	dialer := &net.Dialer{Timeout: s.ConnectTimeoutDuration()}
+	conn, err := dialer.DialContext(ctx, transport, address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	defer closeOnCancel(ctx, conn)()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if transport == "tcp" {
+		length := make([]byte, 2)
+		binary.BigEndian.PutUint16(length, uint16(len(query)))
+		if _, err := conn.Write(append(length, query...)); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return nil, err
+		}
+		response := make([]byte, binary.BigEndian.Uint16(length))
+		_, err := io.ReadFull(conn, response)
+		return response, err
+	}
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	response := make([]byte, 4096)
+	n, err := conn.Read(response)
+	return response[:n], err
+}
+
+// parseDnssecAnswer returns the response code, the AD flag and the amount of answers and signatures of the response
+func parseDnssecAnswer(response []byte, id uint16) (*dnssecAnswer, error) {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(response)
+	if err != nil {
+		return nil, err
+	}
+	if header.ID != id || !header.Response {
+		return nil, errors.New("nameserver returned a response for a different query")
+	}
+	answer := &dnssecAnswer{
+		rcode:         header.RCode,
+		authenticated: binary.BigEndian.Uint16(response[2:])&dnsBitAD != 0,
+	}
+	if err := parser.SkipAllQuestions(); err != nil {
+		return nil, err
+	}
+	for {
+		h, err := parser.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if h.Type == dnsTypeRRSIG {
+			answer.signatures++
+		} else {
+			answer.answers++
+		}
+		if err := parser.SkipAnswer(); err != nil {
+			return nil, err
+		}
+	}
+	return answer, nil
+}
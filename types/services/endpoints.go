@@ -0,0 +1,136 @@
+package services
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/statping/statping/types/failures"
+)
+
+// StringList is a list of strings stored in the database as a JSON array
+type StringList []string
+
+// Value will store the list as a JSON array
+func (l StringList) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return "", nil
+	}
+	return jsonValue(l)
+}
+
+// Scan will parse the list from the JSON array in the database
+func (l *StringList) Scan(value interface{}) error {
+	*l = nil
+	return scanJSON(value, l)
+}
+
+// probeResult is the outcome of checking one of the Endpoints of a service
+type probeResult struct {
+	endpoint string
+	online   bool
+	issue    string
+	category string
+	service  *Service
+}
+
+// quorum returns the amount of Endpoints that have to be healthy, all of them if Quorum is not set
+func (s *Service) quorum() int {
+	if s.Quorum <= 0 || s.Quorum > len(s.Endpoints) {
+		return len(s.Endpoints)
+	}
+	return s.Quorum
+}
+
+// probeEndpoint will check the endpoint with a copy of the service, the failure or success of the
+// copy is only kept in the result and is never recorded
+func probeEndpoint(probe Service, endpoint string) *probeResult {
+	result := &probeResult{endpoint: endpoint}
+	probe.Domain = endpoint
+	probe.Endpoints = nil
+	probe.probed = result
+	probe.maintenanceResp = false
+	CheckHttp(&probe, true)
+	// a maintenance response is not a failure of the endpoint
+	result.online = result.online || probe.maintenanceResp
+	result.service = &probe
+	return result
+}
+
+// checkHttpQuorum will check each of the Endpoints concurrently, the service is online when at least
+// the quorum of the endpoints are healthy. The failure names each endpoint that was not healthy.
+func checkHttpQuorum(s *Service, record bool) (*Service, error) {
+	defer s.updateLastCheck()
+
+	results := make([]*probeResult, len(s.Endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range s.Endpoints {
+		wg.Add(1)
+		go func(i int, probe Service, endpoint string) {
+			defer wg.Done()
+			results[i] = probeEndpoint(probe, endpoint)
+		}(i, *s, endpoint)
+	}
+	wg.Wait()
+
+	// the service was stopped during the check
+	if s.Running != nil && !s.IsRunning() {
+		return s, nil
+	}
+
+	var healthy *Service
+	var online int
+	var failed []string
+	category := ""
+	s.Latency, s.PingTime = 0, 0
+	for _, r := range results {
+		if r.service.Latency > s.Latency {
+			s.Latency = r.service.Latency
+		}
+		if r.service.PingTime > s.PingTime {
+			s.PingTime = r.service.PingTime
+		}
+		if r.online {
+			online++
+			if healthy == nil {
+				healthy = r.service
+			}
+			continue
+		}
+		failed = append(failed, fmt.Sprintf("%v (%v)", r.endpoint, r.issue))
+		if category == "" {
+			category = r.category
+		}
+	}
+	if healthy != nil {
+		s.LastResponse = healthy.LastResponse
+		s.LastStatusCode = healthy.LastStatusCode
+		s.RemoteIP = healthy.RemoteIP
+		s.timing = healthy.timing
+		s.Protocol = healthy.Protocol
+	}
+
+	quorum := s.quorum()
+	if online < quorum {
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("%d of %d endpoints are healthy, %d required, failed: %v", online, len(s.Endpoints), quorum, strings.Join(failed, ", ")),
+				Reason:   "quorum",
+				Category: category,
+				Expected: strconv.Itoa(quorum),
+				Actual:   strconv.Itoa(online),
+			})
+		}
+		return s, nil
+	}
+	if len(failed) > 0 {
+		s.markDegraded(fmt.Sprintf("%d of %d endpoints are healthy, failed: %v", online, len(s.Endpoints), strings.Join(failed, ", ")))
+	}
+	if record {
+		RecordSuccess(s)
+	}
+	s.Online = true
+	return s, nil
+}
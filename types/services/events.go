@@ -0,0 +1,67 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// CheckEvent is the result of a recorded check, sent to the subscribers of the check events
+type CheckEvent struct {
+	Service   int64     `json:"service"`
+	Online    bool      `json:"online"`
+	Status    string    `json:"status"`
+	Latency   int64     `json:"latency"`
+	PingTime  int64     `json:"ping_time"`
+	Issue     string    `json:"issue,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var (
+	eventsMu    sync.RWMutex
+	subscribers = make(map[chan CheckEvent]struct{})
+)
+
+// SubscribeEvents returns a channel receiving the events of every recorded check and a function to
+// unsubscribe. Events are dropped for a subscriber that has buffer events it did not receive yet,
+// so a slow subscriber never blocks the checks.
+func SubscribeEvents(buffer int) (<-chan CheckEvent, func()) {
+	events := make(chan CheckEvent, buffer)
+	eventsMu.Lock()
+	subscribers[events] = struct{}{}
+	eventsMu.Unlock()
+
+	var once sync.Once
+	return events, func() {
+		once.Do(func() {
+			eventsMu.Lock()
+			delete(subscribers, events)
+			eventsMu.Unlock()
+		})
+	}
+}
+
+// publishEvent will send the event to the subscribers without waiting for any of them
+func publishEvent(event CheckEvent) {
+	eventsMu.RLock()
+	defer eventsMu.RUnlock()
+	for events := range subscribers {
+		select {
+		case events <- event:
+		default:
+			log.Debugf("Check event of service #%d was dropped for a slow subscriber", event.Service)
+		}
+	}
+}
+
+// checkEvent returns the event of the last check of the service, with the issue if it failed
+func (s *Service) checkEvent(issue string, at time.Time) CheckEvent {
+	return CheckEvent{
+		Service:   s.Id,
+		Online:    s.Online,
+		Status:    s.Status,
+		Latency:   s.Latency,
+		PingTime:  s.PingTime,
+		Issue:     issue,
+		CreatedAt: at,
+	}
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/statping/statping/utils"
+)
+
+// expectedRefresh is how often an expected response from a file or URL is read again, so updating the
+// file takes effect without restarting the service
+const expectedRefresh = time.Minute
+
+// cachedExpected is the last expected response read from the file or URL of a service
+type cachedExpected struct {
+	source    string
+	reference string
+	body      string
+	expires   time.Time
+}
+
+// validateExpectedSource returns an error if the ExpectedSource is unknown or the Expected reference is invalid
+func (s *Service) validateExpectedSource() error {
+	switch s.ExpectedSource {
+	case "":
+		return nil
+	case "file":
+		if s.Expected.String == "" {
+			return errors.New("expected response must be the path of the file when the source is 'file'")
+		}
+		return nil
+	case "url":
+		u, err := url.Parse(s.Expected.String)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("expected response must be a http:// or https:// URL when the source is 'url'")
+		}
+		return nil
+	}
+	return errors.New("expected source must be 'file' or 'url'")
+}
+
+// expectedResponse returns the expected response of the service, which is read from the file or URL in
+// Expected when the ExpectedSource is set. The response is cached for expectedRefresh and the last
+// response is kept if it can not be read again.
+func (s *Service) expectedResponse() (string, error) {
+	if s.ExpectedSource == "" {
+		return s.expectedValue(), nil
+	}
+	cache := s.expectedCache
+	if cache != nil && (cache.source != s.ExpectedSource || cache.reference != s.Expected.String) {
+		cache = nil
+	}
+	if cache != nil && utils.Now().Before(cache.expires) {
+		return cache.body, nil
+	}
+	body, err := readExpected(s.ExpectedSource, s.Expected.String, s.TimeoutDuration())
+	if err != nil {
+		if cache != nil {
+			log.Warnln(fmt.Sprintf("Service %v could not read the expected response again, using the last one: %v", s.Name, err))
+			cache.expires = utils.Now().Add(expectedRefresh)
+			return cache.body, nil
+		}
+		return "", err
+	}
+	s.expectedCache = &cachedExpected{
+		source:    s.ExpectedSource,
+		reference: s.Expected.String,
+		body:      body,
+		expires:   utils.Now().Add(expectedRefresh),
+	}
+	return body, nil
+}
+
+// expectedReference returns the file or URL the expected response was read from, to be appended to a failure
+func (s *Service) expectedReference() string {
+	if s.ExpectedSource == "" {
+		return ""
+	}
+	return fmt.Sprintf(" from %v", s.Expected.String)
+}
+
+// readExpected returns the contents of the expected response file or URL
+func readExpected(source, reference string, timeout time.Duration) (string, error) {
+	if source == "file" {
+		content, err := ioutil.ReadFile(reference)
+		if err != nil {
+			return "", err
+		}
+		return string(content), nil
+	}
+	content, res, err := utils.HttpRequest(reference, "GET", nil, nil, nil, timeout, true, nil)
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return "", fmt.Errorf("expected response URL returned status code %d", res.StatusCode)
+	}
+	return string(content), nil
+}
+
+// expectedFlags returns the inline flags of the CaseInsensitive and Multiline options for the expected regex
+func (s *Service) expectedFlags() string {
+	var flags string
+	if s.CaseInsensitive.Bool {
+		flags += "i"
+	}
+	if s.Multiline.Bool {
+		flags += "m"
+	}
+	if flags == "" {
+		return ""
+	}
+	return "(?" + flags + ")"
+}
+
+// expectedRegexp returns the expected regex compiled with the flags of the service, it is compiled once and
+// kept until the pattern or the flags change
+func (s *Service) expectedRegexp(pattern string) (*regexp.Regexp, error) {
+	pattern = s.expectedFlags() + pattern
+	if s.expectedRegex != nil && s.expectedRegex.String() == pattern {
+		return s.expectedRegex, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	s.expectedRegex = re
+	return re, nil
+}
+
+// usesExpectedRegex returns true if the service matches its Expected response as a regex
+func (s *Service) usesExpectedRegex() bool {
+	switch s.Type {
+	case "http":
+		return !s.ExpectedExact.Bool
+	case "tcp", "udp", "dns", "websocket", "mqtt":
+		return true
+	}
+	return false
+}
+
+// matchExpected returns true if the value matches the Expected regex of the service, using the compiled regex
+func (s *Service) matchExpected(value string) (bool, error) {
+	expected := s.expectedValue()
+	re, err := s.expectedRegexp(expected)
+	if err != nil {
+		log.Warnln(fmt.Sprintf("Service %v expected: %v is not a valid regex, %v", s.Name, expected, err))
+		return false, err
+	}
+	return re.MatchString(value), nil
+}
+
+// validateExpectedRegex returns an error if the inline Expected response of the service is not a valid regex
+func (s *Service) validateExpectedRegex() error {
+	if s.Expected.String == "" || s.ExpectedSource != "" || !s.usesExpectedRegex() {
+		return nil
+	}
+	if _, err := regexp.Compile(s.expectedFlags() + s.expectedValue()); err != nil {
+		return errors.New(fmt.Sprintf("expected response is not a valid regex, %v", err))
+	}
+	return nil
+}
+
+// redirectRegexp returns the compiled ExpectedRedirect regex, the regex is only compiled again when the
+// ExpectedRedirect was changed
+func (s *Service) redirectRegexp() (*regexp.Regexp, error) {
+	if s.redirectRegex != nil && s.redirectRegex.String() == s.ExpectedRedirect {
+		return s.redirectRegex, nil
+	}
+	re, err := regexp.Compile(s.ExpectedRedirect)
+	if err != nil {
+		return nil, err
+	}
+	s.redirectRegex = re
+	return re, nil
+}
+
+// validateExpectedRedirect returns an error if the ExpectedRedirect of the service is not a valid regex
+func (s *Service) validateExpectedRedirect() error {
+	if s.ExpectedRedirect == "" {
+		return nil
+	}
+	if _, err := s.redirectRegexp(); err != nil {
+		return errors.New(fmt.Sprintf("expected redirect is not a valid regex, %v", err))
+	}
+	return nil
+}
+
+// validateExpectedChecksum returns an error if the ExpectedChecksum of the service is not a SHA-256 hex digest
+func (s *Service) validateExpectedChecksum() error {
+	if s.ExpectedChecksum == "" {
+		return nil
+	}
+	if digest, err := hex.DecodeString(s.ExpectedChecksum); err != nil || len(digest) != sha256.Size {
+		return errors.New("expected checksum must be a SHA-256 hex digest")
+	}
+	return nil
+}
+
+// checksumDigest returns the SHA-256 hash written the whole response body while it is read, or nil if the
+// service has no ExpectedChecksum
+func (s *Service) checksumDigest() hash.Hash {
+	if s.ExpectedChecksum == "" {
+		return nil
+	}
+	return sha256.New()
+}
+
+// matchChecksum returns the hex digest of the response body written to the hash and true if it equals the
+// ExpectedChecksum
+func (s *Service) matchChecksum(h hash.Hash) (string, bool) {
+	digest := hex.EncodeToString(h.Sum(nil))
+	return digest, strings.EqualFold(digest, s.ExpectedChecksum)
+}
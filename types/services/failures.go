@@ -1,8 +1,11 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/statping/statping/types/failures"
+	"net"
 	"strings"
 	"time"
 )
@@ -46,6 +49,22 @@ func parseError(f *failures.Failure) string {
 	if err {
 		return fmt.Sprintf("SSL Certificate invalid")
 	}
+	err = strings.Contains(f.Issue, "HTTP Proxy Error")
+	if err {
+		return fmt.Sprintf("Proxy Connection Failed")
+	}
+	err = strings.Contains(f.Issue, "Packet loss of")
+	if err {
+		return fmt.Sprintf("Packet Loss")
+	}
+	err = strings.Contains(f.Issue, "exceeded threshold")
+	if err {
+		return fmt.Sprintf("Slow Response Time")
+	}
+	err = strings.Contains(f.Issue, "SSL Certificate expires")
+	if err {
+		return fmt.Sprintf("SSL Certificate expiring")
+	}
 	err = strings.Contains(f.Issue, "Client.Timeout exceeded while awaiting headers")
 	if err {
 		return fmt.Sprintf("Connection Timed Out")
@@ -80,3 +99,51 @@ func parseError(f *failures.Failure) string {
 	}
 	return f.Issue
 }
+
+// failureCategory returns the Failure category for the reason of a failure
+func failureCategory(reason string) string {
+	switch reason {
+	case "lookup", "parse_domain", "dnssec":
+		return failures.CategoryDns
+	case "connection", "close", "read", "write", "packet_loss", "proxy", "source_ip":
+		return failures.CategoryConnect
+	case "tls", "ssl_expiry":
+		return failures.CategoryTls
+	case "status_code", "response_code", "healthcheck", "reflection", "protocol", "auth", "clock_offset":
+		return failures.CategoryStatus
+	case "regex", "response_body", "header", "redirect", "json_path", "content_length", "checksum", "schema":
+		return failures.CategoryBody
+	case "latency":
+		return failures.CategoryLatency
+	case "trigger":
+		return failures.CategoryTrigger
+	}
+	return failures.CategoryOther
+}
+
+// errorCategory returns the Failure category for an error returned while checking a service,
+// the fallback category is returned if the error could not be categorized.
+func errorCategory(err error, fallback string) string {
+	if err == nil {
+		return fallback
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return failures.CategoryTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return failures.CategoryTimeout
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) || strings.Contains(err.Error(), "unknown host") {
+		return failures.CategoryDns
+	}
+	if strings.Contains(err.Error(), "x509:") || strings.Contains(err.Error(), "tls:") {
+		return failures.CategoryTls
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return failures.CategoryConnect
+	}
+	return fallback
+}
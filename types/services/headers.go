@@ -0,0 +1,91 @@
+package services
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// HttpHeader is a request header sent with the checks of a HTTP or websocket service
+type HttpHeader struct {
+	Key   string `json:"key" yaml:"key"`
+	Value string `json:"value" yaml:"value"`
+}
+
+// HttpHeaders are the request headers of a service, stored in the database as a JSON array
+type HttpHeaders []HttpHeader
+
+// Value will store the headers as a JSON array
+func (h HttpHeaders) Value() (driver.Value, error) {
+	if len(h) == 0 {
+		return "", nil
+	}
+	return jsonValue(h)
+}
+
+// Scan will parse the headers from the JSON array in the database
+func (h *HttpHeaders) Scan(value interface{}) error {
+	*h = nil
+	return scanJSON(value, h)
+}
+
+// jsonValue returns v as a JSON string to be stored in a text column
+func jsonValue(v interface{}) (driver.Value, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// scanJSON will parse the JSON text column value into dest, an empty column is left as is
+func scanJSON(value interface{}, dest interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unable to scan %T into %T", value, dest)
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, dest)
+}
+
+// strings returns the headers as KEY=VALUE strings for utils.HttpRequest
+func (h HttpHeaders) strings() []string {
+	var headers []string
+	for _, header := range h {
+		headers = append(headers, header.Key+"="+header.Value)
+	}
+	return headers
+}
+
+// parseHeaders returns the headers of the legacy comma delimited KEY=VALUE,KEY=VALUE string
+func parseHeaders(legacy string) HttpHeaders {
+	var headers HttpHeaders
+	for _, h := range strings.Split(legacy, ",") {
+		keyVal := strings.SplitN(h, "=", 2)
+		if len(keyVal) == 2 && keyVal[0] != "" {
+			headers = append(headers, HttpHeader{Key: keyVal[0], Value: keyVal[1]})
+		}
+	}
+	return headers
+}
+
+// requestHeaders returns the HttpHeaders of the service, or the headers parsed from the legacy
+// Headers string for services that were saved before HttpHeaders existed
+func (s *Service) requestHeaders() HttpHeaders {
+	if len(s.HttpHeaders) > 0 {
+		return s.HttpHeaders
+	}
+	if s.Headers.Valid {
+		return parseHeaders(s.Headers.String)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"net/http"
+	"sync"
+)
+
+var (
+	httpClientMu sync.RWMutex
+	httpClient   *http.Client
+)
+
+// SetHttpClient will set the client used by the HTTP checks of every service without their own HttpClient,
+// the built-in client is used again when it is nil. The client replaces the built-in transport, which never
+// reuses connections, so checks sent with it can reuse connections and skip the connect and TLS handshake
+func SetHttpClient(client *http.Client) {
+	httpClientMu.Lock()
+	defer httpClientMu.Unlock()
+	httpClient = client
+}
+
+// httpClient returns the client to send the HTTP checks of the service with, or nil for the built-in client
+func (s *Service) httpClient() *http.Client {
+	if s.HttpClient != nil {
+		return s.HttpClient
+	}
+	httpClientMu.RLock()
+	defer httpClientMu.RUnlock()
+	return httpClient
+}
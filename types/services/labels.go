@@ -0,0 +1,55 @@
+package services
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/statping/statping/types/errors"
+	"github.com/statping/statping/types/metrics"
+)
+
+// labelName is a valid Prometheus label name
+var labelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// LabelMap are the key/value labels of a service, such as the team or region, stored in the database as a JSON object
+type LabelMap map[string]string
+
+// Value will store the labels as a JSON object
+func (l LabelMap) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return "", nil
+	}
+	return jsonValue(l)
+}
+
+// Scan will parse the labels from the JSON object in the database
+func (l *LabelMap) Scan(value interface{}) error {
+	*l = nil
+	return scanJSON(value, l)
+}
+
+// validateLabels returns an error if a label key can't be used as a Prometheus label name, the
+// 'id' and 'name' labels are reserved for the service
+func (s *Service) validateLabels() error {
+	for key := range s.Labels {
+		if !labelName.MatchString(key) || strings.HasPrefix(key, "__") {
+			return errors.New(fmt.Sprintf("label '%v' must start with a letter or '_' and only contain letters, digits and '_'", key))
+		}
+		if key == "id" || key == "name" {
+			return errors.New(fmt.Sprintf("label '%v' is reserved", key))
+		}
+	}
+	return nil
+}
+
+// exportLabels will export the labels of the service in the statping_service_info metric, the metrics
+// of the previous name are deleted when the service was renamed
+func (s *Service) exportLabels() {
+	if s.exportedName != "" && s.exportedName != s.Name {
+		metrics.DeleteServiceMetrics(s.Id, s.exportedName)
+	}
+	s.exportedName = s.Name
+	metrics.SetServiceLabels(s.Id, s.Name, s.Labels)
+}
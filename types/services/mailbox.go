@@ -0,0 +1,110 @@
+package services
+
+import (
+	"fmt"
+	"net/textproto"
+	"strings"
+)
+
+// mailboxPorts are the default ports of the mailbox services, the second port is used with implicit TLS
+var mailboxPorts = map[string][2]int{
+	"imap": {143, 993},
+	"pop3": {110, 995},
+}
+
+// mailboxPort returns the port of an IMAP or POP3 service and whether the connection uses implicit TLS,
+// which is used with VerifySSL or when the port is the standard IMAPS or POP3S port
+func (s *Service) mailboxPort() (int, bool) {
+	ports := mailboxPorts[s.Type]
+	port := s.Port
+	if port == 0 {
+		port = ports[0]
+		if s.VerifySSL.Bool {
+			port = ports[1]
+		}
+	}
+	return port, s.VerifySSL.Bool || port == ports[1]
+}
+
+// mailboxGreeting will read the greeting of the IMAP or POP3 server and return an error if it was not accepted
+func mailboxGreeting(text *textproto.Conn, protocol string) (string, error) {
+	line, err := text.ReadLine()
+	if err != nil {
+		return line, err
+	}
+	switch protocol {
+	case "imap":
+		if !strings.HasPrefix(line, "* OK") && !strings.HasPrefix(line, "* PREAUTH") {
+			return line, fmt.Errorf("server greeting was not OK: %v", line)
+		}
+	case "pop3":
+		if !strings.HasPrefix(line, "+OK") {
+			return line, fmt.Errorf("server greeting was not +OK: %v", line)
+		}
+	}
+	return line, nil
+}
+
+// mailboxLogin will login to the IMAP or POP3 server with the credentials
+func mailboxLogin(text *textproto.Conn, protocol, username, password string) error {
+	if protocol == "imap" {
+		_, err := imapCommand(text, "a1", "LOGIN "+imapQuote(username)+" "+imapQuote(password))
+		return err
+	}
+	if _, err := pop3Command(text, "USER "+username); err != nil {
+		return err
+	}
+	_, err := pop3Command(text, "PASS "+password)
+	return err
+}
+
+// mailboxLogout will end the session with the IMAP or POP3 server, the response is not required
+func mailboxLogout(text *textproto.Conn, protocol string) {
+	if protocol == "imap" {
+		imapCommand(text, "a2", "LOGOUT")
+		return
+	}
+	pop3Command(text, "QUIT")
+}
+
+// imapCommand will send the tagged IMAP command and return the tagged response if it was OK,
+// untagged responses before it are skipped
+func imapCommand(text *textproto.Conn, tag, command string) (string, error) {
+	if err := text.PrintfLine("%s %s", tag, command); err != nil {
+		return "", err
+	}
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasPrefix(line, tag+" ") {
+			continue
+		}
+		response := strings.TrimPrefix(line, tag+" ")
+		if !strings.HasPrefix(response, "OK") {
+			return response, fmt.Errorf("server responded: %v", response)
+		}
+		return response, nil
+	}
+}
+
+// pop3Command will send the POP3 command and return the response if it was +OK
+func pop3Command(text *textproto.Conn, command string) (string, error) {
+	if err := text.PrintfLine("%s", command); err != nil {
+		return "", err
+	}
+	line, err := text.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(line, "+OK") {
+		return line, fmt.Errorf("server responded: %v", line)
+	}
+	return line, nil
+}
+
+// imapQuote returns the value as an IMAP quoted string
+func imapQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"crypto/sha1"
 	"crypto/tls"
 	"crypto/x509"
@@ -12,57 +13,178 @@ import (
 	"github.com/statping/statping/types/hits"
 	"github.com/statping/statping/utils"
 	"io/ioutil"
+	"math"
+	"net"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
 const limitedFailures = 25
 
+// LoadTLSCert returns the TLS config with the client certificate and the Root CA pool when they are set,
+// the Root CA can be used without a client certificate to verify services signed by a private CA.
 func (s *Service) LoadTLSCert() (*tls.Config, error) {
-	if s.TLSCert.String == "" || s.TLSCertKey.String == "" {
+	hasCert := s.TLSCert.String != "" && s.TLSCertKey.String != ""
+	if !hasCert && s.TLSCertRoot.String == "" {
 		return nil, nil
 	}
 
-	// load TLS cert and key from file path or PEM format
-	var cert tls.Certificate
-	var err error
-	tlsCertExtension := utils.FileExtension(s.TLSCert.String)
-	tlsCertKeyExtension := utils.FileExtension(s.TLSCertKey.String)
-	if tlsCertExtension == "" && tlsCertKeyExtension == "" {
-		cert, err = tls.X509KeyPair([]byte(s.TLSCert.String), []byte(s.TLSCertKey.String))
-	} else {
-		cert, err = tls.LoadX509KeyPair(s.TLSCert.String, s.TLSCertKey.String)
-	}
-	if err != nil {
-		return nil, errors.Wrap(err, "issue loading X509KeyPair")
-	}
-
 	config := &tls.Config{
-		Certificates:       []tls.Certificate{cert},
 		InsecureSkipVerify: s.TLSCertRoot.String == "",
 	}
 
+	// load TLS cert and key from file path or PEM format
+	var err error
+	if hasCert {
+		var cert tls.Certificate
+		if isPEM(s.TLSCert.String) && isPEM(s.TLSCertKey.String) {
+			cert, err = tls.X509KeyPair([]byte(s.TLSCert.String), []byte(s.TLSCertKey.String))
+		} else {
+			cert, err = tls.LoadX509KeyPair(s.TLSCert.String, s.TLSCertKey.String)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "issue loading X509KeyPair")
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
 	if s.TLSCertRoot.String == "" {
 		return config, nil
 	}
 
 	// create Root CA pool or use Root CA provided
 	rootCA := s.TLSCertRoot.String
-	caCert, err := ioutil.ReadFile(rootCA)
-	if err != nil {
-		return nil, errors.Wrap(err, "issue reading root CA file: "+rootCA)
+	caCert := []byte(rootCA)
+	if !isPEM(rootCA) {
+		caCert, err = ioutil.ReadFile(rootCA)
+		if err != nil {
+			return nil, errors.Wrap(err, "issue reading root CA file: "+rootCA)
+		}
 	}
 	caCertPool := x509.NewCertPool()
-	caCertPool.AppendCertsFromPEM(caCert)
+	if !caCertPool.AppendCertsFromPEM(caCert) {
+		return nil, errors.New("no certificates found in root CA: " + rootCA)
+	}
 
 	config.RootCAs = caCertPool
 
 	return config, nil
 }
 
+// verifyTLS returns true if the service's certificate should be verified, a Root CA is always verified
+func (s *Service) verifyTLS(config *tls.Config) bool {
+	return s.VerifySSL.Bool || (config != nil && config.RootCAs != nil)
+}
+
+// isPEM returns true if the value is PEM encoded rather than a file path
+func isPEM(value string) bool {
+	return strings.Contains(value, "-----BEGIN ")
+}
+
 func (s Service) Duration() time.Duration {
-	return time.Duration(s.Interval) * time.Second
+	return time.Duration(s.Interval) * s.intervalUnit()
+}
+
+// downDuration returns the interval between checks while the service is offline, IntervalDown in the unit
+// of the Interval or the Interval when it is not set
+func (s Service) downDuration() time.Duration {
+	if s.IntervalDown <= 0 {
+		return s.Duration()
+	}
+	return time.Duration(s.IntervalDown) * s.intervalUnit()
+}
+
+// intervalUnit returns the unit of the Interval, existing services without an IntervalUnit are in seconds
+func (s Service) intervalUnit() time.Duration {
+	switch s.IntervalUnit {
+	case "ms":
+		return time.Millisecond
+	case "us":
+		return time.Microsecond
+	default:
+		return time.Second
+	}
+}
+
+// TimeoutDuration returns the overall deadline for a check
+func (s Service) TimeoutDuration() time.Duration {
+	return time.Duration(s.Timeout) * time.Second
+}
+
+// ipNetwork returns the network restricted to the service's IP version, such as "tcp4" or "ip6"
+func (s Service) ipNetwork(network string) string {
+	switch s.IPVersion {
+	case "ipv4":
+		return network + "4"
+	case "ipv6":
+		return network + "6"
+	default:
+		return network
+	}
+}
+
+// sourceAddr returns the local address the connections of the service are sent from, or nil if SourceIP is
+// not set. An error is returned if the SourceIP can not be bound, such as when it is not an address of this host.
+func (s Service) sourceAddr(network string) (net.Addr, error) {
+	if s.SourceIP == "" {
+		return nil, nil
+	}
+	ip := net.ParseIP(s.SourceIP)
+	if ip == nil {
+		return nil, errors.New(fmt.Sprintf("source address '%v' is not an IP address", s.SourceIP))
+	}
+	conn, err := net.ListenPacket("udp", net.JoinHostPort(ip.String(), "0"))
+	if err != nil {
+		return nil, err
+	}
+	conn.Close()
+	if strings.HasPrefix(network, "udp") {
+		return &net.UDPAddr{IP: ip}, nil
+	}
+	return &net.TCPAddr{IP: ip}, nil
+}
+
+// maintenancePeriod returns how often the maintenance window repeats, or 0 if it does not repeat
+func (s Service) maintenancePeriod() time.Duration {
+	switch s.MaintenanceRepeat {
+	case "daily":
+		return types.Day
+	case "weekly":
+		return types.Week
+	default:
+		return 0
+	}
+}
+
+// maintenanceWindow returns true if the time is inside of the service's maintenance window,
+// along with the time the current window ends
+func (s Service) maintenanceWindow(now time.Time) (bool, time.Time) {
+	if s.MaintenanceStart.IsZero() || !s.MaintenanceEnd.After(s.MaintenanceStart) || now.Before(s.MaintenanceStart) {
+		return false, time.Time{}
+	}
+	length := s.MaintenanceEnd.Sub(s.MaintenanceStart)
+	period := s.maintenancePeriod()
+	if period == 0 {
+		if now.Before(s.MaintenanceEnd) {
+			return true, s.MaintenanceEnd
+		}
+		return false, time.Time{}
+	}
+	start := now.Add(-(now.Sub(s.MaintenanceStart) % period))
+	if now.Before(start.Add(length)) {
+		return true, start.Add(length)
+	}
+	return false, time.Time{}
+}
+
+// ConnectTimeoutDuration returns the timeout for dialing the service, falls back to the overall Timeout
+func (s Service) ConnectTimeoutDuration() time.Duration {
+	if s.ConnectTimeout <= 0 {
+		return s.TimeoutDuration()
+	}
+	return time.Duration(s.ConnectTimeout) * time.Second
 }
 
 // Start will create a channel for the service checking go routine
@@ -184,6 +306,25 @@ func (s *Service) Start() {
 		return
 	}
 	s.Running = make(chan bool)
+	s.forced = make(chan chan bool)
+}
+
+// checkContext returns a context that is cancelled when the service is stopped with Close,
+// the returned cancel func must be called once the check is complete
+func (s *Service) checkContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	running := s.Running
+	if running == nil {
+		return ctx, cancel
+	}
+	go func() {
+		select {
+		case <-running:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
 }
 
 // Close will stop the go routine that is checking if service is online or not
@@ -229,9 +370,11 @@ func SelectAllServices(start bool) (map[int64]*Service, error) {
 	for _, s := range all() {
 		s.Failures = s.AllFailures().LastAmount(limitedFailures)
 		s.prevOnline = true
+		s.loadLatencies()
 		// collect initial service stats
 		s.UpdateStats()
 		allServices[s.Id] = s
+		s.exportLabels()
 		if start {
 			CheckinProcess(s)
 		}
@@ -243,6 +386,7 @@ func (s *Service) UpdateStats() *Service {
 	s.Online24Hours = s.OnlineDaysPercent(1)
 	s.Online7Days = s.OnlineDaysPercent(7)
 	s.AvgResponse = s.AvgTime()
+	s.updatePercentiles()
 	s.FailuresLast24Hours = s.FailuresSince(utils.Now().Add(-time.Hour * 24)).Count()
 
 	allFails := s.AllFailures()
@@ -261,6 +405,132 @@ func (s *Service) UpdateStats() *Service {
 	return s
 }
 
+// hitLatency is the latency of a 'hit' kept to compute the percentiles without querying the database
+type hitLatency struct {
+	createdAt time.Time
+	latency   int64
+}
+
+// loadLatencies will cache the latency of the hits within the LATENCY_WINDOW when the service is loaded or updated
+func (s *Service) loadLatencies() {
+	window := utils.Params.GetDuration("LATENCY_WINDOW")
+	s.latencies = nil
+	for _, hit := range s.HitsSince(utils.Now().Add(-window)).List() {
+		s.latencies = append(s.latencies, hitLatency{createdAt: hit.CreatedAt, latency: hit.Latency})
+	}
+}
+
+// trackLatency will cache the latency of a new 'hit' and remove the latencies outside of the LATENCY_WINDOW
+func (s *Service) trackLatency(hit *hits.Hit) {
+	since := hit.CreatedAt.Add(-utils.Params.GetDuration("LATENCY_WINDOW"))
+	recent := s.latencies[:0]
+	for _, l := range s.latencies {
+		if l.createdAt.After(since) {
+			recent = append(recent, l)
+		}
+	}
+	s.latencies = append(recent, hitLatency{createdAt: hit.CreatedAt, latency: hit.Latency})
+}
+
+// updatePercentiles will set the p50, p95 and p99 latency of the cached hits within the LATENCY_WINDOW
+func (s *Service) updatePercentiles() {
+	since := utils.Now().Add(-utils.Params.GetDuration("LATENCY_WINDOW"))
+	var latencies []int64
+	for _, l := range s.latencies {
+		if l.createdAt.After(since) {
+			latencies = append(latencies, l.latency)
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	s.LatencyP50 = percentile(latencies, 50)
+	s.LatencyP95 = percentile(latencies, 95)
+	s.LatencyP99 = percentile(latencies, 99)
+}
+
+// defaultLatencyAlpha is the weight of the newest latency in the SmoothedLatency when LatencyAlpha is not set
+const defaultLatencyAlpha = 0.3
+
+// latencyResetIntervals is the amount of intervals a service has to be offline before the SmoothedLatency
+// starts over, the latency from before a long outage says little about the latency after it
+const latencyResetIntervals = 10
+
+// latencyAlpha returns the weight of the newest latency in the exponential moving average
+func (s *Service) latencyAlpha() float64 {
+	if s.LatencyAlpha <= 0 || s.LatencyAlpha > 1 {
+		return defaultLatencyAlpha
+	}
+	return s.LatencyAlpha
+}
+
+// smoothLatency will add the latency of a successful check to the SmoothedLatency, an exponential
+// moving average of the latency. The average starts over after an outage of latencyResetIntervals.
+func (s *Service) smoothLatency(latency int64, downtime time.Duration) {
+	if s.SmoothedLatency == 0 || downtime > latencyResetIntervals*s.Duration() {
+		s.SmoothedLatency = latency
+		return
+	}
+	alpha := s.latencyAlpha()
+	s.SmoothedLatency = int64(math.Round(alpha*float64(latency) + (1-alpha)*float64(s.SmoothedLatency)))
+}
+
+// anomalyWindow is the amount of latest latencies the baseline of the latency anomaly detection is weighted over
+const anomalyWindow = 50
+
+// defaultAnomalyMinSamples is the amount of successful checks before latency anomalies are detected when
+// AnomalyMinSamples is not set
+const defaultAnomalyMinSamples = 20
+
+// anomalyMinSamples returns the amount of successful checks in the baseline before anomalies are detected
+func (s *Service) anomalyMinSamples() int {
+	if s.AnomalyMinSamples <= 0 {
+		return defaultAnomalyMinSamples
+	}
+	return s.AnomalyMinSamples
+}
+
+// latencyAnomaly returns the reason if the latency is over the mean of the baseline plus AnomalyDeviations
+// standard deviations, and then adds the latency to the baseline
+func (s *Service) latencyAnomaly(latency int64) string {
+	if s.AnomalyDeviations <= 0 {
+		return ""
+	}
+	var reason string
+	if s.latencySamples >= s.anomalyMinSamples() {
+		threshold := s.latencyMean + s.AnomalyDeviations*math.Sqrt(s.latencyVariance)
+		if float64(latency) > threshold {
+			reason = fmt.Sprintf("Response time %s exceeded the baseline of %s by more than %v standard deviations", humanMicro(latency), humanMicro(int64(s.latencyMean)), s.AnomalyDeviations)
+		}
+	}
+	s.updateBaseline(float64(latency))
+	return reason
+}
+
+// updateBaseline will add the latency to the exponentially weighted mean and variance of the latency,
+// which is a plain mean and variance until the anomalyWindow has been filled
+func (s *Service) updateBaseline(latency float64) {
+	s.latencySamples++
+	alpha := 2.0 / (anomalyWindow + 1)
+	if weight := 1 / float64(s.latencySamples); weight > alpha {
+		alpha = weight
+	}
+	diff := latency - s.latencyMean
+	increment := alpha * diff
+	s.latencyMean += increment
+	s.latencyVariance = (1 - alpha) * (s.latencyVariance + diff*increment)
+}
+
+// percentile returns the nearest-rank percentile of the sorted values, or 0 if there are no values
+func percentile(sorted []int64, percent float64) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(percent / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
 // AvgTime will return the average amount of time for a service to response back successfully
 func (s Service) AvgTime() int64 {
 	return s.AllHits().Avg()
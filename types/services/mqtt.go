@@ -0,0 +1,167 @@
+package services
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// MQTT 3.1.1 control packet types, shifted into the upper nibble of the fixed header
+const (
+	mqttConnect    = 0x10
+	mqttConnack    = 0x20
+	mqttPublish    = 0x30
+	mqttSubscribe  = 0x82
+	mqttSuback     = 0x90
+	mqttDisconnect = 0xe0
+)
+
+// mqttConnackCodes are the reasons a broker will refuse the connection
+var mqttConnackCodes = map[byte]string{
+	0: "accepted",
+	1: "unacceptable protocol version",
+	2: "identifier rejected",
+	3: "server unavailable",
+	4: "bad username or password",
+	5: "not authorized",
+}
+
+// mqttPacket is a control packet received from the broker
+type mqttPacket struct {
+	header  byte
+	payload []byte
+}
+
+// mqttString returns the value prefixed with its length, as strings are encoded in MQTT packets
+func mqttString(value string) []byte {
+	data := make([]byte, 2, 2+len(value))
+	binary.BigEndian.PutUint16(data, uint16(len(value)))
+	return append(data, value...)
+}
+
+// mqttEncode returns the packet with the fixed header and the variable length encoded remaining length
+func mqttEncode(header byte, body []byte) []byte {
+	packet := []byte{header}
+	length := len(body)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		packet = append(packet, digit)
+		if length == 0 {
+			break
+		}
+	}
+	return append(packet, body...)
+}
+
+// mqttConnectPacket returns the CONNECT packet for a clean session with the optional credentials
+func mqttConnectPacket(clientId, username, password string, keepAlive uint16) []byte {
+	var flags byte = 0x02
+	if username != "" {
+		flags |= 0x80
+	}
+	if password != "" {
+		flags |= 0x40
+	}
+	body := append(mqttString("MQTT"), 4, flags, byte(keepAlive>>8), byte(keepAlive))
+	body = append(body, mqttString(clientId)...)
+	if username != "" {
+		body = append(body, mqttString(username)...)
+	}
+	if password != "" {
+		body = append(body, mqttString(password)...)
+	}
+	return mqttEncode(mqttConnect, body)
+}
+
+// mqttSubscribePacket returns the SUBSCRIBE packet for the topic with QoS 0
+func mqttSubscribePacket(packetId uint16, topic string) []byte {
+	body := []byte{byte(packetId >> 8), byte(packetId)}
+	body = append(body, mqttString(topic)...)
+	return mqttEncode(mqttSubscribe, append(body, 0))
+}
+
+// mqttPublishPacket returns the PUBLISH packet of the message to the topic with QoS 0
+func mqttPublishPacket(topic, message string) []byte {
+	return mqttEncode(mqttPublish, append(mqttString(topic), message...))
+}
+
+// mqttRead will read the next control packet from the broker
+func mqttRead(r *bufio.Reader) (*mqttPacket, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	var length, multiplier int = 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return nil, fmt.Errorf("malformed remaining length")
+		}
+		digit, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		length += int(digit&0x7f) * multiplier
+		multiplier *= 128
+		if digit&0x80 == 0 {
+			break
+		}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return &mqttPacket{header: header, payload: payload}, nil
+}
+
+// mqttConnackError returns an error if the CONNACK packet did not accept the connection
+func mqttConnackError(p *mqttPacket) error {
+	if p.header&0xf0 != mqttConnack || len(p.payload) != 2 {
+		return fmt.Errorf("expected CONNACK, received packet type %d", p.header>>4)
+	}
+	if code := p.payload[1]; code != 0 {
+		reason, ok := mqttConnackCodes[code]
+		if !ok {
+			reason = "return code " + strconv.Itoa(int(code))
+		}
+		return fmt.Errorf("connection refused, %v", reason)
+	}
+	return nil
+}
+
+// mqttSubackError returns an error if the SUBACK packet did not grant the subscription
+func mqttSubackError(p *mqttPacket, packetId uint16) error {
+	if p.header&0xf0 != mqttSuback || len(p.payload) < 3 {
+		return fmt.Errorf("expected SUBACK, received packet type %d", p.header>>4)
+	}
+	if binary.BigEndian.Uint16(p.payload) != packetId {
+		return fmt.Errorf("SUBACK for unknown packet id %d", binary.BigEndian.Uint16(p.payload))
+	}
+	if p.payload[2] == 0x80 {
+		return fmt.Errorf("subscription refused")
+	}
+	return nil
+}
+
+// mqttMessage returns the topic and message of a PUBLISH packet
+func mqttMessage(p *mqttPacket) (string, string, error) {
+	if p.header&0xf0 != mqttPublish || len(p.payload) < 2 {
+		return "", "", fmt.Errorf("expected PUBLISH, received packet type %d", p.header>>4)
+	}
+	topicLen := int(binary.BigEndian.Uint16(p.payload))
+	if len(p.payload) < 2+topicLen {
+		return "", "", fmt.Errorf("malformed PUBLISH packet")
+	}
+	topic := string(p.payload[2 : 2+topicLen])
+	message := p.payload[2+topicLen:]
+	// QoS 1 and 2 messages have a packet id before the message
+	if p.header&0x06 != 0 && len(message) >= 2 {
+		message = message[2:]
+	}
+	return topic, string(message), nil
+}
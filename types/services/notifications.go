@@ -4,6 +4,7 @@ import (
 	"github.com/statping/statping/types/failures"
 	"github.com/statping/statping/types/notifications"
 	"github.com/statping/statping/utils"
+	"time"
 )
 
 func AddNotifier(n ServiceNotifier) {
@@ -18,8 +19,10 @@ func UpdateNotifiers() {
 	}
 }
 
-func sendSuccess(s *Service) {
-	if !s.AllowNotifications.Bool {
+// sendSuccess will notify the ServiceNotifiers that the service is online, when the service recovered the
+// RecoverNotifiers were already sent the recovery so they are not sent a success too
+func sendSuccess(s *Service, recovered bool) {
+	if !s.AllowNotifications.Bool || s.Flapping {
 		return
 	}
 
@@ -28,6 +31,9 @@ func sendSuccess(s *Service) {
 	}
 
 	for _, n := range allNotifiers {
+		if _, ok := n.(RecoverNotifier); ok && recovered {
+			continue
+		}
 		notif := n.Select()
 		if notif.CanSend() {
 			log.Infof("Sending notification to: %s!", notif.Method)
@@ -47,11 +53,92 @@ func sendSuccess(s *Service) {
 	s.notifyAfterCount++
 }
 
-func sendFailure(s *Service, f *failures.Failure) {
+// sendRecover will notify the RecoverNotifiers that the service is online after being offline for downtime
+func sendRecover(s *Service, downtime time.Duration) {
+	if !s.AllowNotifications.Bool || s.Flapping {
+		return
+	}
+
+	for _, n := range allNotifiers {
+		recoverer, ok := n.(RecoverNotifier)
+		if !ok {
+			continue
+		}
+		notif := n.Select()
+		if notif.CanSend() {
+			log.Infof("Sending Recovery notification to: %s!", notif.Method)
+			out, err := recoverer.OnRecover(*s, downtime)
+			if err != nil {
+				notif.Logger().Errorln(err)
+				logMessage(notif.Method, "", err, false, s.Id)
+				continue
+			}
+			logMessage(notif.Method, out, nil, true, s.Id)
+			notif.LastSentCount++
+			notif.LastSent = utils.Now()
+		}
+	}
+}
+
+// sendFlapping will notify the FlappingNotifiers once that the service started flapping
+func sendFlapping(s *Service) {
 	if !s.AllowNotifications.Bool {
 		return
 	}
 
+	for _, n := range allNotifiers {
+		flapper, ok := n.(FlappingNotifier)
+		if !ok {
+			continue
+		}
+		notif := n.Select()
+		if notif.CanSend() {
+			log.Infof("Sending Flapping notification to: %s!", notif.Method)
+			out, err := flapper.OnFlapping(*s, len(s.transitions))
+			if err != nil {
+				notif.Logger().Errorln(err)
+				logMessage(notif.Method, "", err, false, s.Id)
+				continue
+			}
+			logMessage(notif.Method, out, nil, true, s.Id)
+			notif.LastSentCount++
+			notif.LastSent = utils.Now()
+		}
+	}
+}
+
+// sendStatusChange will notify the StatusNotifiers that the service changed from the previous status
+func sendStatusChange(s *Service, previous, status string) {
+	if !s.AllowNotifications.Bool || s.Flapping {
+		return
+	}
+
+	for _, n := range allNotifiers {
+		changer, ok := n.(StatusNotifier)
+		if !ok {
+			continue
+		}
+		notif := n.Select()
+		if notif.CanSend() {
+			log.Infof("Sending Status notification to: %s!", notif.Method)
+			out, err := changer.OnStatusChange(*s, previous, status)
+			if err != nil {
+				notif.Logger().Errorln(err)
+				logMessage(notif.Method, "", err, false, s.Id)
+				continue
+			}
+			logMessage(notif.Method, out, nil, true, s.Id)
+			notif.LastSentCount++
+			notif.LastSent = utils.Now()
+		}
+	}
+}
+
+func sendFailure(s *Service, f *failures.Failure) {
+	if !s.AllowNotifications.Bool || s.Flapping {
+		return
+	}
+
 	if s.prevOnline == s.Online && !s.UpdateNotify.Bool {
 		return
 	}
@@ -63,6 +150,11 @@ func sendFailure(s *Service, f *failures.Failure) {
 		}
 	}
 
+	if s.throttleFailure(f.Issue) {
+		log.Debugf("Service %v failure notification was throttled, the issue is unchanged since %v", s.Name, s.lastNotified)
+		return
+	}
+
 	for _, n := range allNotifiers {
 		notif := n.Select()
 		if notif.CanSend() {
@@ -81,6 +173,71 @@ func sendFailure(s *Service, f *failures.Failure) {
 
 	s.prevOnline = false
 	s.notifyAfterCount++
+	s.lastNotified = utils.Now()
+	s.lastNotifyIssue = f.Issue
+}
+
+// throttleFailure returns true if the same issue was already sent within the NotifyResend minutes
+func (s *Service) throttleFailure(issue string) bool {
+	if s.NotifyResend <= 0 || s.lastNotified.IsZero() {
+		return false
+	}
+	if issue != s.lastNotifyIssue {
+		return false
+	}
+	return utils.Now().Sub(s.lastNotified) < time.Duration(s.NotifyResend)*time.Minute
+}
+
+// resetThrottle will allow the next failure notification to be sent, such as after the service recovers
+func (s *Service) resetThrottle() {
+	s.lastNotified = time.Time{}
+	s.lastNotifyIssue = ""
+}
+
+const defaultFlapWindow = 10 * time.Minute
+
+// flapWindow returns the duration the state changes are counted in to detect flapping
+func (s *Service) flapWindow() time.Duration {
+	if s.FlapWindow <= 0 {
+		return defaultFlapWindow
+	}
+	return time.Duration(s.FlapWindow) * time.Second
+}
+
+// updateFlapping will track a change between online and offline at now and returns true if the service
+// started flapping, which is when it changed state more than FlapThreshold times within the flap window.
+// Notifications are suppressed while flapping, which ends once the state has not changed for a whole window.
+func (s *Service) updateFlapping(now time.Time, changed bool) bool {
+	if s.FlapThreshold <= 0 {
+		s.Flapping = false
+		s.transitions = nil
+		return false
+	}
+	window := s.flapWindow()
+	recent := s.transitions[:0]
+	for _, t := range s.transitions {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	if changed {
+		recent = append(recent, now)
+	}
+	s.transitions = recent
+
+	if s.Flapping {
+		if len(recent) == 0 {
+			log.Infof("Service %v stopped flapping, it was stable for %v", s.Name, window)
+			s.Flapping = false
+		}
+		return false
+	}
+	if len(recent) > s.FlapThreshold {
+		log.Warnf("Service %v is flapping, it changed state %d times within %v", s.Name, len(recent), window)
+		s.Flapping = true
+		return true
+	}
+	return false
 }
 
 func logMessage(method string, msg string, error error, onSuccesss bool, serviceId int64) {
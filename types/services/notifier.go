@@ -3,6 +3,7 @@ package services
 import (
 	"github.com/statping/statping/types/failures"
 	"github.com/statping/statping/types/notifications"
+	"time"
 )
 
 var (
@@ -39,3 +40,18 @@ type ServiceNotifier interface {
 	Select() *notifications.Notification                 // OnTest is triggered for testing
 	Valid(notifications.Values) error                    // Valid checks your form values
 }
+
+// RecoverNotifier is optional for a ServiceNotifier to be notified once when an offline service is back online
+type RecoverNotifier interface {
+	OnRecover(Service, time.Duration) (string, error) // OnRecover is triggered with the downtime when a service recovers
+}
+
+// FlappingNotifier is optional for a ServiceNotifier to be notified once when a service starts flapping
+type FlappingNotifier interface {
+	OnFlapping(Service, int) (string, error) // OnFlapping is triggered with the amount of state changes within the flap window
+}
+
+// StatusNotifier is optional for a ServiceNotifier to be notified when a service changes between up, degraded and down
+type StatusNotifier interface {
+	OnStatusChange(Service, string, string) (string, error) // OnStatusChange is triggered with the previous and the new status
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/statping/statping/utils"
+)
+
+const (
+	// ntpPort is the default port of NTP servers
+	ntpPort = 123
+	// ntpPacketSize is the size of a NTP packet without extensions
+	ntpPacketSize = 48
+	// ntpEpochOffset is the amount of seconds between the NTP epoch of 1900 and the unix epoch
+	ntpEpochOffset = 2208988800
+)
+
+// ntpResult is the response of a NTP server, the offset is the difference of the server clock to the
+// local clock and the delay is the round trip of the request without the processing time of the server
+type ntpResult struct {
+	Stratum int
+	Offset  time.Duration
+	Delay   time.Duration
+}
+
+// ntpAddress returns the address of the NTP server of the service, the port is 123 unless it is set
+func (s *Service) ntpAddress() string {
+	port := s.Port
+	if port == 0 {
+		port = ntpPort
+	}
+	return net.JoinHostPort(s.Domain, strconv.Itoa(port))
+}
+
+// exceedsClockOffset returns true if the offset is over the MaxClockOffset threshold
+func (s *Service) exceedsClockOffset(offset time.Duration) bool {
+	if s.MaxClockOffset <= 0 {
+		return false
+	}
+	if offset < 0 {
+		offset = -offset
+	}
+	return offset > time.Duration(s.MaxClockOffset*float64(time.Second))
+}
+
+// ntpQuery will send a client request on the connection and read the response of the NTP server
+func ntpQuery(conn net.Conn) (*ntpResult, error) {
+	request := make([]byte, ntpPacketSize)
+	// leap indicator 0, version 4 and client mode
+	request[0] = 0<<6 | 4<<3 | 3
+	t1 := utils.Now()
+	binary.BigEndian.PutUint64(request[40:], toNtpTime(t1))
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+
+	response := make([]byte, ntpPacketSize)
+	for {
+		n, err := conn.Read(response)
+		if err != nil {
+			return nil, err
+		}
+		t4 := utils.Now()
+		// responses to an earlier request are ignored
+		if n < ntpPacketSize || binary.BigEndian.Uint64(response[24:]) != binary.BigEndian.Uint64(request[40:]) {
+			continue
+		}
+		return parseNtpResponse(response, t1, t4)
+	}
+}
+
+// parseNtpResponse returns the result of the NTP response to a request sent at t1 and received at t4
+func parseNtpResponse(response []byte, t1, t4 time.Time) (*ntpResult, error) {
+	leap := response[0] >> 6
+	mode := response[0] & 0x7
+	stratum := int(response[1])
+	if mode != 4 {
+		return nil, fmt.Errorf("response mode %d is not a server response", mode)
+	}
+	if stratum == 0 {
+		return nil, fmt.Errorf("server sent kiss code '%s'", response[12:16])
+	}
+	if leap == 3 {
+		return nil, fmt.Errorf("server clock is not synchronized")
+	}
+	t2 := fromNtpTime(binary.BigEndian.Uint64(response[32:]))
+	t3 := fromNtpTime(binary.BigEndian.Uint64(response[40:]))
+	delay := t4.Sub(t1) - t3.Sub(t2)
+	if delay < 0 {
+		delay = 0
+	}
+	return &ntpResult{
+		Stratum: stratum,
+		Offset:  (t2.Sub(t1) + t3.Sub(t4)) / 2,
+		Delay:   delay,
+	}, nil
+}
+
+// toNtpTime returns the 64-bit NTP timestamp of the time
+func toNtpTime(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+// fromNtpTime returns the time of the 64-bit NTP timestamp
+func fromNtpTime(ts uint64) time.Time {
+	seconds := int64(ts>>32) - ntpEpochOffset
+	nanos := int64((ts & 0xffffffff) * uint64(time.Second) >> 32)
+	return time.Unix(seconds, nanos).UTC()
+}
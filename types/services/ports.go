@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql/driver"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/statping/statping/types/failures"
+	"github.com/statping/statping/utils"
+)
+
+// PortList is a list of ports stored in the database as a JSON array
+type PortList []int
+
+// Value will store the list as a JSON array
+func (l PortList) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return "", nil
+	}
+	return jsonValue(l)
+}
+
+// Scan will parse the list from the JSON array in the database
+func (l *PortList) Scan(value interface{}) error {
+	*l = nil
+	return scanJSON(value, l)
+}
+
+// checkPorts returns the Port and the Ports of the service without duplicates
+func (s *Service) checkPorts() []int {
+	var ports []int
+	seen := make(map[int]bool)
+	for _, port := range append([]int{s.Port}, s.Ports...) {
+		if port == 0 || seen[port] {
+			continue
+		}
+		seen[port] = true
+		ports = append(ports, port)
+	}
+	return ports
+}
+
+// portError is a port of the service that could not be connected to
+type portError struct {
+	port int
+	err  error
+}
+
+// checkTcpPorts will connect to every port of the service, with TLS if a TLS Certificate was set, the service
+// is only online if all of the ports are reachable. The failure has every port that could not be connected to.
+func checkTcpPorts(ctx context.Context, s *Service, record bool, dialer *net.Dialer, tlsConfig *tls.Config, t1 time.Time) (*Service, error) {
+	s.RemoteIP = ""
+	s.LastResponse = ""
+	var failed []portError
+	for _, port := range s.checkPorts() {
+		if err := s.dialPort(ctx, dialer, tlsConfig, port, t1.Add(s.TimeoutDuration())); err != nil {
+			failed = append(failed, portError{port, err})
+		}
+	}
+	if len(failed) > 0 {
+		ports := make([]string, len(failed))
+		issues := make([]string, len(failed))
+		for i, f := range failed {
+			ports[i] = strconv.Itoa(f.port)
+			issues[i] = fmt.Sprintf("%d: %v", f.port, f.err)
+		}
+		if record && ctx.Err() == nil {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("TCP ports %v failed, %v", strings.Join(ports, ", "), strings.Join(issues, "; ")),
+				Reason:   "connection",
+				Category: errorCategory(failed[0].err, failures.CategoryConnect),
+				Actual:   strings.Join(ports, ","),
+				Error:    failed[0].err.Error(),
+			})
+		}
+		return s, failed[0].err
+	}
+	s.Latency = utils.Now().Sub(t1).Microseconds()
+
+	if s.exceedsMaxLatency() {
+		if record {
+			RecordSlowResponse(s)
+		}
+		return s, nil
+	}
+
+	s.Online = true
+	if record {
+		RecordSuccess(s)
+	}
+	return s, nil
+}
+
+// dialPort will connect to the port of the service and close the connection, the remote IP is set by
+// the first connection
+func (s *Service) dialPort(ctx context.Context, dialer *net.Dialer, tlsConfig *tls.Config, port int, deadline time.Time) error {
+	conn, err := dialer.DialContext(ctx, s.ipNetwork(s.Type), net.JoinHostPort(s.Domain, strconv.Itoa(port)))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer closeOnCancel(ctx, conn)()
+	if s.RemoteIP == "" {
+		s.RemoteIP = remoteIP(conn.RemoteAddr().String())
+	}
+	if s.TLSCert.String != "" {
+		tlsConn := tls.Client(conn, tcpTLSConfig(tlsConfig, s.Domain))
+		tlsConn.SetDeadline(deadline)
+		return tlsConn.Handshake()
+	}
+	return nil
+}
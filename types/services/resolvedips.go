@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/statping/statping/types/failures"
+	"github.com/statping/statping/utils"
+)
+
+// ResolvedIP is the result of checking one of the resolved IP addresses of a service with AllResolvedIPs
+type ResolvedIP struct {
+	IP      string `json:"ip"`
+	Online  bool   `json:"online"`
+	Latency int64  `json:"latency"`
+	Issue   string `json:"issue,omitempty"`
+}
+
+// resolvedIPs returns the A records of the host of the service, or the AAAA records when IPVersion is ipv6
+func (s *Service) resolvedIPs() ([]net.IP, error) {
+	network := "ip4"
+	if s.IPVersion == "ipv6" {
+		network = "ip6"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.ConnectTimeoutDuration())
+	defer cancel()
+	host := parseHost(s)
+	ips, err := dnsResolver(s).LookupIP(ctx, network, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no %v address found for %v", network, host)
+	}
+	return ips, nil
+}
+
+// probeIP will check the service connecting to the IP with a copy of the service, the failure or
+// success of the copy is only kept in the result and is never recorded
+func probeIP(probe Service, ip string) *probeResult {
+	result := &probeResult{endpoint: ip}
+	probe.dialIP = ip
+	probe.probed = result
+	probe.maintenanceResp = false
+	CheckHttp(&probe, true)
+	// a maintenance response is not a failure of the IP
+	result.online = result.online || probe.maintenanceResp
+	result.service = &probe
+	return result
+}
+
+// checkHttpResolvedIPs will resolve every IP address of the host and check each of them concurrently with
+// the Host header and TLS server name of the domain, the service is only online if all of them are healthy.
+// The failure names each IP address that was not healthy.
+func checkHttpResolvedIPs(s *Service, record bool) (*Service, error) {
+	defer s.updateLastCheck()
+
+	t1 := utils.Now()
+	ips, err := s.resolvedIPs()
+	if err != nil {
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:  fmt.Sprintf("Could not get IP addresses for domain %v, %v", s.Domain, err),
+				Reason: "lookup",
+				Error:  err.Error(),
+			})
+		}
+		return s, err
+	}
+	s.PingTime = utils.Now().Sub(t1).Microseconds()
+
+	results := make([]*probeResult, len(ips))
+	var wg sync.WaitGroup
+	for i, ip := range ips {
+		wg.Add(1)
+		go func(i int, probe Service, ip string) {
+			defer wg.Done()
+			results[i] = probeIP(probe, ip)
+		}(i, *s, ip.String())
+	}
+	wg.Wait()
+
+	// the service was stopped during the check
+	if s.Running != nil && !s.IsRunning() {
+		return s, nil
+	}
+
+	var healthy *Service
+	var failed []string
+	category := ""
+	s.Latency = 0
+	s.ResolvedIPs = make([]ResolvedIP, len(results))
+	for i, r := range results {
+		s.ResolvedIPs[i] = ResolvedIP{IP: r.endpoint, Online: r.online, Latency: r.service.Latency, Issue: r.issue}
+		if r.service.Latency > s.Latency {
+			s.Latency = r.service.Latency
+		}
+		if r.online {
+			if healthy == nil {
+				healthy = r.service
+			}
+			continue
+		}
+		failed = append(failed, fmt.Sprintf("%v (%v)", r.endpoint, r.issue))
+		if category == "" {
+			category = r.category
+		}
+	}
+	if healthy != nil {
+		s.LastResponse = healthy.LastResponse
+		s.LastStatusCode = healthy.LastStatusCode
+		s.RemoteIP = healthy.RemoteIP
+		s.timing = healthy.timing
+		s.Protocol = healthy.Protocol
+	}
+
+	if len(failed) > 0 {
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("%d of %d resolved IP addresses are not healthy: %v", len(failed), len(results), strings.Join(failed, ", ")),
+				Reason:   "resolved_ip",
+				Category: category,
+			})
+		}
+		return s, nil
+	}
+	if record {
+		RecordSuccess(s)
+	}
+	s.Online = true
+	return s, nil
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"github.com/statping/statping/utils"
+	"sync"
+	"time"
+)
+
+// maxResponseLength is the max amount of bytes kept for each recorded response body
+const maxResponseLength = 4096
+
+// Response is a raw check response kept in memory for debugging the service
+type Response struct {
+	Response   string    `json:"response" scope:"user,admin"`
+	StatusCode int       `json:"status_code"`
+	Latency    int64     `json:"latency"`
+	Online     bool      `json:"online"`
+	Truncated  bool      `json:"truncated,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// responseHistory is a ring buffer of the most recent responses for a service
+type responseHistory struct {
+	mu        sync.Mutex
+	responses []Response
+	next      int
+}
+
+var historyLock sync.Mutex
+
+// responseHistorySize returns the amount of responses to keep per service from RESPONSE_HISTORY
+func responseHistorySize() int {
+	if utils.Params == nil {
+		return 0
+	}
+	return utils.Params.GetInt("RESPONSE_HISTORY")
+}
+
+// history returns the service's response history, creating it if needed
+func (s *Service) history() *responseHistory {
+	historyLock.Lock()
+	defer historyLock.Unlock()
+	if s.responses == nil {
+		s.responses = &responseHistory{}
+	}
+	return s.responses
+}
+
+// responseOnFailure returns true if response bodies are only kept for failing checks,
+// set for each service or for all services with RESPONSE_ON_FAILURE
+func (s *Service) responseOnFailure() bool {
+	if s.ResponseOnFailure.Bool {
+		return true
+	}
+	return utils.Params != nil && utils.Params.GetBool("RESPONSE_ON_FAILURE")
+}
+
+// maxRequestSize returns the max amount of bytes sent as a HTTP request body for all services from
+// MAX_REQUEST_SIZE, there is no limit if 0
+func maxRequestSize() int64 {
+	if utils.Params == nil {
+		return 0
+	}
+	return utils.Params.GetInt64("MAX_REQUEST_SIZE")
+}
+
+// maxRetainedResponse returns the max amount of bytes of a HTTP response kept as the LastResponse for all
+// services from MAX_RESPONSE_SIZE, there is no limit if 0
+func maxRetainedResponse() int {
+	if utils.Params == nil {
+		return 0
+	}
+	return utils.Params.GetInt("MAX_RESPONSE_SIZE")
+}
+
+// setLastResponse will keep the HTTP response as the LastResponse, cut at MAX_RESPONSE_SIZE if it is larger.
+// The LastResponse ends with utils.TruncatedBody if it was cut here, or if it was already cut while reading it.
+func (s *Service) setLastResponse(content []byte, truncated bool) {
+	if limit := maxRetainedResponse(); limit > 0 && len(content) > limit {
+		content = content[:limit:limit]
+		truncated = true
+	}
+	if truncated {
+		content = append(content[:len(content):len(content)], utils.TruncatedBody...)
+	}
+	s.LastResponse = string(content)
+	s.ResponseTruncated = truncated
+}
+
+// recordResponse will add the last response of the service to the response history,
+// overwriting the oldest response once the history is full
+func (s *Service) recordResponse(online bool) {
+	size := responseHistorySize()
+	if size <= 0 {
+		return
+	}
+	body := s.LastResponse
+	truncated := s.ResponseTruncated
+	if online && s.responseOnFailure() {
+		body = ""
+		truncated = false
+	}
+	if len(body) > maxResponseLength {
+		body = body[:maxResponseLength]
+		truncated = true
+	}
+	res := Response{
+		Response:   body,
+		StatusCode: s.LastStatusCode,
+		Latency:    s.Latency,
+		Online:     online,
+		Truncated:  truncated,
+		CreatedAt:  utils.Now(),
+	}
+
+	h := s.history()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.responses) > size {
+		// the configured size was lowered, only keep the newest responses
+		h.responses = h.ordered()[:size]
+		reverse(h.responses)
+		h.next = 0
+	}
+	if len(h.responses) < size {
+		h.responses = append(h.responses, res)
+		h.next = len(h.responses) % size
+		return
+	}
+	h.responses[h.next] = res
+	h.next = (h.next + 1) % size
+}
+
+// ordered returns the responses in the history from newest to oldest, the lock must be held
+func (h *responseHistory) ordered() []Response {
+	count := len(h.responses)
+	ordered := make([]Response, 0, count)
+	for i := 1; i <= count; i++ {
+		ordered = append(ordered, h.responses[(h.next-i+count)%count])
+	}
+	return ordered
+}
+
+// Responses returns the most recent responses of the service, newest first
+func (s *Service) Responses() []Response {
+	h := s.history()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.ordered()
+}
+
+func reverse(responses []Response) {
+	for i, j := 0, len(responses)-1; i < j; i, j = i+1, j-1 {
+		responses[i], responses[j] = responses[j], responses[i]
+	}
+}
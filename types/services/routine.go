@@ -1,41 +1,84 @@
 package services
 
 import (
-	"bytes"
+	"bufio"
 	"context"
 	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand"
 	"net"
 	"net/http"
+	"net/http/cookiejar"
+	"net/textproto"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/statping/statping/types/metrics"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
 
 	"github.com/statping/statping/types/failures"
 	"github.com/statping/statping/types/hits"
 	"github.com/statping/statping/utils"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
 )
 
 // checkServices will start the checking go routine for each service
 func CheckServices() {
 	log.Infoln(fmt.Sprintf("Starting monitoring process for %v Services", len(allServices)))
 	for _, s := range allServices {
+		if err := s.Validate(); err != nil {
+			log.Warnf("Service %v is misconfigured: %v", s.Name, err)
+		}
+		s.logWarnings()
 		time.Sleep(50 * time.Millisecond)
 		go ServiceCheckQueue(s, true)
 	}
 }
 
+// StopServices will stop the checking go routine for each service, cancelling any checks in progress
+func StopServices() {
+	for _, s := range allServices {
+		s.Close()
+	}
+}
+
 // CheckQueue is the main go routine for checking a service
 func ServiceCheckQueue(s *Service, record bool) {
+	addCheckerGauge(&checkerRoutines, "routines", 1)
+	defer addCheckerGauge(&checkerRoutines, "routines", -1)
 	s.Start()
 	s.Checkpoint = utils.Now()
+	s.warmupUntil = utils.Now().Add(warmupPeriod())
+	if expected, err := s.expectedResponse(); err != nil {
+		log.Warnln(fmt.Sprintf("Service %v could not read the expected response from %v: %v", s.Name, s.Expected.String, err))
+	} else if expected != "" && s.usesExpectedRegex() {
+		if _, err := s.expectedRegexp(expected); err != nil {
+			log.Warnln(fmt.Sprintf("Service %v expected: %v is not a valid regex, %v", s.Name, expected, err))
+		}
+	}
+	if s.ExpectedRedirect != "" {
+		if _, err := s.redirectRegexp(); err != nil {
+			log.Warnln(fmt.Sprintf("Service %v expected redirect: %v is not a valid regex, %v", s.Name, s.ExpectedRedirect, err))
+		}
+	}
+	if s.ExpectedSchema != "" {
+		if _, err := s.compiledSchema(); err != nil {
+			log.Warnln(fmt.Sprintf("Service %v expected schema is not a valid JSON Schema, %v", s.Name, err))
+		}
+	}
 	s.SleepDuration = (time.Duration(s.Id) * 100) * time.Millisecond
 
 CheckLoop:
@@ -44,21 +87,179 @@ CheckLoop:
 		case <-s.Running:
 			log.Infoln(fmt.Sprintf("Stopping service: %v", s.Name))
 			break CheckLoop
+		case done := <-s.forced:
+			// the forced check replaces the next scheduled check, which is rescheduled from now
+			log.Infoln(fmt.Sprintf("Service %v was forced to check now", s.Name))
+			s.Checkpoint = utils.Now()
+			s.queuedCheck(record)
+			close(done)
 		case <-time.After(s.SleepDuration):
-			s.CheckService(record)
-			s.UpdateStats()
-			s.Checkpoint = s.Checkpoint.Add(s.Duration())
-			if !s.Online {
+			if inMaintenance, end := s.maintenanceWindow(utils.Now()); inMaintenance {
+				if !s.InMaintenance {
+					log.Infoln(fmt.Sprintf("Service %v is in maintenance until %v, skipping checks", s.Name, end.Format(time.RFC1123)))
+				}
+				s.InMaintenance = true
+				// wake up at the end of the window to resume checking immediately
 				s.SleepDuration = s.Duration()
-			} else {
-				s.SleepDuration = s.Checkpoint.Sub(time.Now())
+				if untilEnd := end.Sub(utils.Now()); untilEnd < s.SleepDuration {
+					s.SleepDuration = untilEnd
+				}
+				s.Checkpoint = utils.Now().Add(s.SleepDuration)
+				continue
+			}
+			if active, next := s.activeSchedule(utils.Now()); !active {
+				if !s.OffSchedule {
+					log.Infoln(fmt.Sprintf("Service %v is outside of its active schedule until %v, skipping checks", s.Name, next.Format(time.RFC1123)))
+				}
+				s.OffSchedule = true
+				// wake up at the start of the next window to resume checking immediately
+				s.SleepDuration = s.Duration()
+				if untilNext := next.Sub(utils.Now()); !next.IsZero() && untilNext < s.SleepDuration {
+					s.SleepDuration = untilNext
+				}
+				s.Checkpoint = utils.Now().Add(s.SleepDuration)
+				continue
+			}
+			if s.OffSchedule {
+				log.Infoln(fmt.Sprintf("Service %v is inside of its active schedule, resuming checks", s.Name))
+				s.OffSchedule = false
+			}
+			if s.InMaintenance && !s.maintenanceResp {
+				log.Infoln(fmt.Sprintf("Service %v maintenance has ended, resuming checks", s.Name))
+				s.InMaintenance = false
 			}
+			s.queuedCheck(record)
+		}
+	}
+}
+
+// queuedCheck will check the service from CheckQueue and schedule the next check
+func (s *Service) queuedCheck(record bool) {
+	lock := s.checkLock()
+	lock.Lock()
+	defer lock.Unlock()
+	checkDuration, ok := s.pooledCheck(record, s.Running)
+	if !ok {
+		return
+	}
+	s.checkOverrun(checkDuration)
+	s.UpdateStats()
+	s.Checkpoint = nextCheckpoint(s.Checkpoint, utils.Now(), s.Duration())
+	if !s.Online {
+		s.SleepDuration = s.downBackoff()
+	} else {
+		s.SleepDuration = s.Checkpoint.Sub(time.Now())
+	}
+	s.SleepDuration = s.applyJitter(s.SleepDuration)
+}
+
+// ForceCheck will check the service immediately and return once the check is complete. When the service
+// is running, the check is run by CheckQueue so it never runs at the same time as a scheduled check, and
+// forced checks of a stopped service wait for each other.
+func (s *Service) ForceCheck() {
+	if s.forceQueued() {
+		return
+	}
+	s.checkNow(true)
+}
+
+// forceQueued will make the CheckQueue of the running service check now and wait for the check, false is
+// returned if the service is not running or was stopped before it checked
+func (s *Service) forceQueued() bool {
+	if !s.IsRunning() || s.forced == nil {
+		return false
+	}
+	done := make(chan bool)
+	select {
+	case s.forced <- done:
+		<-done
+		return true
+	case <-s.Running:
+		return false
+	}
+}
+
+// CheckAllNow will make every running service check now without waiting for the checks, and returns the
+// amount of services. The checks wait for a slot in the MAX_CONCURRENT_CHECKS worker pool like scheduled
+// checks, and each service continues checking on its interval from the forced check.
+func CheckAllNow() int {
+	var count int
+	for _, s := range All() {
+		if !s.IsRunning() || s.forced == nil {
+			continue
 		}
+		count++
+		go s.forceQueued()
 	}
+	log.Infoln(fmt.Sprintf("Forcing %d services to check now", count))
+	return count
+}
+
+// matchMaintenance returns true if the response matches MaintenanceStatus and the optional
+// MaintenanceExpected body regex. A maintenance response is neither a success nor a failure,
+// so nothing is recorded and InMaintenance is set until a response no longer matches
+func (s *Service) matchMaintenance(statusCode int, body []byte) bool {
+	match := s.MaintenanceStatus != 0 && statusCode == s.MaintenanceStatus
+	if match && s.MaintenanceExpected != "" {
+		var err error
+		match, err = regexp.Match(s.MaintenanceExpected, body)
+		if err != nil {
+			log.Warnln(fmt.Sprintf("Service %v maintenance expected: %v is not a valid regex, %v", s.Name, s.MaintenanceExpected, err))
+		}
+	}
+	if match && !s.maintenanceResp {
+		log.Infoln(fmt.Sprintf("Service %v responded with maintenance status %v, not recording the check", s.Name, statusCode))
+	} else if !match && s.maintenanceResp {
+		log.Infoln(fmt.Sprintf("Service %v is no longer responding with maintenance status %v", s.Name, s.MaintenanceStatus))
+	}
+	if match || s.maintenanceResp {
+		s.InMaintenance = match
+	}
+	s.maintenanceResp = match
+	return match
+}
+
+// checkOverrun will set CheckOverrun and warn when the check took longer than the interval,
+// which means the service is checked back to back and is falling behind its schedule
+func (s *Service) checkOverrun(elapsed time.Duration) {
+	overrun := elapsed > s.Duration()
+	if overrun && !s.CheckOverrun {
+		log.Warnln(fmt.Sprintf("Service %v check took %v, longer than the %v interval. Increase the interval or lower the timeout", s.Name, elapsed, s.Duration()))
+	} else if !overrun && s.CheckOverrun {
+		log.Infoln(fmt.Sprintf("Service %v check took %v, within the %v interval again", s.Name, elapsed, s.Duration()))
+	}
+	s.CheckOverrun = overrun
+}
+
+// jitterPercent returns the service's jitter percent, or the global CHECK_JITTER if not set
+func (s *Service) jitterPercent() int {
+	percent := s.Jitter
+	if percent <= 0 {
+		percent = utils.Params.GetInt("CHECK_JITTER")
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	return percent
+}
+
+// applyJitter will randomize the sleep duration by up to ±Jitter percent of the check interval,
+// the returned duration will never be zero or negative.
+func (s *Service) applyJitter(sleep time.Duration) time.Duration {
+	percent := s.jitterPercent()
+	maxJitter := int64(s.Duration()) * int64(percent) / 100
+	if maxJitter <= 0 {
+		return sleep
+	}
+	sleep += time.Duration(rand.Int63n(2*maxJitter+1) - maxJitter)
+	if sleep <= 0 {
+		return time.Millisecond
+	}
+	return sleep
 }
 
 func parseHost(s *Service) string {
-	if s.Type == "tcp" || s.Type == "udp" || s.Type == "grpc" {
+	if s.Type == "tcp" || s.Type == "udp" || s.Type == "grpc" || s.Type == "dns" || s.Type == "smtp" || s.Type == "imap" || s.Type == "pop3" || s.Type == "ntp" || s.Type == "mqtt" {
 		return s.Domain
 	} else {
 		u, err := url.Parse(s.Domain)
@@ -69,20 +270,178 @@ func parseHost(s *Service) string {
 	}
 }
 
+// remoteIP returns the IP address from a 'host:port' address of a connection
+func remoteIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// userAgent returns the User-Agent sent with HTTP checks, defaults to 'Statping/<version>'
+func (s *Service) userAgent() string {
+	if s.UserAgent != "" {
+		return s.UserAgent
+	}
+	return "Statping/" + utils.Params.GetString("VERSION")
+}
+
 // dnsCheck will check the domain name and return a float64 for the amount of time the DNS check took
 func dnsCheck(s *Service) (int64, error) {
 	var err error
-	t1 := utils.Now()
 	host := parseHost(s)
-	if s.Type == "tcp" || s.Type == "udp" || s.Type == "grpc" {
-		_, err = net.LookupHost(host)
+	if s.dnsCacheValid(host, utils.Now()) {
+		s.dnsCached = true
+		return s.dnsCacheLookup, nil
+	}
+	s.dnsCached = false
+	t1 := utils.Now()
+	if s.IPVersion != "" {
+		_, err = resolveIP(s, host)
 	} else {
-		_, err = net.LookupIP(host)
+		ctx, cancel := context.WithTimeout(context.Background(), s.ConnectTimeoutDuration())
+		defer cancel()
+		if s.Type == "tcp" || s.Type == "udp" || s.Type == "grpc" || s.Type == "smtp" || s.Type == "imap" || s.Type == "pop3" || s.Type == "ntp" || s.Type == "mqtt" {
+			_, err = dnsResolver(s).LookupHost(ctx, host)
+		} else {
+			_, err = dnsResolver(s).LookupIPAddr(ctx, host)
+		}
 	}
 	if err != nil {
+		s.dnsCacheExpires = time.Time{}
 		return 0, err
 	}
-	return utils.Now().Sub(t1).Microseconds(), err
+	lookup := utils.Now().Sub(t1).Microseconds()
+	if s.DnsCacheTTL > 0 {
+		s.dnsCacheHost = host
+		s.dnsCacheLookup = lookup
+		s.dnsCacheExpires = utils.Now().Add(time.Duration(s.DnsCacheTTL) * time.Second)
+	}
+	return lookup, err
+}
+
+// dnsCacheValid returns true if the last lookup of host can be reused, the lookup time
+// from the last uncached lookup is reported until the cache expires
+func (s *Service) dnsCacheValid(host string, now time.Time) bool {
+	if s.DnsCacheTTL <= 0 || s.dnsCacheHost != host {
+		return false
+	}
+	return now.Before(s.dnsCacheExpires)
+}
+
+// lookupText returns the lookup time for logs, marking lookups that were served from the cache
+func (s *Service) lookupText(pingTime int64) string {
+	if s.dnsCached {
+		return humanMicro(pingTime) + " (cached)"
+	}
+	return humanMicro(pingTime)
+}
+
+// resolveIP will return the first address of the host in the service's IP version
+func resolveIP(s *Service, host string) (net.IP, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.ConnectTimeoutDuration())
+	defer cancel()
+	ips, err := dnsResolver(s).LookupIP(ctx, s.ipNetwork("ip"), host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no %v address found for %v", s.IPVersion, host)
+	}
+	return ips[0], nil
+}
+
+// dnsResolver returns a net.Resolver that will use the service's custom nameserver, or DNS_RESOLVER
+// for all services, the system resolver is used if neither are set
+func dnsResolver(s *Service) *net.Resolver {
+	resolver := s.DnsResolver.String
+	if resolver == "" && utils.Params != nil {
+		resolver = utils.Params.GetString("DNS_RESOLVER")
+	}
+	if resolver == "" {
+		return net.DefaultResolver
+	}
+	transport, address := resolverAddress(resolver)
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			if transport != "" {
+				network = transport
+			}
+			d := net.Dialer{Timeout: s.ConnectTimeoutDuration()}
+			return d.DialContext(ctx, network, address)
+		},
+	}
+}
+
+// resolverAddress returns the transport and address of a nameserver such as '8.8.8.8', 'tcp://8.8.8.8:53' or 'udp://[::1]',
+// the port defaults to 53 and the transport is empty if the resolver should choose
+func resolverAddress(resolver string) (string, string) {
+	var transport string
+	if i := strings.Index(resolver, "://"); i >= 0 {
+		transport, resolver = strings.ToLower(resolver[:i]), resolver[i+3:]
+	}
+	if _, _, err := net.SplitHostPort(resolver); err != nil {
+		resolver = net.JoinHostPort(strings.Trim(resolver, "[]"), "53")
+	}
+	return transport, resolver
+}
+
+// lookupRecords will return the DNS answers for the service's record type
+func lookupRecords(ctx context.Context, resolver *net.Resolver, recordType, host string) ([]string, error) {
+	var answers []string
+	switch strings.ToUpper(recordType) {
+	case "", "A", "AAAA":
+		ips, err := resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			isV4 := ip.IP.To4() != nil
+			if strings.ToUpper(recordType) == "AAAA" && isV4 {
+				continue
+			}
+			if strings.ToUpper(recordType) != "AAAA" && !isV4 {
+				continue
+			}
+			answers = append(answers, ip.IP.String())
+		}
+	case "CNAME":
+		cname, err := resolver.LookupCNAME(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, cname)
+	case "MX":
+		mxs, err := resolver.LookupMX(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range mxs {
+			answers = append(answers, fmt.Sprintf("%v %v", mx.Pref, mx.Host))
+		}
+	case "TXT":
+		txts, err := resolver.LookupTXT(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, txts...)
+	case "NS":
+		nss, err := resolver.LookupNS(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range nss {
+			answers = append(answers, ns.Host)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported DNS record type %v", recordType)
+	}
+	if len(answers) == 0 {
+		return nil, fmt.Errorf("no %v records found for %v", strings.ToUpper(recordType), host)
+	}
+	return answers, nil
 }
 
 func isIPv6(address string) bool {
@@ -95,17 +454,194 @@ func CheckIcmp(s *Service, record bool) (*Service, error) {
 	timer := prometheus.NewTimer(metrics.ServiceTimer(s.Name))
 	defer timer.ObserveDuration()
 
-	dur, err := utils.Ping(s.Domain, s.Timeout)
+	s.PacketLoss = 0
+	s.RemoteIP = ""
+	var address string
+	if s.IPVersion != "" {
+		ip, err := resolveIP(s, s.Domain)
+		if err != nil {
+			if record {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("Could not get %v address for ICMP service %v, %v", s.IPVersion, s.Domain, err),
+					Reason:   "lookup",
+					Category: failures.CategoryDns,
+					Error:    err.Error(),
+				})
+			}
+			return s, err
+		}
+		address = ip.String()
+	} else {
+		// resolve the host before pinging so the address that was pinged can be recorded
+		ipAddr, err := net.ResolveIPAddr("ip", s.Domain)
+		if err != nil {
+			if record {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("Could not get IP address for ICMP service %v, %v", s.Domain, err),
+					Reason:   "lookup",
+					Category: failures.CategoryDns,
+					Error:    err.Error(),
+				})
+			}
+			return s, err
+		}
+		address = ipAddr.String()
+	}
+	s.RemoteIP = address
+
+	if _, err := s.sourceAddr("ip"); err != nil {
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:  fmt.Sprintf("Could not bind source address %v, %v", s.SourceIP, err),
+				Reason: "source_ip",
+				Error:  err.Error(),
+			})
+		}
+		return s, err
+	}
+	res, err := s.ping(address)
 	if err != nil {
+		s.PacketLoss = 100
 		if record {
-			RecordFailure(s, fmt.Sprintf("Could not send ICMP to service %v, %v", s.Domain, err), "lookup")
+			if errors.Is(err, utils.ErrPingPermission) {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("Not permitted to send ICMP to service %v, %v", s.Domain, err),
+					Reason:   "permission",
+					Category: failures.CategoryOther,
+					Error:    err.Error(),
+				})
+			} else {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("Could not send ICMP to service %v, %v", s.Domain, err),
+					Reason:   "lookup",
+					Category: errorCategory(err, failures.CategoryConnect),
+					Error:    err.Error(),
+				})
+			}
 		}
 		return s, err
 	}
 
-	s.PingTime = dur
-	s.Latency = dur
+	s.PingTime = res.Latency
+	s.Latency = s.pingLatency(res)
+	s.PacketLoss = res.PacketLoss
 	s.LastResponse = ""
+
+	if s.MaxPacketLoss > 0 && s.PacketLoss > s.MaxPacketLoss {
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("Packet loss of %0.1f%% is over the max of %0.1f%%", s.PacketLoss, s.MaxPacketLoss),
+				Reason:   "packet_loss",
+				Expected: fmt.Sprintf("%0.1f%%", s.MaxPacketLoss),
+				Actual:   fmt.Sprintf("%0.1f%%", s.PacketLoss),
+			})
+		}
+		return s, nil
+	}
+	s.Online = true
+	if record {
+		RecordSuccess(s)
+	}
+	return s, nil
+}
+
+// ping will send the ICMP packets with the system ping command. When the command is missing or not permitted, or a SourceIP is set,
+// the packets are sent from Statping with a raw socket if Privileged is set, or an unprivileged UDP ICMP socket.
+// If the socket can't be opened because of missing permissions the other kind of socket is tried
+func (s *Service) ping(address string) (*utils.PingResult, error) {
+	// the system ping command is not used with a SourceIP, the socket is bound to the address instead
+	if s.SourceIP == "" {
+		res, err := utils.PingCount(address, s.pingCount(), s.Timeout)
+		if !errors.Is(err, utils.ErrPingUnavailable) {
+			return res, err
+		}
+	}
+	privileged := s.Privileged.Bool
+	res, err := utils.PingICMPFrom(address, s.SourceIP, s.pingCount(), s.Timeout, privileged)
+	if errors.Is(err, utils.ErrPingPermission) {
+		log.Debugln(fmt.Sprintf("Service %v could not open an ICMP socket, %v. Trying with privileged: %v", s.Name, err, !privileged))
+		res, err = utils.PingICMPFrom(address, s.SourceIP, s.pingCount(), s.Timeout, !privileged)
+	}
+	return res, err
+}
+
+// pingLatency returns the latency of the ping selected by the LatencyMode, the average of the replies is used
+// if it is not set
+func (s *Service) pingLatency(res *utils.PingResult) int64 {
+	switch s.LatencyMode {
+	case "first":
+		return res.First
+	case "min":
+		return res.Min
+	default:
+		return res.Latency
+	}
+}
+
+// pingCount returns the amount of ICMP packets to send for each check, at least 1
+func (s *Service) pingCount() int {
+	if s.PingCount < 1 {
+		return 1
+	}
+	return s.PingCount
+}
+
+// CheckDns will resolve the DNS record type for the service and match the answers against the expected value
+func CheckDns(s *Service, record bool) (*Service, error) {
+	defer s.updateLastCheck()
+	timer := prometheus.NewTimer(metrics.ServiceTimer(s.Name))
+	defer timer.ObserveDuration()
+
+	checkCtx, stop := s.checkContext()
+	defer stop()
+	timeout := s.TimeoutDuration()
+	ctx, cancel := context.WithTimeout(checkCtx, timeout)
+	defer cancel()
+
+	t1 := utils.Now()
+	answers, err := lookupRecords(ctx, dnsResolver(s), s.DnsRecordType, s.Domain)
+	if err != nil {
+		if record && checkCtx.Err() == nil {
+			recordFailure(s, &failures.Failure{
+				Issue:  fmt.Sprintf("Could not lookup %v records for %v, %v", strings.ToUpper(s.DnsRecordType), s.Domain, err),
+				Reason: "lookup",
+				Error:  err.Error(),
+			})
+		}
+		return s, err
+	}
+	s.Latency = utils.Now().Sub(t1).Microseconds()
+	s.PingTime = s.Latency
+	s.LastResponse = strings.Join(answers, "\n")
+
+	if s.RequireDNSSEC.Bool {
+		if err := checkDnssec(ctx, s); err != nil {
+			if record && checkCtx.Err() == nil {
+				recordFailure(s, &failures.Failure{
+					Issue:  fmt.Sprintf("DNSSEC validation of %v records for %v failed, %v", strings.ToUpper(s.DnsRecordType), s.Domain, err),
+					Reason: "dnssec",
+					Error:  err.Error(),
+				})
+			}
+			return s, err
+		}
+	}
+
+	if s.Expected.String != "" {
+		match, err := s.matchExpected(s.LastResponse)
+		if !match {
+			if record {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("DNS %v answer '%v' did not match '%v'", strings.ToUpper(s.DnsRecordType), s.LastResponse, s.Expected.String),
+					Reason:   "regex",
+					Expected: s.Expected.String,
+					Actual:   s.LastResponse,
+				})
+			}
+			return s, err
+		}
+	}
+
 	s.Online = true
 	if record {
 		RecordSuccess(s)
@@ -126,7 +662,11 @@ func CheckGrpc(s *Service, record bool) (*Service, error) {
 			// Unable to parse.
 			log.Warnln(fmt.Sprintf("GRPC Service: '%s', Unable to parse URL: '%v'", s.Name, s.Domain))
 			if record {
-				RecordFailure(s, fmt.Sprintf("Unable to parse GRPC domain %v, %v", s.Domain, err), "parse_domain")
+				recordFailure(s, &failures.Failure{
+					Issue:  fmt.Sprintf("Unable to parse GRPC domain %v, %v", s.Domain, err),
+					Reason: "parse_domain",
+					Error:  err.Error(),
+				})
 			}
 		}
 
@@ -138,7 +678,24 @@ func CheckGrpc(s *Service, record bool) (*Service, error) {
 	dnsLookup, err := dnsCheck(s)
 	if err != nil {
 		if record {
-			RecordFailure(s, fmt.Sprintf("Could not get IP address for GRPC service %v, %v", s.Domain, err), "lookup")
+			recordFailure(s, &failures.Failure{
+				Issue:  fmt.Sprintf("Could not get IP address for GRPC service %v, %v", s.Domain, err),
+				Reason: "lookup",
+				Error:  err.Error(),
+			})
+		}
+		return s, err
+	}
+
+	customTLS, err := s.LoadTLSCert()
+	if err != nil {
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("Invalid TLS Client Certificate, %v", err),
+				Reason:   "tls",
+				Category: failures.CategoryTls,
+				Error:    err.Error(),
+			})
 		}
 		return s, err
 	}
@@ -147,10 +704,16 @@ func CheckGrpc(s *Service, record bool) (*Service, error) {
 	grpcOption := grpc.WithInsecure()
 
 	// Check if TLS is enabled
-	// Upgrade GRPC connection if using TLS
+	// Upgrade GRPC connection if using TLS, the client certificate is presented for mTLS services
 	// Force to connect on HTTP2 with TLS. Needed when using a reverse proxy such as nginx.
-	if s.VerifySSL.Bool {
-		h2creds := credentials.NewTLS(&tls.Config{NextProtos: []string{"h2"}})
+	if s.VerifySSL.Bool || customTLS != nil {
+		tlsConfig := &tls.Config{NextProtos: []string{"h2"}}
+		if customTLS != nil {
+			tlsConfig.Certificates = customTLS.Certificates
+			tlsConfig.RootCAs = customTLS.RootCAs
+			tlsConfig.InsecureSkipVerify = !s.verifyTLS(customTLS)
+		}
+		h2creds := credentials.NewTLS(tlsConfig)
 		grpcOption = grpc.WithTransportCredentials(h2creds)
 	}
 
@@ -166,63 +729,122 @@ func CheckGrpc(s *Service, record bool) (*Service, error) {
 
 	// Context will cancel the request when timeout is exceeded.
 	// Cancel the context when request is served within the timeout limit.
-	timeout := time.Duration(s.Timeout) * time.Second
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	checkCtx, stop := s.checkContext()
+	defer stop()
+	ctx, cancel := context.WithTimeout(checkCtx, s.TimeoutDuration())
 	defer cancel()
+	ctx = s.grpcContext(ctx)
+
+	dialer := &net.Dialer{Timeout: s.ConnectTimeoutDuration()}
+	grpcDialer := grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "tcp", addr)
+	})
 
-	conn, err := grpc.DialContext(ctx, domain, grpcOption, grpc.WithBlock())
+	conn, err := grpc.DialContext(ctx, domain, grpcOption, grpcDialer, grpc.WithBlock())
 	if err != nil {
-		if record {
-			RecordFailure(s, fmt.Sprintf("Dial Error %v", err), "connection")
+		if record && checkCtx.Err() == nil {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("Dial Error %v", err),
+				Reason:   "connection",
+				Category: errorCategory(err, failures.CategoryConnect),
+				Error:    err.Error(),
+			})
 		}
 		return s, err
 	}
 
-	if s.GrpcHealthCheck.Bool {
+	mode := s.grpcMode()
+	var reflected []string
+	if mode == "health" {
 		// Create a new health check client
 		c := healthpb.NewHealthClient(conn)
-		in := &healthpb.HealthCheckRequest{}
+		in := &healthpb.HealthCheckRequest{Service: s.GrpcService}
 		res, err := c.Check(ctx, in)
 		if err != nil {
-			if record {
-				RecordFailure(s, fmt.Sprintf("GRPC Error %v", err), "healthcheck")
+			if record && checkCtx.Err() == nil {
+				recordFailure(s, &failures.Failure{
+					Issue:  fmt.Sprintf("GRPC Error %v", err),
+					Reason: "healthcheck",
+					Error:  err.Error(),
+				})
 			}
 			return s, nil
 		}
 
 		// Record responses
-		s.LastResponse = strings.TrimSpace(res.String())
+		s.LastResponse = fmt.Sprintf("status:%v", res.GetStatus())
 		s.LastStatusCode = int(res.GetStatus())
+	} else if mode == "reflection" {
+		reflected, err = grpcReflectionServices(ctx, conn)
+		if err != nil {
+			conn.Close()
+			if record && checkCtx.Err() == nil {
+				recordFailure(s, &failures.Failure{
+					Issue:  fmt.Sprintf("GRPC Reflection Error %v", err),
+					Reason: "reflection",
+					Error:  err.Error(),
+				})
+			}
+			return s, nil
+		}
+		s.LastResponse = strings.Join(reflected, "\n")
 	}
 
 	if err := conn.Close(); err != nil {
 		if record {
-			RecordFailure(s, fmt.Sprintf("%v Socket Close Error %v", strings.ToUpper(s.Type), err), "close")
+			recordFailure(s, &failures.Failure{
+				Issue:  fmt.Sprintf("%v Socket Close Error %v", strings.ToUpper(s.Type), err),
+				Reason: "close",
+				Error:  err.Error(),
+			})
 		}
 		return s, err
 	}
 
 	// Record latency
 	s.Latency = utils.Now().Sub(t1).Microseconds()
-	s.Online = true
 
-	if s.GrpcHealthCheck.Bool {
-		if s.ExpectedStatus != s.LastStatusCode {
+	if mode == "reflection" && s.GrpcService != "" && !hasGrpcService(reflected, s.GrpcService) {
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("GRPC Service: '%s', '%v' was not in the reflected services", s.Name, s.GrpcService),
+				Reason:   "reflection",
+				Expected: s.GrpcService,
+				Actual:   s.LastResponse,
+			})
+		}
+		return s, nil
+	}
+
+	if mode == "health" {
+		expectedStatus := s.expectedGrpcStatus()
+		if int(expectedStatus) != s.LastStatusCode {
 			if record {
-				RecordFailure(s, fmt.Sprintf("GRPC Service: '%s', Status Code: expected '%v', got '%v'", s.Name, s.ExpectedStatus, s.LastStatusCode), "response_code")
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("GRPC Service: '%s', Status Code: expected '%v' (%v), got '%v' (%v)", s.Name, int(expectedStatus), expectedStatus, s.LastStatusCode, healthpb.HealthCheckResponse_ServingStatus(s.LastStatusCode)),
+					Reason:   "response_code",
+					Expected: expectedStatus.String(),
+					Actual:   healthpb.HealthCheckResponse_ServingStatus(s.LastStatusCode).String(),
+				})
 			}
 			return s, nil
 		}
 
-		if s.Expected.String != s.LastResponse {
+		if s.Expected.String != "" && s.Expected.String != s.LastResponse {
 			log.Warnln(fmt.Sprintf("GRPC Service: '%s', Response: expected '%v', got '%v'", s.Name, s.Expected.String, s.LastResponse))
 			if record {
-				RecordFailure(s, fmt.Sprintf("GRPC Response Body '%v' did not match '%v'", s.LastResponse, s.Expected.String), "response_body")
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("GRPC Response Body '%v' did not match '%v'", s.LastResponse, s.Expected.String),
+					Reason:   "response_body",
+					Expected: s.Expected.String,
+					Actual:   s.LastResponse,
+				})
 			}
 			return s, nil
 		}
 	}
 
+	s.Online = true
 	if record {
 		RecordSuccess(s)
 	}
@@ -230,6 +852,79 @@ func CheckGrpc(s *Service, record bool) (*Service, error) {
 	return s, nil
 }
 
+// grpcMode returns the GrpcMode, services without one use 'health' when GrpcHealthCheck is enabled
+func (s *Service) grpcMode() string {
+	if s.GrpcMode == "" && s.GrpcHealthCheck.Bool {
+		return "health"
+	}
+	return s.GrpcMode
+}
+
+// grpcContext returns the context with the GrpcMetadata attached to the outgoing calls, keys are lower case as
+// required by gRPC and a key can be given more than once
+func (s *Service) grpcContext(ctx context.Context) context.Context {
+	if len(s.GrpcMetadata) == 0 {
+		return ctx
+	}
+	md := metadata.MD{}
+	var keys []string
+	for _, m := range s.GrpcMetadata {
+		if m.Key == "" {
+			continue
+		}
+		md.Append(strings.ToLower(m.Key), m.Value)
+		keys = append(keys, strings.ToLower(m.Key)+"=[redacted]")
+	}
+	log.Debugln(fmt.Sprintf("Service %v sending GRPC metadata: %v", s.Name, keys))
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// grpcReflectionServices will list the services registered on the server with the reflection API
+func grpcReflectionServices(ctx context.Context, conn *grpc.ClientConn) ([]string, error) {
+	stream, err := reflectpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.CloseSend()
+	req := &reflectpb.ServerReflectionRequest{
+		MessageRequest: &reflectpb.ServerReflectionRequest_ListServices{ListServices: "*"},
+	}
+	if err := stream.Send(req); err != nil {
+		return nil, err
+	}
+	res, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if e := res.GetErrorResponse(); e != nil {
+		return nil, fmt.Errorf("code %v, %v", e.GetErrorCode(), e.GetErrorMessage())
+	}
+	var services []string
+	for _, service := range res.GetListServicesResponse().GetService() {
+		services = append(services, service.GetName())
+	}
+	return services, nil
+}
+
+// hasGrpcService returns true if the service name was listed by the reflection API
+func hasGrpcService(services []string, name string) bool {
+	for _, service := range services {
+		if service == name {
+			return true
+		}
+	}
+	return false
+}
+
+// expectedGrpcStatus returns the expected gRPC health check serving status, defaults to SERVING
+// when the ExpectedStatus is not set or is not a valid serving status (eg: HTTP status 200)
+func (s *Service) expectedGrpcStatus() healthpb.HealthCheckResponse_ServingStatus {
+	if _, ok := healthpb.HealthCheckResponse_ServingStatus_name[int32(s.ExpectedStatus)]; !ok || s.ExpectedStatus == 0 {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_ServingStatus(s.ExpectedStatus)
+}
+
 // checkTcp will check a TCP service
 func CheckTcp(s *Service, record bool) (*Service, error) {
 	defer s.updateLastCheck()
@@ -239,66 +934,1489 @@ func CheckTcp(s *Service, record bool) (*Service, error) {
 	dnsLookup, err := dnsCheck(s)
 	if err != nil {
 		if record {
-			RecordFailure(s, fmt.Sprintf("Could not get IP address for TCP service %v, %v", s.Domain, err), "lookup")
+			recordFailure(s, &failures.Failure{
+				Issue:  fmt.Sprintf("Could not get IP address for TCP service %v, %v", s.Domain, err),
+				Reason: "lookup",
+				Error:  err.Error(),
+			})
 		}
 		return s, err
 	}
 	s.PingTime = dnsLookup
+	if s.ExpectClosed.Bool {
+		return checkPortClosed(s, record)
+	}
 	t1 := utils.Now()
-	domain := fmt.Sprintf("%v", s.Domain)
-	if s.Port != 0 {
-		domain = fmt.Sprintf("%v:%v", s.Domain, s.Port)
-		if isIPv6(s.Domain) {
-			domain = fmt.Sprintf("[%v]:%v", s.Domain, s.Port)
+	domain := s.dialAddress()
+
+	tlsConfig, err := s.LoadTLSCert()
+	if err != nil {
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("Invalid TLS Client Certificate, %v", err),
+				Reason:   "tls",
+				Category: failures.CategoryTls,
+				Error:    err.Error(),
+			})
 		}
+		return s, err
 	}
 
-	tlsConfig, err := s.LoadTLSCert()
+	localAddr, err := s.sourceAddr("tcp")
 	if err != nil {
-		log.Errorln(err)
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:  fmt.Sprintf("Could not bind source address %v, %v", s.SourceIP, err),
+				Reason: "source_ip",
+				Error:  err.Error(),
+			})
+		}
+		return s, err
+	}
+	dialer := &net.Dialer{
+		KeepAlive: s.TimeoutDuration(),
+		Timeout:   s.ConnectTimeoutDuration(),
+		Deadline:  t1.Add(s.TimeoutDuration()),
+		LocalAddr: localAddr,
 	}
 
-	// test TCP connection if there is no TLS Certificate set
-	if s.TLSCert.String == "" {
-		conn, err := net.DialTimeout(s.Type, domain, time.Duration(s.Timeout)*time.Second)
-		if err != nil {
-			if record {
-				RecordFailure(s, fmt.Sprintf("Dial Error: %v", err), "tls")
+	ctx, stop := s.checkContext()
+	defer stop()
+
+	if len(s.Ports) > 0 {
+		return checkTcpPorts(ctx, s, record, dialer, tlsConfig, t1)
+	}
+
+	// test TCP connection, the connection is upgraded to TLS if a TLS Certificate was set
+	s.RemoteIP = ""
+	conn, err := dialer.DialContext(ctx, s.ipNetwork(s.Type), domain)
+	if err != nil {
+		if record && ctx.Err() == nil {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("Dial Error: %v", err),
+				Reason:   "tls",
+				Category: errorCategory(err, failures.CategoryConnect),
+				Error:    err.Error(),
+			})
+		}
+		return s, err
+	}
+	defer conn.Close()
+	s.RemoteIP = remoteIP(conn.RemoteAddr().String())
+	// close the connection if the service is stopped while sending or reading
+	defer closeOnCancel(ctx, conn)()
+
+	if s.TLSCert.String != "" {
+		tlsConn := tls.Client(conn, tcpTLSConfig(tlsConfig, s.Domain))
+		tlsConn.SetDeadline(t1.Add(s.TimeoutDuration()))
+		if err := tlsConn.Handshake(); err != nil {
+			if record && ctx.Err() == nil {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("Dial Error: %v", err),
+					Reason:   "tls",
+					Category: errorCategory(err, failures.CategoryTls),
+					Error:    err.Error(),
+				})
 			}
 			return s, err
 		}
-		defer conn.Close()
-	} else {
-		// test TCP connection if TLS Certificate was set
-		dialer := &net.Dialer{
-			KeepAlive: time.Duration(s.Timeout) * time.Second,
-			Timeout:   time.Duration(s.Timeout) * time.Second,
-		}
-		conn, err := tls.DialWithDialer(dialer, s.Type, domain, tlsConfig)
+		conn = tlsConn
+	}
+
+	s.LastResponse = ""
+	if s.PostData.String != "" || s.Expected.String != "" {
+		response, err := tcpExchange(conn, s, t1.Add(s.TimeoutDuration()))
+		s.LastResponse = response
 		if err != nil {
-			if record {
-				RecordFailure(s, fmt.Sprintf("Dial Error: %v", err), "tls")
+			if record && ctx.Err() == nil {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("TCP Error: %v", err),
+					Reason:   "request",
+					Category: errorCategory(err, failures.CategoryConnect),
+					Error:    err.Error(),
+				})
 			}
 			return s, err
 		}
-		defer conn.Close()
+		if s.Expected.String != "" {
+			match, err := s.matchExpected(response)
+			if !match {
+				if record {
+					recordFailure(s, &failures.Failure{
+						Issue:    fmt.Sprintf("TCP Response '%v' did not match '%v'", response, s.Expected.String),
+						Reason:   "regex",
+						Expected: s.Expected.String,
+						Actual:   response,
+					})
+				}
+				return s, err
+			}
+		}
 	}
-
 	s.Latency = utils.Now().Sub(t1).Microseconds()
-	s.LastResponse = ""
-	s.Online = true
+
+	if s.exceedsMaxLatency() {
+		if record {
+			RecordSlowResponse(s)
+		}
+		return s, nil
+	}
+
+	s.Online = true
+	if record {
+		RecordSuccess(s)
+	}
+	return s, nil
+}
+
+// dialAddress returns the domain and port to dial, IPv6 addresses are wrapped in brackets
+func (s *Service) dialAddress() string {
+	if s.Port == 0 {
+		return s.Domain
+	}
+	if isIPv6(s.Domain) {
+		return fmt.Sprintf("[%v]:%v", s.Domain, s.Port)
+	}
+	return fmt.Sprintf("%v:%v", s.Domain, s.Port)
+}
+
+// CheckUdp will send the PostData as a datagram and wait for a response to match the Expected regex
+func CheckUdp(s *Service, record bool) (*Service, error) {
+	defer s.updateLastCheck()
+	timer := prometheus.NewTimer(metrics.ServiceTimer(s.Name))
+	defer timer.ObserveDuration()
+
+	dnsLookup, err := dnsCheck(s)
+	if err != nil {
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:  fmt.Sprintf("Could not get IP address for UDP service %v, %v", s.Domain, err),
+				Reason: "lookup",
+				Error:  err.Error(),
+			})
+		}
+		return s, err
+	}
+	s.PingTime = dnsLookup
+	if s.ExpectClosed.Bool {
+		return checkPortClosed(s, record)
+	}
+	t1 := utils.Now()
+
+	localAddr, err := s.sourceAddr("udp")
+	if err != nil {
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:  fmt.Sprintf("Could not bind source address %v, %v", s.SourceIP, err),
+				Reason: "source_ip",
+				Error:  err.Error(),
+			})
+		}
+		return s, err
+	}
+	dialer := &net.Dialer{
+		Timeout:   s.ConnectTimeoutDuration(),
+		Deadline:  t1.Add(s.TimeoutDuration()),
+		LocalAddr: localAddr,
+	}
+
+	ctx, stop := s.checkContext()
+	defer stop()
+
+	conn, err := dialer.DialContext(ctx, s.ipNetwork(s.Type), s.dialAddress())
+	if err != nil {
+		if record && ctx.Err() == nil {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("Dial Error: %v", err),
+				Reason:   "request",
+				Category: errorCategory(err, failures.CategoryConnect),
+				Error:    err.Error(),
+			})
+		}
+		return s, err
+	}
+	defer conn.Close()
+	defer closeOnCancel(ctx, conn)()
+
+	s.LastResponse = ""
+	response, err := udpExchange(conn, s, t1.Add(s.TimeoutDuration()))
+	if err != nil {
+		if record && ctx.Err() == nil {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("UDP Error: %v", err),
+				Reason:   "request",
+				Category: errorCategory(err, failures.CategoryConnect),
+				Error:    err.Error(),
+			})
+		}
+		return s, err
+	}
+	s.LastResponse = response
+	s.Latency = utils.Now().Sub(t1).Microseconds()
+
+	if s.Expected.String != "" {
+		match, err := s.matchExpected(response)
+		if !match {
+			if record {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("UDP Response '%v' did not match '%v'", response, s.Expected.String),
+					Reason:   "regex",
+					Expected: s.Expected.String,
+					Actual:   response,
+				})
+			}
+			return s, err
+		}
+	}
+
+	if s.exceedsMaxLatency() {
+		if record {
+			RecordSlowResponse(s)
+		}
+		return s, nil
+	}
+
+	s.Online = true
+	if record {
+		RecordSuccess(s)
+	}
+	return s, nil
+}
+
+// checkPortClosed will check that the port of a TCP or UDP service is not listening, the service is online when the
+// connection is refused or times out, such as a port that is filtered by a firewall. A TCP connection or UDP response
+// is a failure. UDP ports are only refused if the host replies with ICMP port unreachable.
+func checkPortClosed(s *Service, record bool) (*Service, error) {
+	t1 := utils.Now()
+	deadline := t1.Add(s.TimeoutDuration())
+	ctx, stop := s.checkContext()
+	defer stop()
+
+	localAddr, err := s.sourceAddr(s.Type)
+	if err != nil {
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:  fmt.Sprintf("Could not bind source address %v, %v", s.SourceIP, err),
+				Reason: "source_ip",
+				Error:  err.Error(),
+			})
+		}
+		return s, err
+	}
+	address := s.dialAddress()
+	_, port, _ := net.SplitHostPort(address)
+	dialer := &net.Dialer{Timeout: s.ConnectTimeoutDuration(), Deadline: deadline, LocalAddr: localAddr}
+	conn, err := dialer.DialContext(ctx, s.ipNetwork(s.Type), address)
+	if err == nil {
+		defer conn.Close()
+		defer closeOnCancel(ctx, conn)()
+		s.RemoteIP = remoteIP(conn.RemoteAddr().String())
+		if s.Type == "udp" {
+			s.LastResponse, err = udpExchange(conn, s, deadline)
+		}
+	}
+	if ctx.Err() != nil {
+		return s, ctx.Err()
+	}
+	s.Latency = utils.Now().Sub(t1).Microseconds()
+
+	var netErr net.Error
+	if err != nil && (errors.Is(err, syscall.ECONNREFUSED) || (errors.As(err, &netErr) && netErr.Timeout())) {
+		s.LastResponse = err.Error()
+		s.Online = true
+		if record {
+			RecordSuccess(s)
+		}
+		return s, nil
+	}
+	if record {
+		if err != nil {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("Could not determine if port %v is closed, %v", port, err),
+				Reason:   "connection",
+				Category: errorCategory(err, failures.CategoryConnect),
+				Error:    err.Error(),
+			})
+		} else {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("%v port %v unexpectedly open", strings.ToUpper(s.Type), port),
+				Reason:   "open_port",
+				Category: failures.CategoryConnect,
+				Expected: "closed",
+				Actual:   "open",
+			})
+		}
+	}
+	return s, err
+}
+
+// udpExchange will write the PostData datagram and read a single datagram in response before the deadline
+func udpExchange(conn net.Conn, s *Service, deadline time.Time) (string, error) {
+	if err := conn.SetDeadline(deadline); err != nil {
+		return "", err
+	}
+	if _, err := conn.Write([]byte(s.postData())); err != nil {
+		return "", err
+	}
+	limit := s.TcpReadLimit
+	if limit <= 0 {
+		limit = defaultTcpReadLimit
+	}
+	buf := make([]byte, limit)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return string(buf[:n]), nil
+}
+
+// tcpTLSConfig returns the TLS config for upgrading a TCP connection, the same as tls.DialWithDialer
+// the ServerName will be the service's domain if it was not set
+func tcpTLSConfig(config *tls.Config, domain string) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	} else {
+		config = config.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = domain
+	}
+	return config
+}
+
+// closeOnCancel will close the connection when the context is cancelled,
+// the returned func must be called to stop watching the context
+func closeOnCancel(ctx context.Context, conn net.Conn) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+	}
+}
+
+// defaultTcpReadLimit is the max amount of bytes read from a TCP response when TcpReadLimit is not set
+const defaultTcpReadLimit = 1024
+
+// tcpExchange will send the service's PostData over the connection and read the response,
+// reading stops at the read limit, when the connection closes, or once the Expected regex matches
+func tcpExchange(conn net.Conn, s *Service, deadline time.Time) (string, error) {
+	if err := conn.SetDeadline(deadline); err != nil {
+		return "", err
+	}
+	if s.PostData.String != "" {
+		if _, err := conn.Write([]byte(s.postData())); err != nil {
+			return "", err
+		}
+	}
+	if s.Expected.String == "" {
+		return "", nil
+	}
+	expected, err := s.expectedRegexp(s.expectedValue())
+	if err != nil {
+		return "", err
+	}
+
+	limit := s.TcpReadLimit
+	if limit <= 0 {
+		limit = defaultTcpReadLimit
+	}
+	var response []byte
+	buf := make([]byte, limit)
+	for len(response) < limit {
+		n, err := conn.Read(buf[:limit-len(response)])
+		response = append(response, buf[:n]...)
+		if expected.Match(response) {
+			break
+		}
+		if err != nil {
+			// a closed connection or timeout after receiving data will use what has been read
+			if len(response) > 0 {
+				break
+			}
+			return "", err
+		}
+	}
+	return string(response), nil
+}
+
+func (s *Service) updateLastCheck() {
+	s.LastCheck = time.Now()
+}
+
+// defaultMaxResponseSize is the max amount of bytes read from a HTTP response when MaxResponseSize is not set
+const defaultMaxResponseSize = 1 << 20
+
+// maxResponseSize returns the max amount of bytes to read from a HTTP response body
+func (s *Service) maxResponseSize() int64 {
+	if s.MaxResponseSize <= 0 {
+		return defaultMaxResponseSize
+	}
+	return s.MaxResponseSize
+}
+
+// preRequest will send the PreRequestURL request before the check, such as to login, and returns the cookie jar
+// with the cookies it set. The request fails if the response status code is 400 or above.
+func (s *Service) preRequest(headers []string, contentType string, timeout time.Duration, verifySSL bool, customTLS *tls.Config, opts utils.HttpOptions) (http.CookieJar, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	method := strings.ToUpper(s.PreRequestMethod)
+	if method == "" {
+		method = http.MethodPost
+	}
+	var data io.Reader
+	var bodyType interface{}
+	if s.PreRequestData.String != "" && method != http.MethodHead {
+		data = strings.NewReader(s.PreRequestData.String)
+		bodyType = contentType
+	}
+	opts.Jar = jar
+	opts.Timing = nil
+	opts.ReadLimit = 0
+	_, res, err := utils.HttpRequestWithOptions(s.PreRequestURL, method, bodyType, headers, data, timeout, verifySSL, customTLS, opts)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 400 {
+		return nil, fmt.Errorf("status code %d", res.StatusCode)
+	}
+	return jar, nil
+}
+
+// checkHttp will check a HTTP service
+func CheckHttp(s *Service, record bool) (*Service, error) {
+	if len(s.Endpoints) > 0 {
+		return checkHttpQuorum(s, record)
+	}
+	// the domain is resolved by the proxy or the custom client when one is set
+	client := s.httpClient()
+	if s.AllResolvedIPs.Bool && s.dialIP == "" && s.Proxy == "" && client == nil {
+		return checkHttpResolvedIPs(s, record)
+	}
+	defer s.updateLastCheck()
+	timer := prometheus.NewTimer(metrics.ServiceTimer(s.Name))
+	defer timer.ObserveDuration()
+
+	if s.Proxy == "" && client == nil && s.dialIP == "" {
+		dnsLookup, err := dnsCheck(s)
+		if err != nil {
+			if record {
+				recordFailure(s, &failures.Failure{
+					Issue:  fmt.Sprintf("Could not get IP address for domain %v, %v", s.Domain, err),
+					Reason: "lookup",
+					Error:  err.Error(),
+				})
+			}
+			return s, err
+		}
+		s.PingTime = dnsLookup
+	}
+	t1 := utils.Now()
+
+	timeout := s.TimeoutDuration()
+	var content []byte
+	var res *http.Response
+	var data io.Reader
+	var headers []string
+	method := strings.ToUpper(s.Method)
+	// any method can send the PostData as the request body, except HEAD which can't have one
+	hasBody := s.PostData.String != "" && method != http.MethodHead
+	contentType := "application/json" // default Content-Type
+	if s.PostDataType != "" {
+		contentType = s.PostDataType
+	}
+
+	headers = s.requestHeaders().strings()
+
+	if auth := s.authorizationHeader(); auth != "" {
+		headers = append(headers, "Authorization="+auth)
+	}
+	log.Debugln(fmt.Sprintf("Service %v sending HTTP request with headers: %v", s.Name, redactHeaders(headers)))
+
+	// an explicit 'Content-Type' header will override the PostDataType
+	for _, header := range headers {
+		keyVal := strings.SplitN(header, "=", 2)
+		if len(keyVal) == 2 && strings.EqualFold(strings.TrimSpace(keyVal[0]), "Content-Type") {
+			contentType = keyVal[1]
+			break
+		}
+	}
+
+	var bodyType interface{}
+	if hasBody {
+		data = strings.NewReader(s.postData())
+		bodyType = contentType
+	}
+
+	customTLS, err := s.LoadTLSCert()
+	if err != nil {
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("Invalid TLS Client Certificate, %v", err),
+				Reason:   "tls",
+				Category: failures.CategoryTls,
+				Error:    err.Error(),
+			})
+		}
+		return s, err
+	}
+
+	localAddr, err := s.sourceAddr("tcp")
+	if err != nil {
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:  fmt.Sprintf("Could not bind source address %v, %v", s.SourceIP, err),
+				Reason: "source_ip",
+				Error:  err.Error(),
+			})
+		}
+		return s, err
+	}
+
+	expected, err := s.expectedResponse()
+	if err != nil {
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("HTTP Expected Response could not be read from %v, %v", s.Expected.String, err),
+				Reason:   "expected",
+				Expected: s.Expected.String,
+				Error:    err.Error(),
+			})
+		}
+		return s, err
+	}
+
+	ctx, stop := s.checkContext()
+	defer stop()
+
+	var bodySize int64
+	var truncated bool
+	opts := utils.HttpOptions{
+		ConnectTimeout:     s.ConnectTimeoutDuration(),
+		FollowRedirects:    s.Redirect.Bool,
+		Context:            ctx,
+		Proxy:              s.Proxy,
+		Timing:             &utils.HttpTiming{},
+		MaxBodySize:        s.maxResponseSize(),
+		MaxRequestSize:     maxRequestSize(),
+		BodySize:           &bodySize,
+		Truncated:          &truncated,
+		ForceH2C:           s.ForceH2C.Bool,
+		UserAgent:          s.userAgent(),
+		HostHeader:         s.HostHeader,
+		ServerName:         s.TLSServerName,
+		SkipHostnameVerify: s.TLSSkipHostname.Bool,
+		LocalAddr:          localAddr,
+		Client:             client,
+		DialIP:             s.dialIP,
+	}
+	if s.ReadLimit > 0 {
+		// streaming responses are only read until the limit or until the expected response is found
+		opts.ReadLimit = int64(s.ReadLimit)
+		if expected != "" && s.ExpectedExact.Bool {
+			opts.ReadUntil, _ = regexp.Compile("^" + regexp.QuoteMeta(expected) + "$")
+		} else if expected != "" {
+			opts.ReadUntil, _ = s.expectedRegexp(expected)
+		}
+	}
+
+	if s.PreRequestURL != "" {
+		jar, err := s.preRequest(headers, contentType, timeout, s.verifyTLS(customTLS), customTLS, opts)
+		if err != nil {
+			if record && ctx.Err() == nil {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("HTTP Pre-Request to %v failed, %v", s.PreRequestURL, err),
+					Reason:   "pre_request",
+					Category: errorCategory(err, failures.CategoryStatus),
+					Error:    err.Error(),
+				})
+			}
+			return s, err
+		}
+		// the session cookies of the pre-request are sent with the check, which is timed on its own
+		opts.Jar = jar
+		t1 = utils.Now()
+	}
+	if len(s.HttpSteps) > 0 {
+		return s.checkHttpSteps(record, headers, contentType, timeout, customTLS, opts, t1)
+	}
+
+	// the checksum is of the whole body, which is only read by the check and not the pre-request or steps
+	opts.Digest = s.checksumDigest()
+	content, res, err = utils.HttpRequestWithOptions(s.Domain, method, bodyType, headers, data, timeout, s.verifyTLS(customTLS), customTLS, opts)
+	s.RemoteIP = remoteIP(opts.Timing.RemoteAddr)
+	if err != nil {
+		if record && ctx.Err() == nil {
+			if utils.IsProxyError(err) {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("HTTP Proxy Error %v", err),
+					Reason:   "proxy",
+					Category: failures.CategoryConnect,
+					Error:    err.Error(),
+				})
+			} else {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("HTTP Error %v", err),
+					Reason:   "request",
+					Category: errorCategory(err, failures.CategoryConnect),
+					Error:    err.Error(),
+				})
+			}
+		}
+		return s, err
+	}
+	s.Latency = utils.Now().Sub(t1).Microseconds()
+	s.responseSize = bodySize
+	s.setLastResponse(content, truncated)
+	s.LastStatusCode = res.StatusCode
+	s.timing = *opts.Timing
+	s.Protocol = res.Proto
+	s.updateTLSExpiry(res)
+	s.updateTLSHealth(res.TLS, s.tlsHost(res))
+
+	metrics.Gauge("status_code", float64(res.StatusCode), s.Name)
+
+	// a maintenance response takes precedence over all the expected response checks below
+	if s.matchMaintenance(res.StatusCode, content) {
+		return s, nil
+	}
+
+	if expected != "" && s.ExpectedExact.Bool {
+		if string(content) != expected {
+			if record {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("HTTP Response Body did not equal the expected response%v", s.expectedReference()),
+					Reason:   "response_body",
+					Expected: expected,
+					Actual:   string(content),
+				})
+			}
+			return s, nil
+		}
+	} else if expected != "" {
+		re, err := s.expectedRegexp(expected)
+		if err != nil {
+			log.Warnln(fmt.Sprintf("Service %v expected: %v is not a valid regex, %v", s.Name, expected, err))
+		}
+		if err != nil || !re.Match(content) {
+			if record {
+				issue := fmt.Sprintf("HTTP Response Body did not match '%v'", expected)
+				if s.ExpectedSource != "" {
+					issue = fmt.Sprintf("HTTP Response Body did not match the expected response%v", s.expectedReference())
+				}
+				recordFailure(s, &failures.Failure{
+					Issue:    issue,
+					Reason:   "regex",
+					Expected: expected,
+					Actual:   string(content),
+				})
+			}
+			return s, err
+		}
+	}
+	if patterns := s.expectedPatterns(); len(patterns) > 0 {
+		if err := matchPatterns(content, patterns, s.ExpectedMatch); err != nil {
+			if record {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("HTTP Response Body %v", err),
+					Reason:   "regex",
+					Expected: s.ExpectedPatterns.String,
+					Actual:   string(content),
+					Error:    err.Error(),
+				})
+			}
+			return s, nil
+		}
+	}
+	if s.ExpectedJSONPath != "" {
+		if err := matchJSONPath(content, s.ExpectedJSONPath, s.ExpectedJSONValue); err != nil {
+			if record {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("HTTP Response %v", err),
+					Reason:   "json_path",
+					Expected: s.ExpectedJSONValue,
+					Error:    err.Error(),
+				})
+			}
+			return s, nil
+		}
+	}
+	if s.ExpectedSchema != "" {
+		if err := s.matchSchema(content); err != nil {
+			if record {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("HTTP Response %v", err),
+					Reason:   "schema",
+					Expected: s.ExpectedSchema,
+					Actual:   string(content),
+					Error:    err.Error(),
+				})
+			}
+			return s, nil
+		}
+	}
+	if s.ExpectedChecksum != "" {
+		if digest, ok := s.matchChecksum(opts.Digest); !ok {
+			if record {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("HTTP Response SHA-256 checksum %v did not match %v", digest, s.ExpectedChecksum),
+					Reason:   "checksum",
+					Expected: s.ExpectedChecksum,
+					Actual:   digest,
+				})
+			}
+			return s, nil
+		}
+	}
+	if ok, err := matchStatusCode(s.expectedStatusCodes(), res.StatusCode); !ok {
+		if record {
+			if err != nil {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("HTTP Status Code %v could not be matched, %v", res.StatusCode, err),
+					Reason:   "status_code",
+					Expected: s.expectedStatusCodes(),
+					Actual:   strconv.Itoa(res.StatusCode),
+					Error:    err.Error(),
+				})
+			} else {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("HTTP Status Code %v did not match %v", res.StatusCode, s.expectedStatusCodes()),
+					Reason:   "status_code",
+					Expected: s.expectedStatusCodes(),
+					Actual:   strconv.Itoa(res.StatusCode),
+				})
+			}
+		}
+		return s, nil
+	}
+	if s.ExpectedHeaders.String != "" {
+		if err := matchHeaders(s.ExpectedHeaders.String, res.Header); err != nil {
+			if record {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("HTTP Response %v", err),
+					Reason:   "header",
+					Expected: s.ExpectedHeaders.String,
+					Error:    err.Error(),
+				})
+			}
+			return s, nil
+		}
+	}
+	if s.ExpectedRedirect != "" && isRedirect(res.StatusCode) {
+		location := res.Header.Get("Location")
+		re, err := s.redirectRegexp()
+		if err != nil {
+			log.Warnln(fmt.Sprintf("Service %v expected redirect: %v is not a valid regex, %v", s.Name, s.ExpectedRedirect, err))
+		}
+		if err != nil || !re.MatchString(location) {
+			if record {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("HTTP Redirect Location '%v' did not match '%v'", location, s.ExpectedRedirect),
+					Reason:   "redirect",
+					Expected: s.ExpectedRedirect,
+					Actual:   location,
+				})
+			}
+			return s, nil
+		}
+	}
+	// the length of the whole decoded body, including the bytes over the MaxResponseSize
+	if s.MinContentLength > 0 && bodySize < int64(s.MinContentLength) {
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("HTTP Response body length %d is less than the minimum %d", bodySize, s.MinContentLength),
+				Reason:   "content_length",
+				Expected: strconv.Itoa(s.MinContentLength),
+				Actual:   strconv.FormatInt(bodySize, 10),
+			})
+		}
+		return s, nil
+	}
+	if s.SSLExpiryWarning > 0 && !s.TLSExpiry.IsZero() && s.TLSExpiresIn < float64(s.SSLExpiryWarning) {
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("SSL Certificate expires in %0.1f days on %v", s.TLSExpiresIn, s.TLSExpiry.Format(time.RFC1123)),
+				Reason:   "ssl_expiry",
+				Expected: fmt.Sprintf("%d days", s.SSLExpiryWarning),
+				Actual:   fmt.Sprintf("%0.1f days", s.TLSExpiresIn),
+			})
+		}
+		return s, err
+	}
+	if s.ExpectedProtocol != "" && res.Proto != httpProtocols[s.ExpectedProtocol] {
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("HTTP Protocol %v did not match %v", res.Proto, httpProtocols[s.ExpectedProtocol]),
+				Reason:   "protocol",
+				Expected: httpProtocols[s.ExpectedProtocol],
+				Actual:   res.Proto,
+			})
+		}
+		return s, nil
+	}
+	if s.MinTLSVersion != "" && res.TLS != nil {
+		if err := checkTLSState(res.TLS, s.MinTLSVersion); err != nil {
+			if record {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("HTTP %v", err),
+					Reason:   "tls",
+					Expected: s.MinTLSVersion,
+					Error:    err.Error(),
+				})
+			}
+			return s, nil
+		}
+	}
+	if s.exceedsMaxLatency() {
+		if record {
+			RecordSlowResponse(s)
+		}
+		return s, err
+	}
+	if record {
+		RecordSuccess(s)
+	}
+	s.Online = true
+	return s, err
+}
+
+// isRedirect returns true if the HTTP status code is a redirect with a Location header
+func isRedirect(statusCode int) bool {
+	switch statusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// authorizationHeader returns the Authorization header value for the service's basic auth or bearer token
+func (s *Service) authorizationHeader() string {
+	if s.BasicAuthUser.String != "" {
+		if s.BearerToken.String != "" {
+			log.Warnln(fmt.Sprintf("Service %v has both basic auth and a bearer token set, using basic auth", s.Name))
+		}
+		creds := s.BasicAuthUser.String + ":" + s.BasicAuthPass.String
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(creds))
+	}
+	if s.BearerToken.String != "" {
+		return "Bearer " + s.BearerToken.String
+	}
+	return ""
+}
+
+// redactHeaders returns the headers with the value of any credential headers hidden, safe for logging
+func redactHeaders(headers []string) []string {
+	var redacted []string
+	for _, header := range headers {
+		keyVal := strings.SplitN(header, "=", 2)
+		if len(keyVal) == 2 {
+			switch strings.ToLower(strings.TrimSpace(keyVal[0])) {
+			case "authorization", "proxy-authorization", "cookie":
+				header = keyVal[0] + "=[redacted]"
+			}
+		}
+		redacted = append(redacted, header)
+	}
+	return redacted
+}
+
+// expectedStatusCodes returns the accepted HTTP status codes expression, the ExpectedStatus is used if
+// ExpectedStatusCodes is not set
+func (s *Service) expectedStatusCodes() string {
+	if strings.TrimSpace(s.ExpectedStatusCodes) != "" {
+		return s.ExpectedStatusCodes
+	}
+	return strconv.Itoa(s.ExpectedStatus)
+}
+
+// matchStatusCode returns true if the status code is accepted by the comma delimited expression,
+// each value can be a status code (200), a class of codes (2xx), or an inclusive range (200-299).
+func matchStatusCode(expected string, code int) (bool, error) {
+	for _, part := range strings.Split(expected, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+		min, max := 0, 0
+		var err error
+		switch {
+		case len(part) == 3 && strings.HasSuffix(part, "xx"):
+			class, err := strconv.Atoi(part[:1])
+			if err != nil {
+				return false, fmt.Errorf("invalid status code class '%v'", part)
+			}
+			min, max = class*100, class*100+99
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			if min, err = strconv.Atoi(strings.TrimSpace(bounds[0])); err != nil {
+				return false, fmt.Errorf("invalid status code range '%v'", part)
+			}
+			if max, err = strconv.Atoi(strings.TrimSpace(bounds[1])); err != nil {
+				return false, fmt.Errorf("invalid status code range '%v'", part)
+			}
+		default:
+			if min, err = strconv.Atoi(part); err != nil {
+				return false, fmt.Errorf("invalid status code '%v'", part)
+			}
+			max = min
+		}
+		if code >= min && code <= max {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// expectedPatterns returns the newline delimited ExpectedPatterns, empty lines are ignored
+func (s *Service) expectedPatterns() []string {
+	var patterns []string
+	for _, p := range strings.Split(s.ExpectedPatterns.String, "\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// matchPatterns will match the body against each regex pattern. With a mode of 'any' one pattern
+// has to match, otherwise all of the patterns have to match. The error names the patterns that failed.
+func matchPatterns(body []byte, patterns []string, mode string) error {
+	var failed []string
+	for _, pattern := range patterns {
+		match, err := regexp.Match(pattern, body)
+		if err != nil {
+			return fmt.Errorf("has an invalid pattern '%v', %v", pattern, err)
+		}
+		if match && mode == "any" {
+			return nil
+		}
+		if !match {
+			failed = append(failed, fmt.Sprintf("'%v'", pattern))
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	if mode == "any" {
+		return fmt.Errorf("did not match any of %v", strings.Join(failed, ", "))
+	}
+	return fmt.Errorf("did not match %v", strings.Join(failed, ", "))
+}
+
+// matchHeaders will compare the comma delimited expected headers (KEY=VALUE,KEY=VALUE) to the response headers,
+// the expected value of a header can be plain text or a regex.
+func matchHeaders(expected string, header http.Header) error {
+	for _, h := range strings.Split(expected, ",") {
+		keyVal := strings.SplitN(h, "=", 2)
+		key := strings.TrimSpace(keyVal[0])
+		if key == "" {
+			continue
+		}
+		values, ok := header[http.CanonicalHeaderKey(key)]
+		if !ok {
+			return fmt.Errorf("header '%v' was missing", key)
+		}
+		if len(keyVal) < 2 {
+			continue
+		}
+		pattern := strings.TrimSpace(keyVal[1])
+		value := strings.Join(values, ", ")
+		match, err := regexp.MatchString(pattern, value)
+		if err != nil {
+			return fmt.Errorf("header '%v' has an invalid expected value '%v', %v", key, pattern, err)
+		}
+		if !match {
+			return fmt.Errorf("header '%v' value '%v' did not match '%v'", key, value, pattern)
+		}
+	}
+	return nil
+}
+
+// jsonPathValue will return the value at the JSON path, such as '$.data.items[0].status', in the body.
+// Strings are returned as is, other values are returned in their JSON encoding.
+func jsonPathValue(body []byte, path string) (string, error) {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return "", fmt.Errorf("response body is not valid JSON, %v", err)
+	}
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	for path != "" {
+		switch path[0] {
+		case '.':
+			path = path[1:]
+			end := strings.IndexAny(path, ".[")
+			if end == -1 {
+				end = len(path)
+			}
+			key := path[:end]
+			path = path[end:]
+			obj, ok := value.(map[string]interface{})
+			if !ok {
+				return "", fmt.Errorf("'%v' is not an object", key)
+			}
+			if value, ok = obj[key]; !ok {
+				return "", fmt.Errorf("key '%v' was missing", key)
+			}
+		case '[':
+			end := strings.Index(path, "]")
+			if end == -1 {
+				return "", fmt.Errorf("missing ']' in path")
+			}
+			index, err := strconv.Atoi(path[1:end])
+			if err != nil {
+				return "", fmt.Errorf("invalid index '%v'", path[1:end])
+			}
+			path = path[end+1:]
+			arr, ok := value.([]interface{})
+			if !ok {
+				return "", fmt.Errorf("index %d is not in an array", index)
+			}
+			if index < 0 || index >= len(arr) {
+				return "", fmt.Errorf("index %d is out of range", index)
+			}
+			value = arr[index]
+		default:
+			return "", fmt.Errorf("invalid path near '%v'", path)
+		}
+	}
+	if str, ok := value.(string); ok {
+		return str, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// matchJSONPath will compare the value at the JSON path in the body to the expected value,
+// the expected value can be plain text or a regex that must match the whole value.
+func matchJSONPath(body []byte, path, expected string) error {
+	value, err := jsonPathValue(body, path)
+	if err != nil {
+		return fmt.Errorf("JSON path '%v' could not be evaluated, %v", path, err)
+	}
+	if value == expected {
+		return nil
+	}
+	match, err := regexp.MatchString("^(?:"+expected+")$", value)
+	if err != nil || !match {
+		return fmt.Errorf("JSON path '%v' value '%v' did not match '%v'", path, value, expected)
+	}
+	return nil
+}
+
+// updateTLSExpiry will set the expiration of the leaf certificate from a HTTPS response
+func (s *Service) updateTLSExpiry(res *http.Response) {
+	if res.TLS == nil || len(res.TLS.PeerCertificates) == 0 {
+		s.TLSExpiry = time.Time{}
+		s.TLSExpiresIn = 0
+		return
+	}
+	s.TLSExpiry = res.TLS.PeerCertificates[0].NotAfter
+	s.TLSExpiresIn = s.TLSExpiry.Sub(utils.Now()).Hours() / 24
+}
+
+// tlsHost returns the host the certificate of a HTTPS response is verified for, the TLSServerName if it was set
+func (s *Service) tlsHost(res *http.Response) string {
+	if s.TLSServerName != "" {
+		return s.TLSServerName
+	}
+	if res.Request == nil {
+		return ""
+	}
+	return res.Request.URL.Hostname()
+}
+
+// httpProtocols are the accepted values for ExpectedProtocol and the protocol of the responses
+var httpProtocols = map[string]string{
+	"1.0": "HTTP/1.0",
+	"1.1": "HTTP/1.1",
+	"2":   "HTTP/2.0",
+}
+
+// tlsVersions are the accepted values for MinTLSVersion
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsVersionName returns the MinTLSVersion name of a negotiated TLS version
+func tlsVersionName(version uint16) string {
+	for name, v := range tlsVersions {
+		if v == version {
+			return "TLS " + name
+		}
+	}
+	return fmt.Sprintf("unknown TLS version 0x%04x", version)
+}
+
+// checkTLSState returns an error if the negotiated TLS version is below the minimum version
+// or if the negotiated cipher suite is considered insecure
+func checkTLSState(state *tls.ConnectionState, minVersion string) error {
+	min, ok := tlsVersions[minVersion]
+	if !ok {
+		return fmt.Errorf("minimum TLS version '%v' is not 1.0, 1.1, 1.2 or 1.3", minVersion)
+	}
+	if state.Version < min {
+		return fmt.Errorf("negotiated %v is below the minimum TLS %v", tlsVersionName(state.Version), minVersion)
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		if suite.ID == state.CipherSuite {
+			return fmt.Errorf("negotiated insecure cipher suite %v", suite.Name)
+		}
+	}
+	return nil
+}
+
+// CheckSmtp will check a SMTP mail server by reading the greeting banner and sending EHLO,
+// STARTTLS is required and the certificate is verified when VerifySSL is set.
+func CheckSmtp(s *Service, record bool) (*Service, error) {
+	defer s.updateLastCheck()
+	timer := prometheus.NewTimer(metrics.ServiceTimer(s.Name))
+	defer timer.ObserveDuration()
+
+	dnsLookup, err := dnsCheck(s)
+	if err != nil {
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:  fmt.Sprintf("Could not get IP address for SMTP service %v, %v", s.Domain, err),
+				Reason: "lookup",
+				Error:  err.Error(),
+			})
+		}
+		return s, err
+	}
+	s.PingTime = dnsLookup
+
+	port := s.Port
+	if port == 0 {
+		port = 25
+	}
+	t1 := utils.Now()
+	deadline := t1.Add(s.TimeoutDuration())
+	ctx, stop := s.checkContext()
+	defer stop()
+
+	dialer := &net.Dialer{Timeout: s.ConnectTimeoutDuration(), Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, s.ipNetwork("tcp"), net.JoinHostPort(s.Domain, strconv.Itoa(port)))
+	if err != nil {
+		if record && ctx.Err() == nil {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("SMTP Dial Error %v", err),
+				Reason:   "connection",
+				Category: errorCategory(err, failures.CategoryConnect),
+				Error:    err.Error(),
+			})
+		}
+		return s, err
+	}
+	defer conn.Close()
+	defer closeOnCancel(ctx, conn)()
+	// the timeout is used as a deadline for the whole conversation
+	conn.SetDeadline(deadline)
+
+	text := textproto.NewConn(conn)
+	_, banner, err := text.ReadResponse(220)
+	s.LastResponse = banner
+	if err != nil {
+		if record && ctx.Err() == nil {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("SMTP Banner Error %v", err),
+				Reason:   "response_code",
+				Category: errorCategory(err, failures.CategoryStatus),
+				Error:    err.Error(),
+			})
+		}
+		return s, err
+	}
+
+	extensions, err := smtpCommand(text, 250, "EHLO statping")
+	if err != nil {
+		if record && ctx.Err() == nil {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("SMTP EHLO Error %v", err),
+				Reason:   "response_code",
+				Category: errorCategory(err, failures.CategoryStatus),
+				Error:    err.Error(),
+			})
+		}
+		return s, err
+	}
+
+	if s.VerifySSL.Bool {
+		if !strings.Contains(strings.ToUpper(extensions), "STARTTLS") {
+			err = fmt.Errorf("server does not support STARTTLS")
+		} else if _, err = smtpCommand(text, 220, "STARTTLS"); err == nil {
+			tlsConn := tls.Client(conn, &tls.Config{ServerName: s.Domain})
+			if err = tlsConn.Handshake(); err == nil {
+				text = textproto.NewConn(tlsConn)
+			}
+		}
+		if err != nil {
+			if record && ctx.Err() == nil {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("SMTP STARTTLS Error %v", err),
+					Reason:   "tls",
+					Category: errorCategory(err, failures.CategoryTls),
+					Error:    err.Error(),
+				})
+			}
+			return s, err
+		}
+	}
+	smtpCommand(text, 221, "QUIT")
+
+	s.Latency = utils.Now().Sub(t1).Microseconds()
+	s.Online = true
+	if record {
+		RecordSuccess(s)
+	}
+	return s, nil
+}
+
+// smtpCommand will send the SMTP command and return the response message if it has the expected code
+func smtpCommand(text *textproto.Conn, expectCode int, command string) (string, error) {
+	id, err := text.Cmd(command)
+	if err != nil {
+		return "", err
+	}
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+	_, msg, err := text.ReadResponse(expectCode)
+	return msg, err
+}
+
+// CheckMailbox will check an IMAP or POP3 mail server by reading the greeting, and logging in when the
+// basic auth credentials are set. The connection uses TLS with VerifySSL or on the IMAPS and POP3S ports,
+// the certificate is only verified with VerifySSL.
+func CheckMailbox(s *Service, record bool) (*Service, error) {
+	defer s.updateLastCheck()
+	timer := prometheus.NewTimer(metrics.ServiceTimer(s.Name))
+	defer timer.ObserveDuration()
+	protocol := strings.ToUpper(s.Type)
+
+	dnsLookup, err := dnsCheck(s)
+	if err != nil {
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:  fmt.Sprintf("Could not get IP address for %v service %v, %v", protocol, s.Domain, err),
+				Reason: "lookup",
+				Error:  err.Error(),
+			})
+		}
+		return s, err
+	}
+	s.PingTime = dnsLookup
+
+	port, useTLS := s.mailboxPort()
+	var tlsConfig *tls.Config
+	if useTLS {
+		tlsConfig = &tls.Config{ServerName: s.Domain, InsecureSkipVerify: !s.VerifySSL.Bool}
+		customTLS, err := s.LoadTLSCert()
+		if err != nil {
+			if record {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("Invalid TLS Client Certificate, %v", err),
+					Reason:   "tls",
+					Category: failures.CategoryTls,
+					Error:    err.Error(),
+				})
+			}
+			return s, err
+		}
+		if customTLS != nil {
+			tlsConfig.RootCAs = customTLS.RootCAs
+			tlsConfig.Certificates = customTLS.Certificates
+		}
+	}
+
+	t1 := utils.Now()
+	deadline := t1.Add(s.TimeoutDuration())
+	ctx, stop := s.checkContext()
+	defer stop()
+
+	dialer := &net.Dialer{Timeout: s.ConnectTimeoutDuration(), Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, s.ipNetwork("tcp"), net.JoinHostPort(s.Domain, strconv.Itoa(port)))
+	if err != nil {
+		if record && ctx.Err() == nil {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("%v Dial Error %v", protocol, err),
+				Reason:   "connection",
+				Category: errorCategory(err, failures.CategoryConnect),
+				Error:    err.Error(),
+			})
+		}
+		return s, err
+	}
+	defer conn.Close()
+	defer closeOnCancel(ctx, conn)()
+	// the timeout is used as a deadline for the whole conversation
+	conn.SetDeadline(deadline)
+
+	if tlsConfig != nil {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			if record && ctx.Err() == nil {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("%v TLS Error %v", protocol, err),
+					Reason:   "tls",
+					Category: errorCategory(err, failures.CategoryTls),
+					Error:    err.Error(),
+				})
+			}
+			return s, err
+		}
+		conn = tlsConn
+	}
+
+	text := textproto.NewConn(conn)
+	greeting, err := mailboxGreeting(text, s.Type)
+	s.LastResponse = greeting
+	if err != nil {
+		if record && ctx.Err() == nil {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("%v Greeting Error %v", protocol, err),
+				Reason:   "response_code",
+				Category: errorCategory(err, failures.CategoryStatus),
+				Error:    err.Error(),
+			})
+		}
+		return s, err
+	}
+
+	if s.BasicAuthUser.String != "" {
+		if err := mailboxLogin(text, s.Type, s.BasicAuthUser.String, s.BasicAuthPass.String); err != nil {
+			if record && ctx.Err() == nil {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("%v Login Error %v", protocol, err),
+					Reason:   "auth",
+					Category: errorCategory(err, failures.CategoryStatus),
+					Error:    err.Error(),
+				})
+			}
+			return s, err
+		}
+	}
+	s.Latency = utils.Now().Sub(t1).Microseconds()
+	mailboxLogout(text, s.Type)
+
+	s.Online = true
+	if record {
+		RecordSuccess(s)
+	}
+	return s, nil
+}
+
+// CheckNtp will query the NTP server of the service, the latency is the round trip of the request and the
+// clock offset of the server is saved as the last response. The service fails if the offset is over the
+// MaxClockOffset.
+func CheckNtp(s *Service, record bool) (*Service, error) {
+	defer s.updateLastCheck()
+	timer := prometheus.NewTimer(metrics.ServiceTimer(s.Name))
+	defer timer.ObserveDuration()
+
+	dnsLookup, err := dnsCheck(s)
+	if err != nil {
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:  fmt.Sprintf("Could not get IP address for NTP server %v, %v", s.Domain, err),
+				Reason: "lookup",
+				Error:  err.Error(),
+			})
+		}
+		return s, err
+	}
+	s.PingTime = dnsLookup
+
+	localAddr, err := s.sourceAddr("udp")
+	if err != nil {
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:  fmt.Sprintf("Could not bind source address %v, %v", s.SourceIP, err),
+				Reason: "source_ip",
+				Error:  err.Error(),
+			})
+		}
+		return s, err
+	}
+	t1 := utils.Now()
+	deadline := t1.Add(s.TimeoutDuration())
+	ctx, stop := s.checkContext()
+	defer stop()
+
+	dialer := &net.Dialer{Timeout: s.ConnectTimeoutDuration(), Deadline: deadline, LocalAddr: localAddr}
+	conn, err := dialer.DialContext(ctx, s.ipNetwork("udp"), s.ntpAddress())
+	if err != nil {
+		if record && ctx.Err() == nil {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("NTP Dial Error %v", err),
+				Reason:   "connection",
+				Category: errorCategory(err, failures.CategoryConnect),
+				Error:    err.Error(),
+			})
+		}
+		return s, err
+	}
+	defer conn.Close()
+	defer closeOnCancel(ctx, conn)()
+	conn.SetDeadline(deadline)
+
+	s.LastResponse = ""
+	result, err := ntpQuery(conn)
+	if err != nil {
+		if record && ctx.Err() == nil {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("NTP Error %v", err),
+				Reason:   "response_code",
+				Category: errorCategory(err, failures.CategoryStatus),
+				Error:    err.Error(),
+			})
+		}
+		return s, err
+	}
+	s.Latency = result.Delay.Microseconds()
+	s.LastResponse = result.Offset.String()
+
+	if s.exceedsClockOffset(result.Offset) {
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("NTP server clock offset of %v is over %0.2fs", result.Offset, s.MaxClockOffset),
+				Reason:   "clock_offset",
+				Expected: fmt.Sprintf("%0.2fs", s.MaxClockOffset),
+				Actual:   result.Offset.String(),
+			})
+		}
+		return s, nil
+	}
+
+	if s.exceedsMaxLatency() {
+		if record {
+			RecordSlowResponse(s)
+		}
+		return s, nil
+	}
+
+	s.Online = true
 	if record {
 		RecordSuccess(s)
 	}
 	return s, nil
 }
 
-func (s *Service) updateLastCheck() {
-	s.LastCheck = time.Now()
-}
-
-// checkHttp will check a HTTP service
-func CheckHttp(s *Service, record bool) (*Service, error) {
+// CheckMqtt will check a MQTT broker by connecting with the optional credentials, the broker is online once
+// the connection is accepted. The check can subscribe to MqttSubscribe, publish PostData to MqttPublish and
+// compare the first received message to the expected value.
+func CheckMqtt(s *Service, record bool) (*Service, error) {
 	defer s.updateLastCheck()
 	timer := prometheus.NewTimer(metrics.ServiceTimer(s.Name))
 	defer timer.ObserveDuration()
@@ -306,135 +2424,520 @@ func CheckHttp(s *Service, record bool) (*Service, error) {
 	dnsLookup, err := dnsCheck(s)
 	if err != nil {
 		if record {
-			RecordFailure(s, fmt.Sprintf("Could not get IP address for domain %v, %v", s.Domain, err), "lookup")
+			recordFailure(s, &failures.Failure{
+				Issue:  fmt.Sprintf("Could not get IP address for MQTT broker %v, %v", s.Domain, err),
+				Reason: "lookup",
+				Error:  err.Error(),
+			})
 		}
 		return s, err
 	}
 	s.PingTime = dnsLookup
+
+	var tlsConfig *tls.Config
+	if s.VerifySSL.Bool {
+		tlsConfig = &tls.Config{ServerName: s.Domain}
+		customTLS, err := s.LoadTLSCert()
+		if err != nil {
+			if record {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("Invalid TLS Client Certificate, %v", err),
+					Reason:   "tls",
+					Category: failures.CategoryTls,
+					Error:    err.Error(),
+				})
+			}
+			return s, err
+		}
+		if customTLS != nil {
+			tlsConfig.RootCAs = customTLS.RootCAs
+			tlsConfig.Certificates = customTLS.Certificates
+		}
+	}
+
+	port := s.Port
+	if port == 0 {
+		port = 1883
+		if tlsConfig != nil {
+			port = 8883
+		}
+	}
 	t1 := utils.Now()
+	deadline := t1.Add(s.TimeoutDuration())
+	ctx, stop := s.checkContext()
+	defer stop()
 
-	timeout := time.Duration(s.Timeout) * time.Second
-	var content []byte
-	var res *http.Response
-	var data *bytes.Buffer
-	var headers []string
-	contentType := "application/json" // default Content-Type
+	dialer := &net.Dialer{Timeout: s.ConnectTimeoutDuration(), Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, s.ipNetwork("tcp"), net.JoinHostPort(s.Domain, strconv.Itoa(port)))
+	if err != nil {
+		if record && ctx.Err() == nil {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("MQTT Dial Error %v", err),
+				Reason:   "connection",
+				Category: errorCategory(err, failures.CategoryConnect),
+				Error:    err.Error(),
+			})
+		}
+		return s, err
+	}
+	defer conn.Close()
+	defer closeOnCancel(ctx, conn)()
+	// the timeout is used as a deadline for the whole conversation
+	conn.SetDeadline(deadline)
 
-	if s.Headers.Valid {
-		headers = strings.Split(s.Headers.String, ",")
-	} else {
-		headers = nil
+	if tlsConfig != nil {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			if record && ctx.Err() == nil {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("MQTT TLS Error %v", err),
+					Reason:   "tls",
+					Category: errorCategory(err, failures.CategoryTls),
+					Error:    err.Error(),
+				})
+			}
+			return s, err
+		}
+		conn = tlsConn
 	}
+	reader := bufio.NewReader(conn)
 
-	// check if 'Content-Type' header was defined
-	for _, header := range headers {
-		if strings.Split(header, "=")[0] == "Content-Type" {
-			contentType = strings.Split(header, "=")[1]
-			break
+	clientId := fmt.Sprintf("statping-%d-%d", s.Id, rand.Intn(1000000))
+	connect := mqttConnectPacket(clientId, s.BasicAuthUser.String, s.BasicAuthPass.String, 60)
+	var connack *mqttPacket
+	if _, err = conn.Write(connect); err == nil {
+		if connack, err = mqttRead(reader); err == nil {
+			err = mqttConnackError(connack)
+		}
+	}
+	if err != nil {
+		if record && ctx.Err() == nil {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("MQTT Connect Error %v", err),
+				Reason:   "connection",
+				Category: errorCategory(err, failures.CategoryConnect),
+				Error:    err.Error(),
+			})
+		}
+		return s, err
+	}
+	s.Latency = utils.Now().Sub(t1).Microseconds()
+	s.LastResponse = "CONNACK accepted"
+
+	if s.MqttSubscribe != "" {
+		const packetId = 1
+		if _, err = conn.Write(mqttSubscribePacket(packetId, s.MqttSubscribe)); err == nil {
+			var suback *mqttPacket
+			// the broker can send retained messages of other sessions before the SUBACK
+			for {
+				if suback, err = mqttRead(reader); err != nil || suback.header&0xf0 != mqttPublish {
+					break
+				}
+			}
+			if err == nil {
+				err = mqttSubackError(suback, packetId)
+			}
+		}
+		if err != nil {
+			if record && ctx.Err() == nil {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("MQTT Subscribe Error %v", err),
+					Reason:   "subscribe",
+					Category: errorCategory(err, failures.CategoryConnect),
+					Error:    err.Error(),
+				})
+			}
+			return s, err
 		}
+		s.LastResponse += fmt.Sprintf(", SUBACK %v", s.MqttSubscribe)
 	}
 
-	if s.Redirect.Bool {
-		headers = append(headers, "Redirect=true")
+	if s.MqttPublish != "" {
+		if _, err := conn.Write(mqttPublishPacket(s.MqttPublish, s.postData())); err != nil {
+			if record && ctx.Err() == nil {
+				recordFailure(s, &failures.Failure{
+					Issue:  fmt.Sprintf("MQTT Publish Error %v", err),
+					Reason: "write",
+					Error:  err.Error(),
+				})
+			}
+			return s, err
+		}
 	}
 
-	if s.PostData.String != "" {
-		data = bytes.NewBuffer([]byte(s.PostData.String))
-	} else {
-		data = bytes.NewBuffer(nil)
+	if s.MqttSubscribe != "" && s.Expected.String != "" {
+		var message string
+		for {
+			var packet *mqttPacket
+			if packet, err = mqttRead(reader); err != nil {
+				break
+			}
+			if packet.header&0xf0 == mqttPublish {
+				_, message, err = mqttMessage(packet)
+				break
+			}
+		}
+		if err != nil {
+			if record && ctx.Err() == nil {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("MQTT Read Error %v", err),
+					Reason:   "read",
+					Category: errorCategory(err, failures.CategoryConnect),
+					Error:    err.Error(),
+				})
+			}
+			return s, err
+		}
+		s.LastResponse = message
+
+		match, err := s.matchExpected(s.LastResponse)
+		if !match {
+			if record {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("MQTT Message '%v' did not match '%v'", s.LastResponse, s.Expected.String),
+					Reason:   "regex",
+					Expected: s.Expected.String,
+					Actual:   s.LastResponse,
+				})
+			}
+			return s, err
+		}
 	}
+	conn.Write(mqttEncode(mqttDisconnect, nil))
 
-	// force set Content-Type to 'application/json' if requests are made
-	// with POST method
-	if s.Method == "POST" && contentType != "application/json" {
-		contentType = "application/json"
+	s.Online = true
+	if record {
+		RecordSuccess(s)
 	}
+	return s, nil
+}
 
-	customTLS, err := s.LoadTLSCert()
+// CheckWebsocket will check a websocket service by upgrading the connection, sending the optional PostData
+// and comparing the first received message to the expected value
+func CheckWebsocket(s *Service, record bool) (*Service, error) {
+	defer s.updateLastCheck()
+	timer := prometheus.NewTimer(metrics.ServiceTimer(s.Name))
+	defer timer.ObserveDuration()
+
+	dnsLookup, err := dnsCheck(s)
 	if err != nil {
-		log.Errorln(err)
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:  fmt.Sprintf("Could not get IP address for websocket %v, %v", s.Domain, err),
+				Reason: "lookup",
+				Error:  err.Error(),
+			})
+		}
+		return s, err
 	}
+	s.PingTime = dnsLookup
 
-	content, res, err = utils.HttpRequest(s.Domain, s.Method, contentType, headers, data, timeout, s.VerifySSL.Bool, customTLS)
+	tlsConfig := &tls.Config{InsecureSkipVerify: !s.VerifySSL.Bool}
+	customTLS, err := s.LoadTLSCert()
 	if err != nil {
 		if record {
-			RecordFailure(s, fmt.Sprintf("HTTP Error %v", err), "request")
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("Invalid TLS Client Certificate, %v", err),
+				Reason:   "tls",
+				Category: failures.CategoryTls,
+				Error:    err.Error(),
+			})
+		}
+		return s, err
+	}
+	if customTLS != nil {
+		tlsConfig.InsecureSkipVerify = !s.verifyTLS(customTLS)
+		tlsConfig.RootCAs = customTLS.RootCAs
+		tlsConfig.Certificates = customTLS.Certificates
+	}
+
+	netDialer := &net.Dialer{Timeout: s.ConnectTimeoutDuration()}
+	dialer := &websocket.Dialer{
+		NetDialContext:   netDialer.DialContext,
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: s.TimeoutDuration(),
+		TLSClientConfig:  tlsConfig,
+	}
+
+	header := http.Header{}
+	for _, h := range s.requestHeaders() {
+		header.Set(h.Key, h.Value)
+	}
+
+	// the timeout is used as a deadline for the whole exchange
+	t1 := utils.Now()
+	checkCtx, stop := s.checkContext()
+	defer stop()
+	ctx, cancel := context.WithDeadline(checkCtx, t1.Add(s.TimeoutDuration()))
+	defer cancel()
+
+	conn, res, err := dialer.DialContext(ctx, s.Domain, header)
+	if res != nil {
+		s.LastStatusCode = res.StatusCode
+	}
+	if err != nil {
+		if record && checkCtx.Err() == nil {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("Websocket Dial Error %v", err),
+				Reason:   "connection",
+				Category: errorCategory(err, failures.CategoryConnect),
+				Error:    err.Error(),
+			})
 		}
 		return s, err
 	}
+	defer conn.Close()
+	defer closeOnCancel(checkCtx, conn.UnderlyingConn())()
 	s.Latency = utils.Now().Sub(t1).Microseconds()
-	s.LastResponse = string(content)
-	s.LastStatusCode = res.StatusCode
+	s.LastResponse = ""
 
-	metrics.Gauge("status_code", float64(res.StatusCode), s.Name)
+	deadline, _ := ctx.Deadline()
+	conn.SetWriteDeadline(deadline)
+	conn.SetReadDeadline(deadline)
+
+	if s.PostData.String != "" {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(s.postData())); err != nil {
+			if record && checkCtx.Err() == nil {
+				recordFailure(s, &failures.Failure{
+					Issue:  fmt.Sprintf("Websocket Write Error %v", err),
+					Reason: "write",
+					Error:  err.Error(),
+				})
+			}
+			return s, err
+		}
+	}
 
 	if s.Expected.String != "" {
-		match, err := regexp.MatchString(s.Expected.String, string(content))
+		_, message, err := conn.ReadMessage()
 		if err != nil {
-			log.Warnln(fmt.Sprintf("Service %v expected: %v to match %v", s.Name, string(content), s.Expected.String))
+			if record && checkCtx.Err() == nil {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("Websocket Read Error %v", err),
+					Reason:   "read",
+					Category: errorCategory(err, failures.CategoryConnect),
+					Error:    err.Error(),
+				})
+			}
+			return s, err
 		}
+		s.LastResponse = string(message)
+
+		match, err := s.matchExpected(s.LastResponse)
 		if !match {
 			if record {
-				RecordFailure(s, fmt.Sprintf("HTTP Response Body did not match '%v'", s.Expected), "regex")
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("Websocket Message '%v' did not match '%v'", s.LastResponse, s.Expected.String),
+					Reason:   "regex",
+					Expected: s.Expected.String,
+					Actual:   s.LastResponse,
+				})
 			}
 			return s, err
 		}
 	}
-	if s.ExpectedStatus != res.StatusCode {
-		if record {
-			RecordFailure(s, fmt.Sprintf("HTTP Status Code %v did not match %v", res.StatusCode, s.ExpectedStatus), "status_code")
-		}
-		return s, err
-	}
+
+	s.Online = true
 	if record {
 		RecordSuccess(s)
 	}
-	s.Online = true
-	return s, err
+	return s, nil
 }
 
 // RecordSuccess will create a new 'hit' record in the database for a successful/online service
 func RecordSuccess(s *Service) {
+	if s.probed != nil {
+		s.probed.online = true
+		return
+	}
+	wasOnline := s.Online
 	s.LastOnline = utils.Now()
 	s.Online = true
-	hit := &hits.Hit{
-		Service:   s.Id,
-		Latency:   s.Latency,
-		PingTime:  s.PingTime,
-		CreatedAt: utils.Now(),
+	s.warmupUntil = time.Time{}
+	s.CurrentFailureCount = 0
+	s.recordResponse(true)
+	if reason := s.latencyAnomaly(s.Latency); reason != "" {
+		s.markDegraded(reason)
+	}
+	previousStatus := s.setStatus(s.successStatus())
+	var hit *hits.Hit
+	if !wasOnline || s.sampleHit(s.LastOnline) {
+		hit = createHit(s)
+	} else {
+		hit = trackHit(s, newHit(s))
+	}
+	log.WithFields(utils.ToFields(hit, s)).Infoln(
+		fmt.Sprintf("Service #%d '%v' Successful Response: %s | Lookup in: %s | Online: %v | Interval: %v", s.Id, s.Name, humanMicro(hit.Latency), s.lookupText(hit.PingTime), s.Online, s.Duration()))
+	metrics.Gauge("online", 1., s.Name, s.Type)
+	metrics.Gauge("up", 1., s.Id, s.Name)
+	metrics.Inc("success", s.Name)
+	recovered := !wasOnline && !s.offlineSince.IsZero()
+	if s.updateFlapping(s.LastOnline, recovered) {
+		sendFlapping(s)
+	}
+	var downtime time.Duration
+	if recovered {
+		downtime = s.LastOnline.Sub(s.offlineSince)
+		s.offlineSince = time.Time{}
+		sendRecover(s, downtime)
 	}
+	s.smoothLatency(hit.Latency, downtime)
+	s.resetThrottle()
+	s.notifyStatus(previousStatus)
+	publishEvent(s.checkEvent("", s.LastOnline))
+	sendSuccess(s, recovered)
+	if s.responseOnFailure() {
+		s.LastResponse = ""
+	}
+}
+
+// createHit will insert a new 'hit' record with the latency of the last check
+func createHit(s *Service) *hits.Hit {
+	hit := newHit(s)
 	if err := hit.Create(); err != nil {
 		log.Error(err)
+	} else {
+		s.trackLatency(hit)
 	}
-	log.WithFields(utils.ToFields(hit, s)).Infoln(
-		fmt.Sprintf("Service #%d '%v' Successful Response: %s | Lookup in: %s | Online: %v | Interval: %d seconds", s.Id, s.Name, humanMicro(hit.Latency), humanMicro(hit.PingTime), s.Online, s.Interval))
+	s.hitsSkipped = 0
+	s.lastHitSaved = hit.CreatedAt
+	return trackHit(s, hit)
+}
+
+// newHit returns a 'hit' with the latency and timings of the last check without inserting it
+func newHit(s *Service) *hits.Hit {
+	return &hits.Hit{
+		Service:    s.Id,
+		Latency:    s.Latency,
+		PingTime:   s.PingTime,
+		PacketLoss: s.PacketLoss,
+		DNS:        s.timing.DNS.Microseconds(),
+		Connect:    s.timing.Connect.Microseconds(),
+		TLS:        s.timing.TLS.Microseconds(),
+		FirstByte:  s.timing.FirstByte.Microseconds(),
+		Size:       s.responseSize,
+		Throughput: throughput(s.responseSize, s.Latency),
+		Protocol:   s.Protocol,
+		RemoteIP:   s.RemoteIP,
+		Status:     s.Status,
+		CreatedAt:  utils.Now(),
+	}
+}
+
+// throughput returns the bytes per second of a response of size bytes downloaded in latency microseconds
+func throughput(size, latency int64) float64 {
+	if size <= 0 || latency <= 0 {
+		return 0
+	}
+	return float64(size) / (time.Duration(latency) * time.Microsecond).Seconds()
+}
+
+// trackHit will update the in-memory latency of the service with the hit
+func trackHit(s *Service, hit *hits.Hit) *hits.Hit {
 	s.LastLookupTime = hit.PingTime
 	s.LastLatency = hit.Latency
-	metrics.Gauge("online", 1., s.Name, s.Type)
-	metrics.Inc("success", s.Name)
-	sendSuccess(s)
+	metrics.Gauge("latency", (time.Duration(hit.Latency) * time.Microsecond).Seconds(), s.Id, s.Name)
+	return hit
+}
+
+// sampleHit returns true if the successful check at now should be inserted as a 'hit', only every
+// HitSampleRate success is inserted and never more than once within HitMinInterval seconds
+func (s *Service) sampleHit(now time.Time) bool {
+	s.hitsSkipped++
+	if s.HitSampleRate > 1 && s.hitsSkipped < s.HitSampleRate {
+		return false
+	}
+	minInterval := time.Duration(s.HitMinInterval) * time.Second
+	if minInterval > 0 && !s.lastHitSaved.IsZero() && now.Sub(s.lastHitSaved) < minInterval {
+		return false
+	}
+	return true
+}
+
+// exceedsMaxLatency returns true if the latency of the last check is over the MaxLatency threshold
+func (s *Service) exceedsMaxLatency() bool {
+	if s.MaxLatency <= 0 {
+		return false
+	}
+	return time.Duration(s.Latency)*time.Microsecond > time.Duration(s.MaxLatency*float64(time.Second))
+}
+
+// RecordSlowResponse will create a 'hit' record for the latency graph and a 'Failure' for exceeding MaxLatency
+func RecordSlowResponse(s *Service) {
+	if s.probed == nil {
+		createHit(s)
+	}
+	latency := (time.Duration(s.Latency) * time.Microsecond).Seconds()
+	recordFailure(s, &failures.Failure{
+		Issue:    fmt.Sprintf("Response time %0.2fs exceeded threshold %0.2fs", latency, s.MaxLatency),
+		Reason:   "latency",
+		Expected: fmt.Sprintf("%0.2fs", s.MaxLatency),
+		Actual:   fmt.Sprintf("%0.2fs", latency),
+	})
 }
 
 // RecordFailure will create a new 'Failure' record in the database for a offline service
 func RecordFailure(s *Service, issue, reason string) {
+	recordFailure(s, &failures.Failure{Issue: issue, Reason: reason})
+}
+
+// RecordFailureCategory is the same as RecordFailure with a specific category for the 'Failure' record
+func RecordFailureCategory(s *Service, issue, reason, category string) {
+	recordFailure(s, &failures.Failure{Issue: issue, Reason: reason, Category: category})
+}
+
+// warmupPeriod returns the WARMUP_PERIOD after a service started checking during which failures are not recorded
+func warmupPeriod() time.Duration {
+	if utils.Params == nil {
+		return 0
+	}
+	return utils.Params.GetDuration("WARMUP_PERIOD")
+}
+
+// warmingUp returns true if the service started checking within the warmup period and had no successful
+// check yet, such as a new service whose DNS or deploy is not ready
+func (s *Service) warmingUp() bool {
+	return !s.warmupUntil.IsZero() && utils.Now().Before(s.warmupUntil)
+}
+
+// recordFailure will create the 'Failure' record from the structured fields of fail, the category is
+// taken from the reason if it is not set and the Issue is rendered from the fields if it is empty
+func recordFailure(s *Service, fail *failures.Failure) {
+	if s.probed != nil {
+		if fail.Category == "" {
+			fail.Category = failureCategory(fail.Reason)
+		}
+		s.probed.issue = fail.Render()
+		s.probed.category = fail.Category
+		return
+	}
+	if s.warmingUp() {
+		log.Warnln(fmt.Sprintf("Service %v Failing during its warmup period, the failure is not recorded: %v", s.Name, fail.Render()))
+		return
+	}
 	s.LastOffline = utils.Now()
 
-	fail := &failures.Failure{
-		Service:   s.Id,
-		Issue:     issue,
-		PingTime:  s.PingTime,
-		CreatedAt: utils.Now(),
-		ErrorCode: s.LastStatusCode,
-		Reason:    reason,
+	fail.Service = s.Id
+	fail.PingTime = s.PingTime
+	fail.CreatedAt = utils.Now()
+	fail.ErrorCode = s.LastStatusCode
+	fail.PacketLoss = s.PacketLoss
+	fail.Status = StatusDown
+	if fail.Category == "" {
+		fail.Category = failureCategory(fail.Reason)
+	}
+	if len(fail.Actual) > maxResponseLength {
+		fail.Actual = fail.Actual[:maxResponseLength]
 	}
+	fail.Issue = fail.Render()
 	log.WithFields(utils.ToFields(fail, s)).
-		Warnln(fmt.Sprintf("Service %v Failing: %v | Lookup in: %v", s.Name, issue, humanMicro(fail.PingTime)))
+		Warnln(fmt.Sprintf("Service %v Failing: %v | Lookup in: %v", s.Name, fail.Issue, s.lookupText(fail.PingTime)))
+	// the event is published once the service is marked offline if the failure threshold is reached
+	defer func() { publishEvent(s.checkEvent(fail.Issue, fail.CreatedAt)) }()
 
 	if err := fail.Create(); err != nil {
 		log.Error(err)
 	}
-	s.Online = false
-	s.DownText = s.DowntimeText()
 
 	limitOffset := len(s.Failures)
 	if len(s.Failures) >= limitFailures {
@@ -442,23 +2945,206 @@ func RecordFailure(s *Service, issue, reason string) {
 	}
 
 	s.Failures = append([]*failures.Failure{fail}, s.Failures[:limitOffset]...)
+	metrics.Inc("failure", s.Name)
+	metrics.Inc("failures_total", s.Id, s.Name)
+	s.recordResponse(false)
+
+	s.CurrentFailureCount++
+	if s.CurrentFailureCount < s.failureThreshold() {
+		log.Infof("Service %v has %d/%d failures before being marked offline", s.Name, s.CurrentFailureCount, s.failureThreshold())
+		return
+	}
+
+	s.Online = false
+	s.DownText = s.DowntimeText()
+	metrics.Gauge("up", 0., s.Id, s.Name)
+	previousStatus := s.setStatus(StatusDown, "")
+	wentOffline := s.offlineSince.IsZero()
+	if wentOffline {
+		s.offlineSince = s.LastOffline
+	}
+	flapping := s.updateFlapping(s.LastOffline, wentOffline)
 
 	metrics.Gauge("online", 0., s.Name, s.Type)
-	metrics.Inc("failure", s.Name)
+	// the service is still offline, but none of the notifications are sent while a dependency is offline
+	if dependency := s.offlineDependency(); dependency != nil {
+		log.Infof("Service %v notifications were suppressed, it depends on %v which is offline", s.Name, dependency.Name)
+		return
+	}
+	s.notifyStatus(previousStatus)
+	if flapping {
+		sendFlapping(s)
+	}
 	sendFailure(s, fail)
 }
 
+// failureThreshold returns the amount of consecutive failures required before the service is offline
+func (s *Service) failureThreshold() int {
+	if s.FailureThreshold < 1 {
+		return 1
+	}
+	return s.FailureThreshold
+}
+
+// defaultRetryInterval is the first backoff between retries when RetryInterval is not set
+const defaultRetryInterval = 100 * time.Millisecond
+
+// retryBackoff returns the exponential backoff duration before the retry attempt
+func (s *Service) retryBackoff(attempt int) time.Duration {
+	interval := time.Duration(s.RetryInterval) * time.Millisecond
+	if interval <= 0 {
+		interval = defaultRetryInterval
+	}
+	return interval * time.Duration(1<<uint(attempt))
+}
+
+const defaultDownBackoffMax = time.Hour
+
+// downBackoff returns the duration to wait before checking an offline service, the IntervalDown or the
+// interval. When DownBackoff is set, the wait starts at DownBackoff seconds and doubles for each failure after
+// the service went offline, up to DownBackoffMax seconds. It is never shorter than the offline interval and
+// resets once the service is online.
+func (s *Service) downBackoff() time.Duration {
+	interval := s.downDuration()
+	if s.DownBackoff <= 0 {
+		return interval
+	}
+	max := time.Duration(s.DownBackoffMax) * time.Second
+	if max <= 0 {
+		max = defaultDownBackoffMax
+	}
+	backoff := time.Duration(s.DownBackoff) * time.Second
+	for i := s.failureThreshold(); i < s.CurrentFailureCount && backoff < max; i++ {
+		backoff *= 2
+	}
+	if backoff > max {
+		backoff = max
+	}
+	if backoff < interval {
+		return interval
+	}
+	return backoff
+}
+
 // Check will run checkHttp for HTTP services and checkTcp for TCP services
 // if record param is set to true, it will add a record into the database.
+// When RetryCount is set, failing attempts are retried with an exponential backoff
+// and only the final attempt will record a failure. HTTP services with RetryStatuses
+// only retry responses with one of those statuses, other failures are final.
 func (s *Service) CheckService(record bool) {
+	online := s.Online
+	for attempt := 0; attempt < s.RetryCount; attempt++ {
+		s.Online = false
+		if s.RetryStatuses != "" {
+			s.LastStatusCode = 0
+		}
+		s.runCheck(false)
+		if s.maintenanceResp {
+			s.Online = online
+			return
+		}
+		if s.Online {
+			if record {
+				RecordSuccess(s)
+			}
+			return
+		}
+		s.Online = online
+		if !s.retryable() {
+			log.Infof("Service %v failed attempt %d/%d with status %d, which is not retried", s.Name, attempt+1, s.RetryCount+1, s.LastStatusCode)
+			break
+		}
+		log.Infof("Service %v failed attempt %d/%d, retrying in %v", s.Name, attempt+1, s.RetryCount+1, s.retryBackoff(attempt))
+		select {
+		case <-s.Running:
+			// the service was stopped while waiting to retry
+			return
+		case <-time.After(s.retryBackoff(attempt)):
+		}
+	}
+	s.runCheck(record)
+}
+
+// retryable returns true if the failed attempt should be retried, HTTP services with RetryStatuses only
+// retry responses that have one of the statuses
+func (s *Service) retryable() bool {
+	if s.Type != "http" || strings.TrimSpace(s.RetryStatuses) == "" {
+		return true
+	}
+	match, _ := matchStatusCode(s.RetryStatuses, s.LastStatusCode)
+	return match
+}
+
+func (s *Service) runCheck(record bool) {
+	if s.Inverted.Bool && s.probed == nil {
+		s.runInvertedCheck(record)
+		return
+	}
+	s.checkType(record)
+}
+
+// runInvertedCheck will check an Inverted service with the result kept in a probeResult instead of being
+// recorded, then records a failure if the check succeeded and a success if it failed. The latency and
+// response of the check are kept for diagnostics.
+func (s *Service) runInvertedCheck(record bool) {
+	online := s.Online
+	result := &probeResult{}
+	s.probed = result
+	s.maintenanceResp = false
+	s.checkType(true)
+	s.probed = nil
+	s.Online = online
+
+	// the service was stopped during the check or responded with maintenance
+	if (s.Running != nil && !s.IsRunning()) || s.maintenanceResp {
+		return
+	}
+	if result.online {
+		if record {
+			recordFailure(s, &failures.Failure{
+				Issue:    fmt.Sprintf("Service was expected to be offline but responded in %s", humanMicro(s.Latency)),
+				Reason:   "inverted",
+				Expected: "offline",
+				Actual:   s.LastResponse,
+			})
+		}
+		return
+	}
+	log.Infof("Service %v is inverted and the check failed as expected: %v", s.Name, result.issue)
+	s.Online = true
+	if record {
+		RecordSuccess(s)
+	}
+}
+
+// checkType will run the check of the service type
+func (s *Service) checkType(record bool) {
 	switch s.Type {
 	case "http":
 		CheckHttp(s, record)
-	case "tcp", "udp":
+	case "tcp":
 		CheckTcp(s, record)
+	case "udp":
+		CheckUdp(s, record)
 	case "grpc":
 		CheckGrpc(s, record)
 	case "icmp":
 		CheckIcmp(s, record)
+	case "dns":
+		CheckDns(s, record)
+	case "websocket":
+		CheckWebsocket(s, record)
+	case "smtp":
+		CheckSmtp(s, record)
+	case "imap", "pop3":
+		CheckMailbox(s, record)
+	case "ntp":
+		CheckNtp(s, record)
+	case "mqtt":
+		CheckMqtt(s, record)
+	default:
+		if checker := customChecker(s.Type); checker != nil {
+			s.runCustomCheck(record, checker)
+		}
 	}
 }
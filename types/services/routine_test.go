@@ -1,16 +1,48 @@
 package services
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/gorilla/websocket"
+	"github.com/statping/statping/types/failures"
+	"github.com/statping/statping/types/hits"
 	"github.com/statping/statping/types/null"
+	"github.com/statping/statping/utils"
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/health"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
 // grpcServerDef is function type.
@@ -164,6 +196,57 @@ var testdata = []struct {
 			GrpcHealthCheck: null.NewNullBool(false),
 		},
 	},
+	{
+		grpcService: func(port int, enableHealthCheck bool) *grpc.Server {
+			return grpcServer(port, enableHealthCheck)
+		},
+		clientChecker: &Service{
+			Name:            "GRPC HealthCheck for a named service",
+			Domain:          "localhost",
+			Port:            50062,
+			Expected:        null.NewNullString("status:SERVING"),
+			ExpectedStatus:  1,
+			Type:            "grpc",
+			Timeout:         1,
+			VerifySSL:       null.NewNullBool(false),
+			GrpcHealthCheck: null.NewNullBool(true),
+			GrpcService:     "Test GRPC Service",
+		},
+	},
+	{
+		grpcService: func(port int, enableHealthCheck bool) *grpc.Server {
+			return grpcServer(port, enableHealthCheck)
+		},
+		clientChecker: &Service{
+			Name:            "GRPC HealthCheck for an unknown named service",
+			Domain:          "localhost",
+			Port:            50063,
+			Expected:        null.NewNullString(""),
+			ExpectedStatus:  0,
+			Type:            "grpc",
+			Timeout:         1,
+			VerifySSL:       null.NewNullBool(false),
+			GrpcHealthCheck: null.NewNullBool(true),
+			GrpcService:     "Unknown GRPC Service",
+		},
+	},
+	{
+		grpcService: func(port int, enableHealthCheck bool) *grpc.Server {
+			return grpcServer(port, enableHealthCheck)
+		},
+		clientChecker: &Service{
+			Name:            "GRPC HealthCheck expecting NOT_SERVING",
+			Domain:          "localhost",
+			Port:            50064,
+			Expected:        null.NewNullString("status:NOT_SERVING"),
+			ExpectedStatus:  2,
+			Type:            "grpc",
+			Timeout:         1,
+			VerifySSL:       null.NewNullBool(false),
+			GrpcHealthCheck: null.NewNullBool(true),
+			GrpcService:     "Maintenance GRPC Service",
+		},
+	},
 }
 
 // grpcServer creates grpc Service with optional parameters.
@@ -178,6 +261,7 @@ func grpcServer(port int, enableHealthCheck bool) *grpc.Server {
 	if enableHealthCheck {
 		healthServer := health.NewServer()
 		healthServer.SetServingStatus("Test GRPC Service", healthpb.HealthCheckResponse_SERVING)
+		healthServer.SetServingStatus("Maintenance GRPC Service", healthpb.HealthCheckResponse_NOT_SERVING)
 		healthpb.RegisterHealthServer(server, healthServer)
 		go server.Serve(lis)
 	}
@@ -200,3 +284,3544 @@ func TestCheckGrpc(t *testing.T) {
 		})
 	}
 }
+
+// TestExpectedGrpcStatus examines expectedGrpcStatus() defaulting to SERVING
+func TestExpectedGrpcStatus(t *testing.T) {
+	tests := map[int]healthpb.HealthCheckResponse_ServingStatus{
+		0:   healthpb.HealthCheckResponse_SERVING,
+		1:   healthpb.HealthCheckResponse_SERVING,
+		2:   healthpb.HealthCheckResponse_NOT_SERVING,
+		200: healthpb.HealthCheckResponse_SERVING,
+	}
+	for expected, status := range tests {
+		s := &Service{ExpectedStatus: expected}
+		if s.expectedGrpcStatus() != status {
+			t.Errorf("Expected Status: '%v', Got Status: '%v'", status, s.expectedGrpcStatus())
+		}
+	}
+}
+
+// TestCheckDns examines CheckDns() against the local hosts file
+func TestCheckDns(t *testing.T) {
+	s := &Service{
+		Name:          "DNS localhost",
+		Domain:        "localhost",
+		Type:          "dns",
+		DnsRecordType: "A",
+		Expected:      null.NewNullString(`127\.0\.0\.1`),
+		Timeout:       2,
+	}
+	if _, err := CheckDns(s, false); err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	if !s.Online {
+		t.Errorf("Expected service to be online, Got response: '%v'", s.LastResponse)
+	}
+
+	s.DnsRecordType = "SRV"
+	if _, err := CheckDns(s, false); err == nil {
+		t.Errorf("Expected error for unsupported record type '%v'", s.DnsRecordType)
+	}
+}
+
+// TestCheckServiceRetry examines CheckService() retrying a failed attempt
+func TestCheckServiceRetry(t *testing.T) {
+	utils.InitEnvs()
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	s := &Service{
+		Name:           "HTTP Retry",
+		Domain:         server.URL,
+		ExpectedStatus: 200,
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        2,
+		RetryCount:     2,
+		RetryInterval:  1,
+	}
+	s.CheckService(false)
+	if !s.Online || requests != 2 {
+		t.Errorf("Expected online after 2 requests, Got online: '%v', requests: '%v'", s.Online, requests)
+	}
+
+	s.Online = false
+	s.Domain = "http://localhost:1"
+	s.CheckService(false)
+	if s.Online {
+		t.Errorf("Expected service to be offline after %v retries", s.RetryCount)
+	}
+}
+
+// TestCheckHttpRedirect examines CheckHttp() expecting a 302 with and without following redirects
+func TestCheckHttpRedirect(t *testing.T) {
+	utils.InitEnvs()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/final", http.StatusFound)
+	})
+	mux.HandleFunc("/final", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	s := &Service{
+		Name:           "HTTP Redirect",
+		Domain:         server.URL + "/redirect",
+		ExpectedStatus: http.StatusFound,
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        2,
+		Redirect:       null.NewNullBool(false),
+	}
+	CheckHttp(s, false)
+	if !s.Online || s.LastStatusCode != http.StatusFound {
+		t.Errorf("Expected online with status 302, Got online: '%v', status: '%v'", s.Online, s.LastStatusCode)
+	}
+
+	s.Online = false
+	s.Redirect = null.NewNullBool(true)
+	CheckHttp(s, false)
+	if s.Online || s.LastStatusCode != http.StatusOK {
+		t.Errorf("Expected offline with status 200, Got online: '%v', status: '%v'", s.Online, s.LastStatusCode)
+	}
+}
+
+// TestCheckHttpMaxLatency examines CheckHttp() failing a slow response
+func TestCheckHttpMaxLatency(t *testing.T) {
+	utils.InitEnvs()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	s := &Service{
+		Name:           "HTTP Slow",
+		Domain:         server.URL,
+		ExpectedStatus: 200,
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        2,
+		MaxLatency:     0.01,
+	}
+	CheckHttp(s, false)
+	if s.Online {
+		t.Errorf("Expected offline when latency '%v' exceeded '%v' seconds", s.Latency, s.MaxLatency)
+	}
+
+	s.MaxLatency = 1
+	CheckHttp(s, false)
+	if !s.Online {
+		t.Errorf("Expected online when latency '%v' is under '%v' seconds", s.Latency, s.MaxLatency)
+	}
+}
+
+// TestMatchHeaders examines matchHeaders() with plain text and regex values
+func TestMatchHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	header.Set("X-Frame-Options", "DENY")
+
+	if err := matchHeaders("Content-Type=application/json,x-frame-options=^DENY$", header); err != nil {
+		t.Errorf("Expected headers to match, Got: '%v'", err)
+	}
+	if err := matchHeaders("Content-Type=text/html", header); err == nil {
+		t.Errorf("Expected mismatched header value to fail")
+	}
+	if err := matchHeaders("Strict-Transport-Security=max-age", header); err == nil {
+		t.Errorf("Expected missing header to fail")
+	}
+}
+
+// TestCheckWebsocket examines CheckWebsocket() against an echo server
+func TestCheckWebsocket(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		msgType, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.WriteMessage(msgType, msg)
+	}))
+	defer server.Close()
+
+	s := &Service{
+		Name:     "Websocket Echo",
+		Domain:   "ws" + strings.TrimPrefix(server.URL, "http"),
+		Type:     "websocket",
+		PostData: null.NewNullString("ping"),
+		Expected: null.NewNullString("^ping$"),
+		Timeout:  2,
+	}
+	if _, err := CheckWebsocket(s, false); err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	if !s.Online || s.LastResponse != "ping" {
+		t.Errorf("Expected online with response 'ping', Got online: '%v', response: '%v'", s.Online, s.LastResponse)
+	}
+
+	s.Online = false
+	s.Expected = null.NewNullString("^pong$")
+	CheckWebsocket(s, false)
+	if s.Online {
+		t.Errorf("Expected offline when message did not match '%v'", s.Expected.String)
+	}
+}
+
+// TestApplyJitter examines applyJitter() staying within the jitter percent and never returning zero
+func TestApplyJitter(t *testing.T) {
+	utils.InitEnvs()
+	s := &Service{Interval: 10, Jitter: 20}
+	for i := 0; i < 100; i++ {
+		sleep := s.applyJitter(s.Duration())
+		if sleep < 8*time.Second || sleep > 12*time.Second {
+			t.Fatalf("Expected sleep between 8s and 12s, Got: '%v'", sleep)
+		}
+	}
+
+	s.Jitter = 100
+	for i := 0; i < 100; i++ {
+		if sleep := s.applyJitter(time.Second); sleep <= 0 {
+			t.Fatalf("Expected positive sleep, Got: '%v'", sleep)
+		}
+	}
+}
+
+// TestCheckHttpPostDataType examines CheckHttp() sending the PostDataType or an explicit Content-Type header
+func TestCheckHttpPostDataType(t *testing.T) {
+	utils.InitEnvs()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Content-Type")))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		postDataType string
+		headers      null.NullString
+		expected     string
+	}{
+		{"", null.NullString{}, "application/json"},
+		{"application/x-www-form-urlencoded", null.NullString{}, "application/x-www-form-urlencoded"},
+		{"text/xml", null.NullString{}, "text/xml"},
+		{"text/xml", null.NewNullString("Content-Type=application/x-www-form-urlencoded"), "application/x-www-form-urlencoded"},
+	}
+
+	for _, test := range tests {
+		s := &Service{
+			Name:           "HTTP Post " + test.postDataType,
+			Domain:         server.URL,
+			ExpectedStatus: 200,
+			Type:           "http",
+			Method:         "POST",
+			PostData:       null.NewNullString("name=statping"),
+			PostDataType:   test.postDataType,
+			Headers:        test.headers,
+			Timeout:        2,
+		}
+		CheckHttp(s, false)
+		if s.LastResponse != test.expected {
+			t.Errorf("Expected Content-Type: '%v', Got: '%v'", test.expected, s.LastResponse)
+		}
+	}
+}
+
+// TestErrorCategory examines errorCategory() and failureCategory() categorizing failures
+func TestErrorCategory(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{&net.DNSError{Err: "no such host", Name: "statping.invalid"}, failures.CategoryDns},
+		{context.DeadlineExceeded, failures.CategoryTimeout},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, failures.CategoryConnect},
+		{errors.New("x509: certificate signed by unknown authority"), failures.CategoryTls},
+		{errors.New("something else"), failures.CategoryOther},
+	}
+	for _, test := range tests {
+		if category := errorCategory(test.err, failures.CategoryOther); category != test.expected {
+			t.Errorf("Expected category: '%v' for '%v', Got: '%v'", test.expected, test.err, category)
+		}
+	}
+
+	if category := failureCategory("status_code"); category != failures.CategoryStatus {
+		t.Errorf("Expected category: '%v', Got: '%v'", failures.CategoryStatus, category)
+	}
+	if category := failureCategory("lookup"); category != failures.CategoryDns {
+		t.Errorf("Expected category: '%v', Got: '%v'", failures.CategoryDns, category)
+	}
+}
+
+// TestCheckTcpIPVersion examines CheckTcp() dialing with a forced IP version
+func TestCheckTcpIPVersion(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	defer listener.Close()
+
+	s := &Service{
+		Name:      "TCP IPv4",
+		Domain:    "127.0.0.1",
+		Port:      listener.Addr().(*net.TCPAddr).Port,
+		Type:      "tcp",
+		Timeout:   2,
+		IPVersion: "ipv4",
+	}
+	if _, err := CheckTcp(s, false); err != nil || !s.Online {
+		t.Errorf("Expected service to be online, Got: '%v'", err)
+	}
+
+	s.Online = false
+	s.IPVersion = "ipv6"
+	if _, err := CheckTcp(s, false); err == nil || s.Online {
+		t.Errorf("Expected IPv6 check of an IPv4 address to fail")
+	}
+}
+
+// TestCheckHttpAuthorization examines CheckHttp() sending basic auth and bearer token credentials
+func TestCheckHttpAuthorization(t *testing.T) {
+	utils.InitEnvs()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); ok && user == "admin" && pass == "secret" {
+			return
+		}
+		if r.Header.Get("Authorization") == "Bearer token123" {
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	s := &Service{
+		Name:           "HTTP Authorization",
+		Domain:         server.URL,
+		ExpectedStatus: 200,
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        2,
+		BearerToken:    null.NewNullString("token123"),
+	}
+	CheckHttp(s, false)
+	if !s.Online {
+		t.Errorf("Expected bearer token to be accepted, Got status: '%v'", s.LastStatusCode)
+	}
+
+	s.Online = false
+	s.BasicAuthUser = null.NewNullString("admin")
+	s.BasicAuthPass = null.NewNullString("secret")
+	s.BearerToken = null.NewNullString("wrong")
+	CheckHttp(s, false)
+	if !s.Online {
+		t.Errorf("Expected basic auth to be used over the bearer token, Got status: '%v'", s.LastStatusCode)
+	}
+
+	redacted := redactHeaders([]string{"Authorization=Basic YWRtaW46c2VjcmV0", "Accept=*/*"})
+	if redacted[0] != "Authorization=[redacted]" || redacted[1] != "Accept=*/*" {
+		t.Errorf("Expected Authorization header to be redacted, Got: '%v'", redacted)
+	}
+}
+
+// TestCheckTcpSendExpect examines CheckTcp() sending PostData and matching the response
+func TestCheckTcpSendExpect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			buf := make([]byte, 16)
+			n, _ := conn.Read(buf)
+			if string(buf[:n]) == "PING\r\n" {
+				conn.Write([]byte("+PONG\r\n"))
+			} else {
+				conn.Write([]byte("-ERR unknown command\r\n"))
+			}
+			conn.Close()
+		}
+	}()
+
+	s := &Service{
+		Name:     "TCP Send Expect",
+		Domain:   "127.0.0.1",
+		Port:     listener.Addr().(*net.TCPAddr).Port,
+		Type:     "tcp",
+		Timeout:  2,
+		PostData: null.NewNullString("PING\r\n"),
+		Expected: null.NewNullString(`^\+PONG`),
+	}
+	if _, err := CheckTcp(s, false); err != nil || !s.Online {
+		t.Errorf("Expected service to be online, Got: '%v', response: '%v'", err, s.LastResponse)
+	}
+	if s.LastResponse != "+PONG\r\n" {
+		t.Errorf("Expected response: '+PONG', Got: '%v'", s.LastResponse)
+	}
+
+	s.Online = false
+	s.PostData = null.NewNullString("QUIT\r\n")
+	CheckTcp(s, false)
+	if s.Online {
+		t.Errorf("Expected service to be offline, Got response: '%v'", s.LastResponse)
+	}
+}
+
+// TestResponseHistory examines the ring buffer of recent responses staying within RESPONSE_HISTORY
+func TestResponseHistory(t *testing.T) {
+	utils.InitEnvs()
+	utils.Params.Set("RESPONSE_HISTORY", 3)
+	defer utils.Params.Set("RESPONSE_HISTORY", 10)
+
+	s := &Service{Name: "Response History"}
+	for i := 1; i <= 5; i++ {
+		s.LastResponse = fmt.Sprintf("response %d", i)
+		s.recordResponse(i%2 == 0)
+	}
+	responses := s.Responses()
+	if len(responses) != 3 {
+		t.Fatalf("Expected 3 responses, Got: '%v'", len(responses))
+	}
+	for i, expected := range []string{"response 5", "response 4", "response 3"} {
+		if responses[i].Response != expected {
+			t.Errorf("Expected response: '%v', Got: '%v'", expected, responses[i].Response)
+		}
+	}
+	if !responses[1].Online || responses[0].Online {
+		t.Errorf("Expected the online status to be recorded with each response")
+	}
+
+	utils.Params.Set("RESPONSE_HISTORY", 2)
+	s.LastResponse = "response 6"
+	s.recordResponse(true)
+	responses = s.Responses()
+	if len(responses) != 2 || responses[0].Response != "response 6" || responses[1].Response != "response 5" {
+		t.Errorf("Expected the 2 newest responses, Got: '%v'", responses)
+	}
+}
+
+// TestMaintenanceWindow examines maintenanceWindow() with one time and repeating windows
+func TestMaintenanceWindow(t *testing.T) {
+	start := time.Date(2020, 5, 1, 2, 0, 0, 0, time.UTC)
+	s := &Service{
+		MaintenanceStart: start,
+		MaintenanceEnd:   start.Add(time.Hour),
+	}
+	tests := []struct {
+		repeat   string
+		now      time.Time
+		expected bool
+		end      time.Time
+	}{
+		{"", start.Add(-time.Minute), false, time.Time{}},
+		{"", start.Add(30 * time.Minute), true, start.Add(time.Hour)},
+		{"", start.Add(time.Hour), false, time.Time{}},
+		{"", start.Add(24 * time.Hour), false, time.Time{}},
+		{"daily", start.Add(24*time.Hour + 10*time.Minute), true, start.Add(25 * time.Hour)},
+		{"daily", start.Add(26 * time.Hour), false, time.Time{}},
+		{"weekly", start.Add(24*time.Hour + 10*time.Minute), false, time.Time{}},
+		{"weekly", start.Add(7*24*time.Hour + 10*time.Minute), true, start.Add(7*24*time.Hour + time.Hour)},
+	}
+	for _, test := range tests {
+		s.MaintenanceRepeat = test.repeat
+		inMaintenance, end := s.maintenanceWindow(test.now)
+		if inMaintenance != test.expected || !end.Equal(test.end) {
+			t.Errorf("Expected maintenance: '%v' until '%v' for %v (%v), Got: '%v' until '%v'", test.expected, test.end, test.now, test.repeat, inMaintenance, end)
+		}
+	}
+}
+
+// TestCheckHttpCancelled examines CheckHttp() being cancelled when the service is stopped
+func TestCheckHttpCancelled(t *testing.T) {
+	utils.InitEnvs()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	s := &Service{
+		Name:           "HTTP Cancelled",
+		Domain:         server.URL,
+		ExpectedStatus: 200,
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        10,
+	}
+	s.Start()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		s.Close()
+	}()
+
+	t1 := time.Now()
+	if _, err := CheckHttp(s, true); err == nil {
+		t.Errorf("Expected error for a cancelled check")
+	}
+	if elapsed := time.Since(t1); elapsed > 2*time.Second {
+		t.Errorf("Expected check to be cancelled promptly, took: '%v'", elapsed)
+	}
+	if s.Online || len(s.Failures) != 0 {
+		t.Errorf("Expected no failure to be recorded for a cancelled check, Got: '%v'", len(s.Failures))
+	}
+}
+
+// TestCheckHttpProxy examines CheckHttp() sending the request through the service's proxy
+func TestCheckHttpProxy(t *testing.T) {
+	utils.InitEnvs()
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host != "statping.invalid" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("proxied"))
+	}))
+	defer proxy.Close()
+
+	s := &Service{
+		Name:           "HTTP Proxy",
+		Domain:         "http://statping.invalid/",
+		ExpectedStatus: 200,
+		Expected:       null.NewNullString("proxied"),
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        2,
+		Proxy:          proxy.URL,
+	}
+	if _, err := CheckHttp(s, false); err != nil || !s.Online {
+		t.Errorf("Expected request through the proxy to be online, Got: '%v', response: '%v'", err, s.LastResponse)
+	}
+
+	s.Online = false
+	s.Proxy = "socks5://127.0.0.1:1"
+	_, err := CheckHttp(s, false)
+	if !utils.IsProxyError(err) {
+		t.Errorf("Expected a proxy error, Got: '%v'", err)
+	}
+}
+
+// TestMatchJSONPath examines matchJSONPath() evaluating a JSON path in the response body
+func TestMatchJSONPath(t *testing.T) {
+	body := []byte(`{"status": "ok", "data": {"items": [{"id": 1, "healthy": true}, {"id": 2, "healthy": false}]}}`)
+	tests := []struct {
+		path     string
+		expected string
+		valid    bool
+	}{
+		{"$.status", "ok", true},
+		{"$.status", "o.", true},
+		{"$.status", "fail", false},
+		{"$.data.items[0].healthy", "true", true},
+		{"$.data.items[1].id", "2", true},
+		{"$.data.items[2].id", "3", false},
+		{"$.data.missing", "", false},
+		{"$.status.value", "ok", false},
+	}
+	for _, test := range tests {
+		err := matchJSONPath(body, test.path, test.expected)
+		if test.valid && err != nil {
+			t.Errorf("Expected '%v' to match '%v', Got: '%v'", test.path, test.expected, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("Expected '%v' not to match '%v'", test.path, test.expected)
+		}
+	}
+
+	if err := matchJSONPath([]byte("<html></html>"), "$.status", "ok"); err == nil {
+		t.Errorf("Expected error for a non JSON body")
+	}
+}
+
+// TestCheckSmtp examines CheckSmtp() reading the banner and requiring STARTTLS when VerifySSL is set
+func TestCheckSmtp(t *testing.T) {
+	listener := smtpServer(t, "220 mail.statping.com ESMTP")
+	defer listener.Close()
+
+	s := &Service{
+		Name:    "SMTP",
+		Domain:  "127.0.0.1",
+		Port:    listener.Addr().(*net.TCPAddr).Port,
+		Type:    "smtp",
+		Timeout: 2,
+	}
+	if _, err := CheckSmtp(s, false); err != nil || !s.Online {
+		t.Errorf("Expected SMTP service to be online, Got: '%v'", err)
+	}
+	if s.LastResponse != "mail.statping.com ESMTP" {
+		t.Errorf("Expected banner response, Got: '%v'", s.LastResponse)
+	}
+
+	s.Online = false
+	s.VerifySSL = null.NewNullBool(true)
+	if _, err := CheckSmtp(s, false); err == nil || s.Online {
+		t.Errorf("Expected error for a server without STARTTLS")
+	}
+
+	rejecting := smtpServer(t, "554 No SMTP service here")
+	defer rejecting.Close()
+	s.VerifySSL = null.NewNullBool(false)
+	s.Port = rejecting.Addr().(*net.TCPAddr).Port
+	if _, err := CheckSmtp(s, false); err == nil || s.Online {
+		t.Errorf("Expected error for a banner without a 220 status")
+	}
+}
+
+// smtpServer will start a minimal SMTP server that sends the banner and responds to EHLO and QUIT
+func smtpServer(t *testing.T, banner string) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				text := textproto.NewConn(conn)
+				text.PrintfLine(banner)
+				for {
+					line, err := text.ReadLine()
+					if err != nil {
+						return
+					}
+					switch {
+					case strings.HasPrefix(line, "EHLO"):
+						text.PrintfLine("250-mail.statping.com")
+						text.PrintfLine("250 SIZE 1000000")
+					case line == "QUIT":
+						text.PrintfLine("221 Bye")
+						return
+					default:
+						text.PrintfLine("502 Command not implemented")
+					}
+				}
+			}(conn)
+		}
+	}()
+	return listener
+}
+
+// TestPercentile examines percentile() using the nearest-rank method
+func TestPercentile(t *testing.T) {
+	var latencies []int64
+	for i := int64(1); i <= 100; i++ {
+		latencies = append(latencies, i*1000)
+	}
+	tests := map[float64]int64{50: 50000, 95: 95000, 99: 99000, 100: 100000}
+	for percent, expected := range tests {
+		if value := percentile(latencies, percent); value != expected {
+			t.Errorf("Expected p%v: '%v', Got: '%v'", percent, expected, value)
+		}
+	}
+	if value := percentile(nil, 95); value != 0 {
+		t.Errorf("Expected 0 without any latencies, Got: '%v'", value)
+	}
+}
+
+// TestLatencyWindow examines the percentiles of the cached latencies of the hits within the LATENCY_WINDOW
+func TestLatencyWindow(t *testing.T) {
+	utils.InitEnvs()
+	now := utils.Now()
+	s := &Service{Name: "Latency Window"}
+	s.trackLatency(&hits.Hit{Latency: 90000, CreatedAt: now.Add(-2 * time.Hour)})
+	for i := int64(1); i <= 4; i++ {
+		s.trackLatency(&hits.Hit{Latency: i * 1000, CreatedAt: now})
+	}
+	if len(s.latencies) != 4 {
+		t.Errorf("Expected the latencies outside of the window to be removed, Got: %d", len(s.latencies))
+	}
+	s.updatePercentiles()
+	if s.LatencyP50 != 2000 || s.LatencyP99 != 4000 {
+		t.Errorf("Expected the percentiles of the cached latencies, Got: '%v' '%v'", s.LatencyP50, s.LatencyP99)
+	}
+}
+
+// TestMatchStatusCode examines matchStatusCode() with codes, classes and ranges
+func TestMatchStatusCode(t *testing.T) {
+	tests := []struct {
+		expected string
+		code     int
+		match    bool
+	}{
+		{"200", 200, true},
+		{"200", 204, false},
+		{"200,204", 204, true},
+		{"2xx", 299, true},
+		{"2xx", 301, false},
+		{"200-299, 404", 404, true},
+		{"200-299", 300, false},
+	}
+	for _, test := range tests {
+		match, err := matchStatusCode(test.expected, test.code)
+		if err != nil || match != test.match {
+			t.Errorf("Expected '%v' to match %v: '%v', Got: '%v' (%v)", test.expected, test.code, test.match, match, err)
+		}
+	}
+	if _, err := matchStatusCode("2xx,abc", 500); err == nil {
+		t.Errorf("Expected error for an invalid status code")
+	}
+
+	s := &Service{ExpectedStatus: 201}
+	if s.expectedStatusCodes() != "201" {
+		t.Errorf("Expected the ExpectedStatus to be used, Got: '%v'", s.expectedStatusCodes())
+	}
+}
+
+// TestCheckHttpTiming examines CheckHttp() recording the connect, TLS and first byte durations
+func TestCheckHttpTiming(t *testing.T) {
+	utils.InitEnvs()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	s := &Service{
+		Name:           "HTTP Timing",
+		Domain:         server.URL,
+		ExpectedStatus: http.StatusOK,
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        2,
+		VerifySSL:      null.NewNullBool(false),
+	}
+	CheckHttp(s, false)
+	if !s.Online {
+		t.Fatalf("Expected online, Got: '%v'", s.LastStatusCode)
+	}
+	if s.timing.Connect <= 0 || s.timing.TLS <= 0 {
+		t.Errorf("Expected connect and TLS durations, Got: '%v' and '%v'", s.timing.Connect, s.timing.TLS)
+	}
+	if s.timing.FirstByte < 10*time.Millisecond {
+		t.Errorf("Expected first byte after 10ms, Got: '%v'", s.timing.FirstByte)
+	}
+}
+
+// TestCheckUdp examines CheckUdp() sending a datagram and waiting for the response
+func TestCheckUdp(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, 16)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			// datagrams other than PING are ignored to simulate a server that does not respond
+			if string(buf[:n]) == "PING" {
+				conn.WriteTo([]byte("PONG"), addr)
+			}
+		}
+	}()
+
+	s := &Service{
+		Name:     "UDP Send Expect",
+		Domain:   "127.0.0.1",
+		Port:     conn.LocalAddr().(*net.UDPAddr).Port,
+		Type:     "udp",
+		Timeout:  1,
+		PostData: null.NewNullString("PING"),
+		Expected: null.NewNullString(`^PONG$`),
+	}
+	if _, err := CheckUdp(s, false); err != nil || !s.Online {
+		t.Errorf("Expected service to be online, Got: '%v', response: '%v'", err, s.LastResponse)
+	}
+
+	// the read deadline is shortened so the test does not wait for the service timeout
+	udpConn, err := net.Dial("udp", s.dialAddress())
+	if err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	defer udpConn.Close()
+	s.PostData = null.NewNullString("QUIT")
+	if _, err := udpExchange(udpConn, s, time.Now().Add(50*time.Millisecond)); err == nil {
+		t.Errorf("Expected a timeout without a response, Got: '%v'", err)
+	}
+}
+
+// TestCheckHttpEncodedBody examines CheckHttp() decompressing and truncating the response body
+func TestCheckHttpEncodedBody(t *testing.T) {
+	utils.InitEnvs()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		switch r.URL.Path {
+		case "/gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(&buf)
+			gz.Write([]byte(`{"status": "healthy"}`))
+			gz.Close()
+		case "/deflate":
+			w.Header().Set("Content-Encoding", "deflate")
+			zw := zlib.NewWriter(&buf)
+			zw.Write([]byte(`{"status": "healthy"}`))
+			zw.Close()
+		}
+		w.Write(buf.Bytes())
+	}))
+	defer server.Close()
+
+	for _, path := range []string{"/gzip", "/deflate"} {
+		s := &Service{
+			Name:           "HTTP Encoded",
+			Domain:         server.URL + path,
+			ExpectedStatus: http.StatusOK,
+			Expected:       null.NewNullString(`"healthy"`),
+			Type:           "http",
+			Method:         "GET",
+			Timeout:        2,
+			// the header disables the transport's own gzip decoding
+			Headers: null.NewNullString("Accept-Encoding=gzip, deflate"),
+		}
+		CheckHttp(s, false)
+		if !s.Online || s.LastResponse != `{"status": "healthy"}` {
+			t.Errorf("Expected %v response to be decoded, Got: '%v'", path, s.LastResponse)
+		}
+
+		s.Online = false
+		s.MaxResponseSize = 10
+		CheckHttp(s, false)
+		if s.LastResponse != `{"status":`+utils.TruncatedBody {
+			t.Errorf("Expected %v response to be truncated, Got: '%v'", path, s.LastResponse)
+		}
+		// the expected response is matched against the body without the truncated marker
+		s.Expected = null.NewNullString(`truncated`)
+		CheckHttp(s, false)
+		if s.Online {
+			t.Errorf("Expected %v truncated response to not match the truncated marker", path)
+		}
+	}
+
+	// a HEAD response has the Content-Encoding of the resource without a body to decode
+	s := &Service{
+		Name:           "HTTP Encoded HEAD",
+		Domain:         server.URL + "/gzip",
+		ExpectedStatus: http.StatusOK,
+		Type:           "http",
+		Method:         "HEAD",
+		Timeout:        2,
+		Headers:        null.NewNullString("Accept-Encoding=gzip"),
+	}
+	if _, err := CheckHttp(s, false); err != nil || !s.Online {
+		t.Errorf("Expected the HEAD response with a gzip Content-Encoding to be online, Got: '%v'", err)
+	}
+}
+
+// TestIntervalDuration examines Duration() with each IntervalUnit, including intervals of 10000 and above
+func TestIntervalDuration(t *testing.T) {
+	tests := []struct {
+		interval int
+		unit     string
+		expected time.Duration
+	}{
+		{9999, "", 9999 * time.Second},
+		{10000, "", 10000 * time.Second},
+		{10001, "s", 10001 * time.Second},
+		{10, "ms", 10 * time.Millisecond},
+		{10000, "ms", 10 * time.Second},
+		{10000, "us", 10 * time.Millisecond},
+	}
+	for _, test := range tests {
+		s := Service{Interval: test.interval, IntervalUnit: test.unit}
+		if s.Duration() != test.expected {
+			t.Errorf("Expected %v%v to be %v, Got: '%v'", test.interval, test.unit, test.expected, s.Duration())
+		}
+	}
+
+	s := &Service{Name: "Interval", Domain: "localhost", Type: "http", Interval: 10, IntervalUnit: "m"}
+	if err := s.Validate(); err == nil {
+		t.Errorf("Expected error for interval unit 'm'")
+	}
+}
+
+// TestCheckHttpMinContentLength examines CheckHttp() failing a response body shorter than MinContentLength
+func TestCheckHttpMinContentLength(t *testing.T) {
+	utils.InitEnvs()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("short"))
+	}))
+	defer server.Close()
+
+	s := &Service{
+		Name:             "HTTP Min Content Length",
+		Domain:           server.URL,
+		ExpectedStatus:   http.StatusOK,
+		Type:             "http",
+		Method:           "GET",
+		Timeout:          2,
+		MinContentLength: 5,
+	}
+	CheckHttp(s, false)
+	if !s.Online {
+		t.Errorf("Expected a 5 byte response to be online")
+	}
+
+	s.Online = false
+	s.MinContentLength = 6
+	CheckHttp(s, false)
+	if s.Online {
+		t.Errorf("Expected a 5 byte response to be offline with a minimum of 6")
+	}
+
+	// the length of the whole body is compared, not the length kept with MaxResponseSize
+	s.MinContentLength = 5
+	s.MaxResponseSize = 2
+	CheckHttp(s, false)
+	if !s.Online {
+		t.Errorf("Expected a 5 byte response cut at 2 bytes to be online with a minimum of 5")
+	}
+}
+
+// TestCheckPool examines the checkPool limiting concurrent checks and skipping missed checkpoints
+func TestCheckPool(t *testing.T) {
+	pool := newCheckPool(1)
+	stop := make(chan bool)
+	if !pool.acquire(stop) {
+		t.Fatalf("Expected the first slot to be acquired")
+	}
+	acquired := make(chan bool)
+	go func() {
+		acquired <- pool.acquire(stop)
+	}()
+	select {
+	case <-acquired:
+		t.Fatalf("Expected the second acquire to wait for a slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(stop)
+	if <-acquired {
+		t.Errorf("Expected acquire to return false when the service is stopped")
+	}
+	pool.release()
+
+	if !newCheckPool(0).acquire(nil) {
+		t.Errorf("Expected an unlimited pool to always acquire")
+	}
+
+	now := time.Now()
+	if next := nextCheckpoint(now.Add(-time.Second), now, 10*time.Second); !next.Equal(now.Add(9 * time.Second)) {
+		t.Errorf("Expected the next checkpoint in 9s, Got: '%v'", next.Sub(now))
+	}
+	// a check blocked for 35s with a 10s interval skips the missed checkpoints
+	if next := nextCheckpoint(now.Add(-35*time.Second), now, 10*time.Second); !next.Equal(now.Add(5 * time.Second)) {
+		t.Errorf("Expected the next checkpoint in 5s, Got: '%v'", next.Sub(now))
+	}
+}
+
+// TestCheckHttpMinTLSVersion examines CheckHttp() failing a HTTPS service negotiating a lower TLS version
+func TestCheckHttpMinTLSVersion(t *testing.T) {
+	utils.InitEnvs()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	s := &Service{
+		Name:           "HTTP Min TLS Version",
+		Domain:         server.URL,
+		ExpectedStatus: http.StatusOK,
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        2,
+		MinTLSVersion:  "1.2",
+	}
+	CheckHttp(s, false)
+	if !s.Online {
+		t.Errorf("Expected TLS 1.2 to be online with a minimum of 1.2")
+	}
+
+	s.Online = false
+	s.MinTLSVersion = "1.3"
+	CheckHttp(s, false)
+	if s.Online {
+		t.Errorf("Expected TLS 1.2 to be offline with a minimum of 1.3")
+	}
+
+	weak := &tls.ConnectionState{Version: tls.VersionTLS12, CipherSuite: tls.TLS_RSA_WITH_RC4_128_SHA}
+	if err := checkTLSState(weak, "1.2"); err == nil {
+		t.Errorf("Expected error for an insecure cipher suite")
+	}
+}
+
+// TestCheckHttpClientCert examines CheckHttp() presenting the TLS client certificate to a mTLS service
+func TestCheckHttpClientCert(t *testing.T) {
+	utils.InitEnvs()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	// the server's own certificate is used as the client certificate
+	serverCert := server.TLS.Certificates[0]
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: serverCert.Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(serverCert.PrivateKey.(*rsa.PrivateKey))})
+
+	s := &Service{
+		Name:           "HTTP Client Cert",
+		Domain:         server.URL,
+		ExpectedStatus: http.StatusOK,
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        2,
+	}
+	CheckHttp(s, false)
+	if s.Online {
+		t.Errorf("Expected offline without a client certificate")
+	}
+
+	s.TLSCert = null.NewNullString(string(certPEM))
+	s.TLSCertKey = null.NewNullString(string(keyPEM))
+	CheckHttp(s, false)
+	if !s.Online {
+		t.Errorf("Expected online with a client certificate")
+	}
+
+	s.Online = false
+	s.TLSCertKey = null.NewNullString("invalid key")
+	if _, err := CheckHttp(s, false); err == nil || s.Online {
+		t.Errorf("Expected error for an invalid client certificate, Got: '%v'", err)
+	}
+}
+
+// TestCheckHttpRootCA examines CheckHttp() verifying a self-signed certificate with the Root CA
+func TestCheckHttpRootCA(t *testing.T) {
+	utils.InitEnvs()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	s := &Service{
+		Name:           "HTTP Root CA",
+		Domain:         server.URL,
+		ExpectedStatus: http.StatusOK,
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        2,
+		VerifySSL:      null.NewNullBool(true),
+	}
+	CheckHttp(s, false)
+	if s.Online {
+		t.Errorf("Expected a self-signed certificate to be offline with VerifySSL")
+	}
+
+	rootPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	s.TLSCertRoot = null.NewNullString(string(rootPEM))
+	s.VerifySSL = null.NewNullBool(false)
+	CheckHttp(s, false)
+	if !s.Online {
+		t.Errorf("Expected the certificate to be verified with the Root CA")
+	}
+
+	s.Online = false
+	s.TLSCertRoot = null.NewNullString("-----BEGIN CERTIFICATE-----\ninvalid\n-----END CERTIFICATE-----\n")
+	if _, err := CheckHttp(s, false); err == nil || s.Online {
+		t.Errorf("Expected error for an invalid Root CA, Got: '%v'", err)
+	}
+}
+
+// TestCheckOverrun examines checkOverrun() flagging a check that took longer than the interval
+func TestCheckOverrun(t *testing.T) {
+	s := &Service{Name: "Overrun", Interval: 100, IntervalUnit: "ms"}
+	s.checkOverrun(50 * time.Millisecond)
+	if s.CheckOverrun {
+		t.Errorf("Expected a 50ms check to be within the 100ms interval")
+	}
+	s.checkOverrun(150 * time.Millisecond)
+	if !s.CheckOverrun {
+		t.Errorf("Expected a 150ms check to exceed the 100ms interval")
+	}
+	s.checkOverrun(100 * time.Millisecond)
+	if s.CheckOverrun {
+		t.Errorf("Expected the overrun to be cleared once the check is within the interval")
+	}
+}
+
+// TestCheckHttpH2C examines CheckHttp() sending cleartext HTTP/2 requests with prior knowledge
+func TestCheckHttpH2C(t *testing.T) {
+	utils.InitEnvs()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+	server := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	defer server.Close()
+
+	s := &Service{
+		Name:           "HTTP H2C",
+		Domain:         server.URL,
+		ExpectedStatus: http.StatusOK,
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        2,
+	}
+	CheckHttp(s, false)
+	if !s.Online || s.Protocol != "HTTP/1.1" {
+		t.Errorf("Expected HTTP/1.1 without ForceH2C, Got: '%v'", s.Protocol)
+	}
+
+	s.Online = false
+	s.ForceH2C = null.NewNullBool(true)
+	CheckHttp(s, false)
+	if !s.Online || s.Protocol != "HTTP/2.0" || s.LastResponse != "HTTP/2.0" {
+		t.Errorf("Expected HTTP/2.0 with ForceH2C, Got: '%v', response: '%v'", s.Protocol, s.LastResponse)
+	}
+}
+
+// TestDnsCheckCache examines dnsCheck() reusing the last lookup within DnsCacheTTL
+func TestDnsCheckCache(t *testing.T) {
+	s := &Service{Name: "DNS Cache", Domain: "localhost", Type: "tcp", DnsCacheTTL: 60}
+	lookup, err := dnsCheck(s)
+	if err != nil {
+		t.Fatalf("Expected lookup of localhost to succeed, Got: %v", err)
+	}
+	if s.dnsCached {
+		t.Errorf("Expected the first lookup to not be cached")
+	}
+	cached, err := dnsCheck(s)
+	if err != nil {
+		t.Fatalf("Expected cached lookup to succeed, Got: %v", err)
+	}
+	if !s.dnsCached || cached != lookup {
+		t.Errorf("Expected cached lookup of %v, Got: %v (cached: %v)", lookup, cached, s.dnsCached)
+	}
+	if !strings.HasSuffix(s.lookupText(cached), "(cached)") {
+		t.Errorf("Expected cached lookup to be marked in logs, Got: '%v'", s.lookupText(cached))
+	}
+
+	if s.dnsCacheValid(s.dnsCacheHost, s.dnsCacheExpires) {
+		t.Errorf("Expected cache to be expired after the TTL")
+	}
+	if s.dnsCacheValid("127.0.0.1", utils.Now()) {
+		t.Errorf("Expected cache to be invalid for a different host")
+	}
+
+	s.DnsCacheTTL = 0
+	if _, err := dnsCheck(s); err != nil || s.dnsCached {
+		t.Errorf("Expected lookup without cache when DnsCacheTTL is 0, Got: %v (cached: %v)", err, s.dnsCached)
+	}
+}
+
+// TestCheckHttpUserAgent examines CheckHttp() sending the service's User-Agent unless one is set in the headers
+func TestCheckHttpUserAgent(t *testing.T) {
+	utils.InitEnvs()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.UserAgent()))
+	}))
+	defer server.Close()
+
+	s := &Service{
+		Name:           "HTTP User Agent",
+		Domain:         server.URL,
+		ExpectedStatus: http.StatusOK,
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        2,
+	}
+	CheckHttp(s, false)
+	if s.LastResponse != "Statping/"+utils.Params.GetString("VERSION") {
+		t.Errorf("Expected default User-Agent, Got: '%v'", s.LastResponse)
+	}
+
+	s.UserAgent = "Mozilla/5.0 (statping)"
+	CheckHttp(s, false)
+	if s.LastResponse != "Mozilla/5.0 (statping)" {
+		t.Errorf("Expected custom User-Agent, Got: '%v'", s.LastResponse)
+	}
+
+	s.Headers = null.NewNullString("User-Agent=from-headers")
+	CheckHttp(s, false)
+	if s.LastResponse != "from-headers" {
+		t.Errorf("Expected User-Agent from headers, Got: '%v'", s.LastResponse)
+	}
+}
+
+// TestCheckRemoteIP examines CheckHttp() and CheckTcp() recording the IP address of the connection
+func TestCheckRemoteIP(t *testing.T) {
+	utils.InitEnvs()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	s := &Service{
+		Name:           "HTTP Remote IP",
+		Domain:         server.URL,
+		ExpectedStatus: http.StatusOK,
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        2,
+	}
+	CheckHttp(s, false)
+	if !s.Online || s.RemoteIP != "127.0.0.1" {
+		t.Errorf("Expected HTTP remote IP of 127.0.0.1, Got: '%v'", s.RemoteIP)
+	}
+
+	addr := server.Listener.Addr().(*net.TCPAddr)
+	tcp := &Service{
+		Name:    "TCP Remote IP",
+		Domain:  "localhost",
+		Port:    addr.Port,
+		Type:    "tcp",
+		Timeout: 2,
+	}
+	CheckTcp(tcp, false)
+	if !tcp.Online || net.ParseIP(tcp.RemoteIP) == nil || !net.ParseIP(tcp.RemoteIP).IsLoopback() {
+		t.Errorf("Expected TCP remote IP to be a loopback address, Got: '%v'", tcp.RemoteIP)
+	}
+
+	if remoteIP("[::1]:8080") != "::1" || remoteIP("10.0.0.1") != "10.0.0.1" {
+		t.Errorf("Expected remoteIP to strip the port")
+	}
+}
+
+// TestCheckHttpMaintenanceStatus examines CheckHttp() treating a MaintenanceStatus response as maintenance
+func TestCheckHttpMaintenanceStatus(t *testing.T) {
+	utils.InitEnvs()
+	status, body := http.StatusServiceUnavailable, "Down for maintenance"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	s := &Service{
+		Name:                "HTTP Maintenance",
+		Domain:              server.URL,
+		ExpectedStatus:      http.StatusOK,
+		Type:                "http",
+		Method:              "GET",
+		Timeout:             2,
+		Online:              true,
+		MaintenanceStatus:   http.StatusServiceUnavailable,
+		MaintenanceExpected: "(?i)maintenance",
+	}
+	CheckHttp(s, false)
+	if !s.Online || !s.InMaintenance {
+		t.Errorf("Expected maintenance response to keep the service online and in maintenance, Got: online %v, maintenance %v", s.Online, s.InMaintenance)
+	}
+
+	body = "Service Unavailable"
+	s.Online = false
+	CheckHttp(s, false)
+	if s.Online || s.InMaintenance {
+		t.Errorf("Expected 503 without a matching body to fail, Got: online %v, maintenance %v", s.Online, s.InMaintenance)
+	}
+
+	status, body = http.StatusOK, "ok"
+	CheckHttp(s, false)
+	if !s.Online || s.InMaintenance {
+		t.Errorf("Expected 200 to be online and not in maintenance, Got: online %v, maintenance %v", s.Online, s.InMaintenance)
+	}
+}
+
+// TestMatchPatterns examines matchPatterns() with 'all' and 'any' modes
+func TestMatchPatterns(t *testing.T) {
+	body := []byte(`{"status": "ok", "database": "up"}`)
+	tests := []struct {
+		patterns []string
+		mode     string
+		failed   string
+	}{
+		{[]string{`"status": "ok"`, `"database": "up"`}, "", ""},
+		{[]string{`"status": "ok"`, `"cache": "up"`}, "all", `did not match '"cache": "up"'`},
+		{[]string{`"cache": "up"`, `"database": "up"`}, "any", ""},
+		{[]string{`"cache": "up"`, `"queue": "up"`}, "any", `did not match any of '"cache": "up"', '"queue": "up"'`},
+	}
+	for _, test := range tests {
+		err := matchPatterns(body, test.patterns, test.mode)
+		if test.failed == "" && err != nil {
+			t.Errorf("Expected %v (%v) to match, Got: %v", test.patterns, test.mode, err)
+		} else if test.failed != "" && (err == nil || err.Error() != test.failed) {
+			t.Errorf("Expected %v (%v) to fail with '%v', Got: %v", test.patterns, test.mode, test.failed, err)
+		}
+	}
+	if err := matchPatterns(body, []string{"("}, "any"); err == nil {
+		t.Errorf("Expected an error for an invalid pattern")
+	}
+
+	s := &Service{ExpectedPatterns: null.NewNullString("first\n\n  second  \n")}
+	if patterns := s.expectedPatterns(); len(patterns) != 2 || patterns[1] != "second" {
+		t.Errorf("Expected 2 patterns, Got: %v", patterns)
+	}
+}
+
+// TestDownBackoff examines downBackoff() doubling the wait for an offline service up to DownBackoffMax
+func TestDownBackoff(t *testing.T) {
+	tests := []struct {
+		backoff  int
+		max      int
+		failures int
+		expected time.Duration
+	}{
+		{0, 0, 5, 30 * time.Second},
+		{60, 0, 1, 60 * time.Second},
+		{60, 0, 3, 240 * time.Second},
+		{60, 300, 10, 300 * time.Second},
+		{60, 0, 100, time.Hour},
+		{10, 0, 1, 30 * time.Second},
+	}
+	for _, test := range tests {
+		s := &Service{Interval: 30, FailureThreshold: 1, DownBackoff: test.backoff, DownBackoffMax: test.max, CurrentFailureCount: test.failures}
+		if backoff := s.downBackoff(); backoff != test.expected {
+			t.Errorf("Expected backoff %v for %d failures (%ds, max %ds), Got: %v", test.expected, test.failures, test.backoff, test.max, backoff)
+		}
+	}
+}
+
+// TestCheckGrpcReflection examines CheckGrpc() listing the server's services with the reflection API
+func TestCheckGrpcReflection(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	reflection.Register(server)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	s := &Service{
+		Name:     "GRPC Reflection",
+		Domain:   "127.0.0.1",
+		Port:     listener.Addr().(*net.TCPAddr).Port,
+		Type:     "grpc",
+		Timeout:  2,
+		GrpcMode: "reflection",
+	}
+	CheckGrpc(s, false)
+	if !s.Online || !strings.Contains(s.LastResponse, "grpc.health.v1.Health") {
+		t.Errorf("Expected reflected services to include the health service, Got: '%v'", s.LastResponse)
+	}
+
+	s.Online = false
+	s.GrpcService = "helloworld.Greeter"
+	CheckGrpc(s, false)
+	if s.Online {
+		t.Errorf("Expected service to be offline when '%v' is not reflected", s.GrpcService)
+	}
+
+	plain, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	noReflection := grpc.NewServer()
+	go noReflection.Serve(plain)
+	defer noReflection.Stop()
+
+	s.GrpcService = ""
+	s.Port = plain.Addr().(*net.TCPAddr).Port
+	CheckGrpc(s, false)
+	if s.Online {
+		t.Errorf("Expected service to be offline without reflection")
+	}
+}
+
+// TestResponseOnFailure examines recordResponse() only keeping the body of failing checks
+func TestResponseOnFailure(t *testing.T) {
+	utils.InitEnvs()
+	s := &Service{Name: "Response On Failure", ResponseOnFailure: null.NewNullBool(true)}
+	s.LastResponse = "failing body"
+	s.recordResponse(false)
+	s.LastResponse = "passing body"
+	s.recordResponse(true)
+	responses := s.Responses()
+	if len(responses) != 2 || responses[0].Response != "" || responses[1].Response != "failing body" {
+		t.Errorf("Expected only the failing response body to be kept, Got: '%v'", responses)
+	}
+
+	s = &Service{Name: "Response On Failure Global"}
+	if s.responseOnFailure() {
+		t.Errorf("Expected response bodies to be kept by default")
+	}
+	utils.Params.Set("RESPONSE_ON_FAILURE", true)
+	defer utils.Params.Set("RESPONSE_ON_FAILURE", false)
+	if !s.responseOnFailure() {
+		t.Errorf("Expected RESPONSE_ON_FAILURE to apply to all services")
+	}
+}
+
+// TestDnsResolver examines dnsCheck() sending queries to the nameserver and transport of DnsResolver
+func TestDnsResolver(t *testing.T) {
+	tests := map[string][2]string{
+		"8.8.8.8":          {"", "8.8.8.8:53"},
+		"1.1.1.1:5353":     {"", "1.1.1.1:5353"},
+		"tcp://8.8.8.8":    {"tcp", "8.8.8.8:53"},
+		"UDP://[::1]":      {"udp", "[::1]:53"},
+		"udp://[::1]:5353": {"udp", "[::1]:5353"},
+	}
+	for resolver, expected := range tests {
+		if transport, address := resolverAddress(resolver); transport != expected[0] || address != expected[1] {
+			t.Errorf("Expected '%v' to be %v, Got: '%v' '%v'", resolver, expected, transport, address)
+		}
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	defer listener.Close()
+	queried := make(chan bool, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			queried <- true
+			conn.Close()
+		}
+	}()
+
+	s := &Service{
+		Name:           "DNS Resolver",
+		Domain:         "statping.invalid",
+		Type:           "tcp",
+		Interval:       30,
+		ConnectTimeout: 1,
+		DnsResolver:    null.NewNullString("tcp://" + listener.Addr().String()),
+	}
+	if _, err := dnsCheck(s); err == nil {
+		t.Errorf("Expected lookup to fail against a nameserver that closes the connection")
+	}
+	select {
+	case <-queried:
+	default:
+		t.Errorf("Expected the query to be sent over TCP to %v", listener.Addr())
+	}
+
+	if err := s.Validate(); err != nil {
+		t.Errorf("Expected the 'tcp' resolver transport to be valid, Got: %v", err)
+	}
+	s.DnsResolver = null.NewNullString("quic://8.8.8.8")
+	if err := s.Validate(); err == nil {
+		t.Errorf("Expected an error for the 'quic' resolver transport")
+	}
+}
+
+// TestCheckHttpMethods examines CheckHttp() sending PostData with any method except HEAD
+func TestCheckHttpMethods(t *testing.T) {
+	utils.InitEnvs()
+	var method, contentType, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		method, contentType, body = r.Method, r.Header.Get("Content-Type"), string(data)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	s := &Service{
+		Name:           "HTTP Methods",
+		Domain:         server.URL,
+		ExpectedStatus: http.StatusOK,
+		Type:           "http",
+		Method:         "patch",
+		PostData:       null.NewNullString(`{"status": "ok"}`),
+		PostDataType:   "application/json",
+		Timeout:        2,
+	}
+	CheckHttp(s, false)
+	if !s.Online || method != http.MethodPatch || body != `{"status": "ok"}` || contentType != "application/json" {
+		t.Errorf("Expected PATCH with a JSON body, Got: %v '%v' (%v)", method, body, contentType)
+	}
+
+	s.Online = false
+	s.Method = "HEAD"
+	CheckHttp(s, false)
+	if !s.Online || method != http.MethodHead || body != "" || contentType != "" || s.LastResponse != "" {
+		t.Errorf("Expected HEAD without a body, Got: %v '%v' (%v), response: '%v'", method, body, contentType, s.LastResponse)
+	}
+}
+
+// TestSampleHit examines sampleHit() only inserting every HitSampleRate success within HitMinInterval
+func TestSampleHit(t *testing.T) {
+	now := utils.Now()
+	tests := []struct {
+		rate     int
+		interval int
+		checks   int
+		every    time.Duration
+		expected int
+	}{
+		{0, 0, 10, time.Second, 10},
+		{1, 0, 10, time.Second, 10},
+		{5, 0, 10, time.Second, 2},
+		{3, 0, 10, time.Second, 3},
+		{1, 5, 10, time.Second, 2},
+		{2, 3, 12, time.Second, 4},
+	}
+	for _, test := range tests {
+		s := &Service{HitSampleRate: test.rate, HitMinInterval: test.interval, lastHitSaved: now}
+		var saved int
+		for i := 1; i <= test.checks; i++ {
+			at := now.Add(time.Duration(i) * test.every)
+			if s.sampleHit(at) {
+				saved++
+				s.hitsSkipped = 0
+				s.lastHitSaved = at
+			}
+		}
+		if saved != test.expected {
+			t.Errorf("Expected %d of %d hits saved (rate %d, min %ds), Got: %d", test.expected, test.checks, test.rate, test.interval, saved)
+		}
+	}
+}
+
+// TestCheckHttpServerName examines CheckHttp() sending the TLSServerName with SNI independent of the HostHeader
+func TestCheckHttpServerName(t *testing.T) {
+	utils.InitEnvs()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.ServerName + " " + r.Host))
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	tests := []struct {
+		serverName string
+		hostHeader string
+		expected   string
+	}{
+		{"", "", " " + host},
+		{"", "status.example.com", "status.example.com status.example.com"},
+		{"sni.example.com", "", "sni.example.com " + host},
+		{"sni.example.com", "status.example.com", "sni.example.com status.example.com"},
+	}
+	for _, test := range tests {
+		s := &Service{
+			Name:           "HTTP Server Name",
+			Domain:         server.URL,
+			ExpectedStatus: http.StatusOK,
+			Type:           "http",
+			Method:         "GET",
+			Timeout:        2,
+			TLSServerName:  test.serverName,
+			HostHeader:     test.hostHeader,
+		}
+		CheckHttp(s, false)
+		if s.LastResponse != test.expected {
+			t.Errorf("Expected '%v' for server name '%v' and host '%v', Got: '%v'", test.expected, test.serverName, test.hostHeader, s.LastResponse)
+		}
+	}
+}
+
+// TestValidateWarnings examines Validate() rejecting unsupported types and ports, and Warnings() for overlapping checks
+func TestValidateWarnings(t *testing.T) {
+	s := &Service{Name: "Validate", Domain: "example.com", Type: "http", Interval: 30, Timeout: 10}
+	if err := s.Validate(); err != nil {
+		t.Errorf("Expected no error, Got: '%v'", err)
+	}
+	if warnings := s.Warnings(); len(warnings) != 0 {
+		t.Errorf("Expected no warnings, Got: '%v'", warnings)
+	}
+
+	s.Timeout = 30
+	if warnings := s.Warnings(); len(warnings) != 1 {
+		t.Errorf("Expected a warning for a timeout equal to the interval, Got: '%v'", warnings)
+	}
+	s.Interval, s.IntervalUnit = 500, "ms"
+	if warnings := s.Warnings(); len(warnings) != 1 {
+		t.Errorf("Expected a warning for a timeout over the interval, Got: '%v'", warnings)
+	}
+
+	s.Type = "ftp"
+	if err := s.Validate(); err == nil {
+		t.Errorf("Expected an error for an unsupported type")
+	}
+	s.Type, s.Port = "tcp", 70000
+	if err := s.Validate(); err == nil {
+		t.Errorf("Expected an error for a port out of range")
+	}
+}
+
+// TestCheckHttpHeaders examines CheckHttp() sending the HttpHeaders with values containing commas and semicolons
+func TestCheckHttpHeaders(t *testing.T) {
+	utils.InitEnvs()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Accept") + "|" + r.Header.Get("Cookie")))
+	}))
+	defer server.Close()
+
+	s := &Service{
+		Name:           "HTTP Headers",
+		Domain:         server.URL,
+		ExpectedStatus: http.StatusOK,
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        2,
+		Headers:        null.NewNullString("Accept=text/plain"),
+		HttpHeaders: HttpHeaders{
+			{Key: "Accept", Value: "text/html, application/xml;q=0.9"},
+			{Key: "Cookie", Value: "a=1; b=2"},
+		},
+	}
+	CheckHttp(s, false)
+	if s.LastResponse != "text/html, application/xml;q=0.9|a=1; b=2" {
+		t.Errorf("Expected the typed headers to be sent, Got: '%v'", s.LastResponse)
+	}
+
+	s.HttpHeaders = nil
+	CheckHttp(s, false)
+	if s.LastResponse != "text/plain|" {
+		t.Errorf("Expected the legacy headers to be sent, Got: '%v'", s.LastResponse)
+	}
+
+	legacy := parseHeaders("Authorization=Bearer abc=,X-Empty,=value,Accept=text/html")
+	expected := HttpHeaders{{Key: "Authorization", Value: "Bearer abc="}, {Key: "Accept", Value: "text/html"}}
+	if !reflect.DeepEqual(legacy, expected) {
+		t.Errorf("Expected legacy headers %v, Got: %v", expected, legacy)
+	}
+}
+
+// TestHttpHeadersValue examines HttpHeaders round tripping through the database value and JSON
+func TestHttpHeadersValue(t *testing.T) {
+	headers := HttpHeaders{{Key: "Accept", Value: "text/html, application/xml"}, {Key: "Cookie", Value: "a=1; b=2"}}
+	value, err := headers.Value()
+	if err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	var scanned HttpHeaders
+	if err := scanned.Scan([]byte(value.(string))); err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	if !reflect.DeepEqual(headers, scanned) {
+		t.Errorf("Expected scanned headers %v, Got: %v", headers, scanned)
+	}
+	if err := scanned.Scan(""); err != nil || scanned != nil {
+		t.Errorf("Expected no headers for an empty column, Got: %v, '%v'", scanned, err)
+	}
+
+	data, err := json.Marshal(&Service{HttpHeaders: headers})
+	if err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	var s Service
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	if !reflect.DeepEqual(headers, s.HttpHeaders) {
+		t.Errorf("Expected JSON headers %v, Got: %v", headers, s.HttpHeaders)
+	}
+}
+
+// TestCheckHttpQuorum examines CheckHttp() being online when the Quorum of the Endpoints are healthy
+func TestCheckHttpQuorum(t *testing.T) {
+	utils.InitEnvs()
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer ok.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	tests := []struct {
+		endpoints []string
+		quorum    int
+		online    bool
+	}{
+		{[]string{ok.URL, ok.URL, failing.URL}, 2, true},
+		{[]string{ok.URL, ok.URL, failing.URL}, 3, false},
+		{[]string{ok.URL, ok.URL, failing.URL}, 0, false},
+		{[]string{ok.URL, ok.URL}, 0, true},
+		{[]string{failing.URL, failing.URL}, 1, false},
+	}
+	for _, test := range tests {
+		s := &Service{
+			Name:           "HTTP Quorum",
+			Domain:         failing.URL,
+			ExpectedStatus: http.StatusOK,
+			Type:           "http",
+			Method:         "GET",
+			Timeout:        2,
+			Endpoints:      test.endpoints,
+			Quorum:         test.quorum,
+		}
+		CheckHttp(s, false)
+		if s.Online != test.online {
+			t.Errorf("Expected online to be %v for a quorum of %d with %d endpoints, Got: %v", test.online, test.quorum, len(test.endpoints), s.Online)
+		}
+		if test.online && s.LastResponse != "ok" {
+			t.Errorf("Expected the response of a healthy endpoint, Got: '%v'", s.LastResponse)
+		}
+	}
+}
+
+// TestSmoothLatency examines smoothLatency() keeping an exponential moving average that starts over after a long outage
+func TestSmoothLatency(t *testing.T) {
+	s := &Service{Interval: 30}
+	s.smoothLatency(1000, 0)
+	if s.SmoothedLatency != 1000 {
+		t.Errorf("Expected the first latency to start the average, Got: %d", s.SmoothedLatency)
+	}
+	s.smoothLatency(2000, 0)
+	if s.SmoothedLatency != 1300 {
+		t.Errorf("Expected the default alpha of 0.3, Got: %d", s.SmoothedLatency)
+	}
+
+	s.LatencyAlpha = 0.5
+	s.smoothLatency(2300, 0)
+	if s.SmoothedLatency != 1800 {
+		t.Errorf("Expected an alpha of 0.5, Got: %d", s.SmoothedLatency)
+	}
+
+	s.smoothLatency(5000, 2*time.Minute)
+	if s.SmoothedLatency != 3400 {
+		t.Errorf("Expected a short outage to keep the average, Got: %d", s.SmoothedLatency)
+	}
+	s.smoothLatency(9000, 6*time.Minute)
+	if s.SmoothedLatency != 9000 {
+		t.Errorf("Expected a long outage to start the average over, Got: %d", s.SmoothedLatency)
+	}
+}
+
+// TestCheckHttpExpectedRedirect examines CheckHttp() matching the Location of a redirect with the ExpectedRedirect
+func TestCheckHttpExpectedRedirect(t *testing.T) {
+	utils.InitEnvs()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ok" {
+			w.Write([]byte("ok"))
+			return
+		}
+		http.Redirect(w, r, "https://secure.example.com"+r.URL.Path, http.StatusMovedPermanently)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path     string
+		status   int
+		expected string
+		online   bool
+	}{
+		{"/login", http.StatusMovedPermanently, `^https://secure\.example\.com/`, true},
+		{"/login", http.StatusMovedPermanently, `^https://secure\.example\.com/login$`, true},
+		{"/login", http.StatusMovedPermanently, `^https://www\.example\.com/`, false},
+		{"/login", http.StatusMovedPermanently, `^http://`, false},
+		{"/ok", http.StatusOK, `^https://`, true},
+	}
+	for _, test := range tests {
+		s := &Service{
+			Name:             "HTTP Expected Redirect",
+			Domain:           server.URL + test.path,
+			ExpectedStatus:   test.status,
+			Type:             "http",
+			Method:           "GET",
+			Timeout:          2,
+			ExpectedRedirect: test.expected,
+		}
+		CheckHttp(s, false)
+		if s.Online != test.online {
+			t.Errorf("Expected online to be %v for redirect of %v matching '%v', Got: %v", test.online, test.path, test.expected, s.Online)
+		}
+	}
+
+	s := &Service{ExpectedRedirect: "/login"}
+	if err := s.validateExpectedRedirect(); err != nil {
+		t.Errorf("Expected the redirect regex to be valid, Got: '%v'", err)
+	}
+	first, _ := s.redirectRegexp()
+	if re, _ := s.redirectRegexp(); re != first {
+		t.Errorf("Expected the compiled redirect regex to be cached")
+	}
+	s.ExpectedRedirect = "/login("
+	if err := s.validateExpectedRedirect(); err == nil {
+		t.Errorf("Expected an invalid redirect regex to be rejected")
+	}
+}
+
+// TestCheckMqtt examines CheckMqtt() connecting with credentials, subscribing and matching the published message
+func TestCheckMqtt(t *testing.T) {
+	listener := mqttBroker(t, "statping", "password")
+	defer listener.Close()
+
+	s := &Service{
+		Name:          "MQTT",
+		Domain:        "127.0.0.1",
+		Port:          listener.Addr().(*net.TCPAddr).Port,
+		Type:          "mqtt",
+		Timeout:       2,
+		BasicAuthUser: null.NewNullString("statping"),
+		BasicAuthPass: null.NewNullString("password"),
+	}
+	if _, err := CheckMqtt(s, false); err != nil || !s.Online {
+		t.Errorf("Expected MQTT service to be online, Got: '%v'", err)
+	}
+	if s.LastResponse != "CONNACK accepted" {
+		t.Errorf("Expected CONNACK response, Got: '%v'", s.LastResponse)
+	}
+
+	s.Online = false
+	s.MqttSubscribe = "statping/health"
+	s.MqttPublish = "statping/health"
+	s.PostData = null.NewNullString("pong")
+	s.Expected = null.NewNullString("^pong$")
+	if _, err := CheckMqtt(s, false); err != nil || !s.Online {
+		t.Errorf("Expected MQTT service to be online, Got: '%v'", err)
+	}
+	if s.LastResponse != "pong" {
+		t.Errorf("Expected published message as response, Got: '%v'", s.LastResponse)
+	}
+
+	s.Online = false
+	s.Expected = null.NewNullString("^ping$")
+	if _, err := CheckMqtt(s, false); s.Online {
+		t.Errorf("Expected offline for a message that did not match, Got: '%v'", err)
+	}
+
+	s.BasicAuthPass = null.NewNullString("wrong")
+	if _, err := CheckMqtt(s, false); err == nil || s.Online {
+		t.Errorf("Expected error for refused credentials")
+	}
+}
+
+// mqttBroker will start a minimal MQTT broker that accepts the credentials, grants subscriptions
+// and sends published messages back to the client
+func mqttBroker(t *testing.T, username, password string) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	connect := string(mqttConnectPacket("", username, password, 60))
+	credentials := connect[len(connect)-len(username)-len(password)-4:]
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					packet, err := mqttRead(reader)
+					if err != nil {
+						return
+					}
+					switch packet.header & 0xf0 {
+					case mqttConnect:
+						code := byte(0)
+						if !strings.HasSuffix(string(packet.payload), credentials) {
+							code = 4
+						}
+						conn.Write([]byte{mqttConnack, 2, 0, code})
+					case mqttSubscribe & 0xf0:
+						conn.Write([]byte{mqttSuback, 3, packet.payload[0], packet.payload[1], 0})
+					case mqttPublish:
+						conn.Write(mqttEncode(mqttPublish, packet.payload))
+					case mqttDisconnect:
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+	return listener
+}
+
+// TestActiveSchedule examines activeSchedule() with weekday ranges and windows that end on the next day
+func TestActiveSchedule(t *testing.T) {
+	utils.InitEnvs()
+	// 2020-05-01 is a Friday
+	friday := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	s := &Service{
+		ActiveSchedule:   "mon-fri 09:00-17:00; sat 22:00-02:00",
+		ScheduleTimezone: "UTC",
+	}
+	tests := []struct {
+		now    time.Time
+		active bool
+		next   time.Time
+	}{
+		{friday.Add(8 * time.Hour), false, friday.Add(9 * time.Hour)},
+		{friday.Add(9 * time.Hour), true, time.Time{}},
+		{friday.Add(16*time.Hour + 59*time.Minute), true, time.Time{}},
+		{friday.Add(17 * time.Hour), false, friday.Add(46 * time.Hour)},
+		{friday.Add(47 * time.Hour), true, time.Time{}},
+		{friday.Add(49*time.Hour + 30*time.Minute), true, time.Time{}},
+		{friday.Add(50 * time.Hour), false, friday.Add(81 * time.Hour)},
+	}
+	for _, test := range tests {
+		active, next := s.activeSchedule(test.now)
+		if active != test.active || !next.Equal(test.next) {
+			t.Errorf("Expected active: '%v' until '%v' for %v, Got: '%v' until '%v'", test.active, test.next, test.now, active, next)
+		}
+	}
+
+	s.ActiveSchedule = ""
+	if active, _ := s.activeSchedule(friday); !active {
+		t.Errorf("Expected a service without a schedule to be active")
+	}
+
+	for _, schedule := range []string{"mon-fri", "mon-fri 09:00", "weekdays 09:00-17:00", "mon 9-17", "mon 25:00-26:00", "mon 09:00-09:00"} {
+		if _, err := parseSchedule(schedule); err == nil {
+			t.Errorf("Expected error for schedule '%v'", schedule)
+		}
+	}
+	if windows, err := parseSchedule("sat-mon,wed 00:00-24:00"); err != nil || len(windows) != 1 {
+		t.Errorf("Expected one window, Got: '%v'", err)
+	} else if days := windows[0].days; !days[time.Saturday] || !days[time.Sunday] || !days[time.Monday] || days[time.Tuesday] || !days[time.Wednesday] {
+		t.Errorf("Expected saturday to monday and wednesday, Got: '%v'", days)
+	}
+}
+
+// TestCheckDnssec examines checkDnssec() telling apart authenticated, unsigned and bogus answers
+func TestCheckDnssec(t *testing.T) {
+	tests := []struct {
+		mode     string
+		expected string
+	}{
+		{"signed", ""},
+		{"unsigned", "signatures are missing"},
+		{"unauthenticated", "signatures are invalid"},
+		{"bogus", "signatures are invalid"},
+	}
+	for _, test := range tests {
+		conn := dnssecServer(t, test.mode)
+		s := &Service{
+			Name:          "DNSSEC",
+			Domain:        "example.com",
+			Type:          "dns",
+			DnsRecordType: "A",
+			DnsResolver:   null.NewNullString("udp://" + conn.LocalAddr().String()),
+			Timeout:       2,
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		err := checkDnssec(ctx, s)
+		cancel()
+		conn.Close()
+		if test.expected == "" && err != nil {
+			t.Errorf("Expected %v answer to be authenticated, Got: '%v'", test.mode, err)
+		}
+		if test.expected != "" && (err == nil || !strings.Contains(err.Error(), test.expected)) {
+			t.Errorf("Expected '%v' for %v answer, Got: '%v'", test.expected, test.mode, err)
+		}
+	}
+}
+
+// dnssecServer will start a nameserver that answers with the A record of the query, signed and authenticated
+// depending on the mode. Bogus answers fail unless checking is disabled, like a validating resolver.
+func dnssecServer(t *testing.T, mode string) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var parser dnsmessage.Parser
+			header, err := parser.Start(buf[:n])
+			if err != nil {
+				continue
+			}
+			question, _ := parser.Question()
+			checkingDisabled := binary.BigEndian.Uint16(buf[2:])&dnsBitCD != 0
+
+			header.Response = true
+			if mode == "bogus" && !checkingDisabled {
+				header.RCode = dnsmessage.RCodeServerFailure
+			}
+			builder := dnsmessage.NewBuilder(nil, header)
+			builder.StartQuestions()
+			builder.Question(question)
+			builder.StartAnswers()
+			if header.RCode == dnsmessage.RCodeSuccess {
+				builder.AResource(dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: 60}, dnsmessage.AResource{A: [4]byte{93, 184, 216, 34}})
+			}
+			response, _ := builder.Finish()
+			if header.RCode == dnsmessage.RCodeSuccess && mode != "unsigned" {
+				// append a RRSIG answer pointing to the question name, dnsmessage can not build one
+				response = append(response, 0xc0, 12, 0, 46, 0, 1, 0, 0, 0, 60, 0, 4, 1, 2, 3, 4)
+				binary.BigEndian.PutUint16(response[6:], 2)
+			}
+			if header.RCode == dnsmessage.RCodeSuccess && mode != "unauthenticated" && mode != "unsigned" {
+				binary.BigEndian.PutUint16(response[2:], binary.BigEndian.Uint16(response[2:])|dnsBitAD)
+			}
+			conn.WriteTo(response, addr)
+		}
+	}()
+	return conn
+}
+
+// TestSuccessStatus examines successStatus() marking slow or partially healthy checks as degraded
+func TestSuccessStatus(t *testing.T) {
+	s := &Service{DegradedLatency: 0.5, Latency: (200 * time.Millisecond).Microseconds()}
+	if status, reason := s.successStatus(); status != StatusUp || reason != "" {
+		t.Errorf("Expected status '%v', Got: '%v' (%v)", StatusUp, status, reason)
+	}
+
+	s.Latency = (700 * time.Millisecond).Microseconds()
+	if status, _ := s.successStatus(); status != StatusDegraded {
+		t.Errorf("Expected status '%v' for a slow response, Got: '%v'", StatusDegraded, status)
+	}
+
+	s.Latency = 0
+	s.markDegraded("1 of 2 endpoints are healthy")
+	s.markDegraded("another reason")
+	if status, reason := s.successStatus(); status != StatusDegraded || reason != "1 of 2 endpoints are healthy" {
+		t.Errorf("Expected the first degraded reason, Got: '%v' (%v)", status, reason)
+	}
+	if status, _ := s.successStatus(); status != StatusUp {
+		t.Errorf("Expected the degraded reason to be reset after a check, Got: '%v'", status)
+	}
+}
+
+// TestCheckServiceRetryStatuses examines CheckService() only retrying the RetryStatuses of a HTTP service
+func TestCheckServiceRetryStatuses(t *testing.T) {
+	utils.InitEnvs()
+	var requests int
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	s := &Service{
+		Name:           "HTTP Retry Statuses",
+		Domain:         server.URL,
+		ExpectedStatus: 200,
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        2,
+		RetryCount:     3,
+		RetryInterval:  1,
+		RetryStatuses:  "502-504",
+	}
+	s.CheckService(false)
+	if s.Online || requests != 4 {
+		t.Errorf("Expected 4 requests for a retried status, Got online: '%v', requests: '%v'", s.Online, requests)
+	}
+
+	requests = 0
+	status = http.StatusInternalServerError
+	s.CheckService(false)
+	if s.Online || requests != 2 {
+		t.Errorf("Expected the final attempt right after a status that is not retried, Got online: '%v', requests: '%v'", s.Online, requests)
+	}
+}
+
+// TestSubscribeEvents examines the check events being sent to subscribers without blocking on a slow subscriber
+func TestSubscribeEvents(t *testing.T) {
+	events, unsubscribe := SubscribeEvents(1)
+	slow, unsubscribeSlow := SubscribeEvents(1)
+	defer unsubscribeSlow()
+
+	s := &Service{Id: 5, Online: true, Status: StatusUp, Latency: 1200}
+	publishEvent(s.checkEvent("", utils.Now()))
+	select {
+	case event := <-events:
+		if event.Service != 5 || !event.Online || event.Latency != 1200 || event.Status != StatusUp {
+			t.Errorf("Expected the event of the check, Got: '%+v'", event)
+		}
+	default:
+		t.Fatalf("Expected an event for the subscriber")
+	}
+
+	// the slow subscriber has a full buffer, publishing must not block
+	s.Online = false
+	publishEvent(s.checkEvent("issue", utils.Now()))
+	if event := <-events; event.Online || event.Issue != "issue" {
+		t.Errorf("Expected the event of the failure, Got: '%+v'", event)
+	}
+	if len(slow) != 1 {
+		t.Errorf("Expected the slow subscriber to only have the first event, Got: %d", len(slow))
+	}
+
+	unsubscribe()
+	unsubscribe()
+	publishEvent(s.checkEvent("", utils.Now()))
+	if len(events) != 0 {
+		t.Errorf("Expected no events after unsubscribing")
+	}
+}
+
+// TestCheckHttpSkipHostname examines CheckHttp() verifying the certificate chain without the hostname
+func TestCheckHttpSkipHostname(t *testing.T) {
+	utils.InitEnvs()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	root := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	tests := []struct {
+		root         string
+		skipHostname bool
+		online       bool
+	}{
+		{string(root), false, false},
+		{string(root), true, true},
+		{"", true, false},
+	}
+	for _, test := range tests {
+		s := &Service{
+			Name:            "HTTP Skip Hostname",
+			Domain:          server.URL,
+			ExpectedStatus:  http.StatusOK,
+			Type:            "http",
+			Method:          "GET",
+			Timeout:         2,
+			VerifySSL:       null.NewNullBool(true),
+			TLSServerName:   "status.statping.com",
+			TLSCertRoot:     null.NewNullString(test.root),
+			TLSSkipHostname: null.NewNullBool(test.skipHostname),
+		}
+		CheckHttp(s, false)
+		if s.Online != test.online {
+			t.Errorf("Expected online to be %v with a root: %v and skipping the hostname: %v, Got: %v", test.online, test.root != "", test.skipHostname, s.Online)
+		}
+	}
+}
+
+// TestCheckPortClosed examines CheckTcp() and CheckUdp() with ExpectClosed treating an open port as a failure
+func TestCheckPortClosed(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	openPort := listener.Addr().(*net.TCPAddr).Port
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+	defer listener.Close()
+
+	s := &Service{
+		Name:         "TCP Closed",
+		Domain:       "127.0.0.1",
+		Port:         closedPort,
+		Type:         "tcp",
+		Timeout:      2,
+		ExpectClosed: null.NewNullBool(true),
+	}
+	if _, err := CheckTcp(s, false); err != nil || !s.Online {
+		t.Errorf("Expected a refused port to be online, Got: '%v'", err)
+	}
+
+	s.Online = false
+	s.Port = openPort
+	if _, err := CheckTcp(s, false); s.Online {
+		t.Errorf("Expected an open port to be offline, Got: '%v'", err)
+	}
+
+	// the port is checked from the SourceIP like an open port
+	s.Port = closedPort
+	s.SourceIP = "192.0.2.1"
+	if _, err := CheckTcp(s, false); err == nil || s.Online {
+		t.Errorf("Expected an error for the source address %v that is not on this host", s.SourceIP)
+	}
+	s.SourceIP = ""
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	udpPort := udp.LocalAddr().(*net.UDPAddr).Port
+	udp.Close()
+	s.Type = "udp"
+	s.Port = udpPort
+	s.PostData = null.NewNullString("ping")
+	if _, err := CheckUdp(s, false); err != nil || !s.Online {
+		t.Errorf("Expected a UDP port without a listener to be online, Got: '%v'", err)
+	}
+}
+
+// TestCheckGrpcMetadata examines CheckGrpc() sending the GrpcMetadata to a health service requiring an API key
+func TestCheckGrpcMetadata(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	var received metadata.MD
+	auth := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		received, _ = metadata.FromIncomingContext(ctx)
+		if keys := received.Get("x-api-key"); len(keys) == 0 || keys[0] != "secret" {
+			return nil, status.Error(codes.Unauthenticated, "missing api key")
+		}
+		return handler(ctx, req)
+	}
+	server := grpc.NewServer(grpc.UnaryInterceptor(auth))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(listener)
+	defer server.Stop()
+
+	s := &Service{
+		Name:            "GRPC Metadata",
+		Domain:          "127.0.0.1",
+		Port:            listener.Addr().(*net.TCPAddr).Port,
+		Type:            "grpc",
+		Timeout:         2,
+		GrpcHealthCheck: null.NewNullBool(true),
+	}
+	CheckGrpc(s, false)
+	if s.Online {
+		t.Errorf("Expected service to be offline without the api key")
+	}
+
+	s.GrpcMetadata = HttpHeaders{{Key: "X-API-Key", Value: "secret"}, {Key: "x-tenant", Value: "a"}, {Key: "x-tenant", Value: "b"}}
+	CheckGrpc(s, false)
+	if !s.Online {
+		t.Errorf("Expected service to be online with the api key, Got: '%v'", s.LastResponse)
+	}
+	if tenants := received.Get("x-tenant"); !reflect.DeepEqual(tenants, []string{"a", "b"}) {
+		t.Errorf("Expected metadata x-tenant 'a' and 'b', Got: '%v'", tenants)
+	}
+}
+
+// TestCheckHttpExpectedProtocol examines CheckHttp() failing when the response is not the ExpectedProtocol
+func TestCheckHttpExpectedProtocol(t *testing.T) {
+	utils.InitEnvs()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	server := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	defer server.Close()
+
+	tests := []struct {
+		expected string
+		h2c      bool
+		online   bool
+	}{
+		{"", false, true},
+		{"1.1", false, true},
+		{"2", false, false},
+		{"2", true, true},
+		{"1.1", true, false},
+	}
+	for _, test := range tests {
+		s := &Service{
+			Name:             "HTTP Expected Protocol",
+			Domain:           server.URL,
+			ExpectedStatus:   http.StatusOK,
+			Type:             "http",
+			Method:           "GET",
+			Timeout:          2,
+			ForceH2C:         null.NewNullBool(test.h2c),
+			ExpectedProtocol: test.expected,
+		}
+		CheckHttp(s, false)
+		if s.Online != test.online {
+			t.Errorf("Expected online to be %v with protocol '%v' expecting '%v'", test.online, s.Protocol, test.expected)
+		}
+	}
+
+	s := &Service{Name: "HTTP Expected Protocol", Domain: server.URL, Type: "http", Interval: 30, ExpectedProtocol: "3"}
+	if err := s.Validate(); err == nil {
+		t.Errorf("Expected an error for the unsupported protocol '%v'", s.ExpectedProtocol)
+	}
+}
+
+// TestCheckHttpReadLimit examines CheckHttp() with a ReadLimit not waiting for a stream that never ends
+func TestCheckHttpReadLimit(t *testing.T) {
+	utils.InitEnvs()
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("event: ready\n\n"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	tests := []struct {
+		limit    int
+		expected string
+		online   bool
+	}{
+		{1024, "ready", true},
+		{5, `^event`, true},
+		{5, "ready", false},
+	}
+	for _, test := range tests {
+		s := &Service{
+			Name:           "HTTP Read Limit",
+			Domain:         server.URL,
+			ExpectedStatus: http.StatusOK,
+			Expected:       null.NewNullString(test.expected),
+			Type:           "http",
+			Method:         "GET",
+			Timeout:        1,
+			ReadLimit:      test.limit,
+		}
+		CheckHttp(s, false)
+		if s.Online != test.online {
+			t.Errorf("Expected online to be %v with read limit %d expecting '%v', Got response: '%v'", test.online, test.limit, test.expected, s.LastResponse)
+		}
+	}
+}
+
+// TestWarmingUp examines recordFailure() not recording failures during the warmup period of a service
+func TestWarmingUp(t *testing.T) {
+	utils.InitEnvs()
+	s := &Service{Name: "Warmup", Online: true}
+	if s.warmingUp() {
+		t.Errorf("Expected a service that did not start checking to not be warming up")
+	}
+
+	s.warmupUntil = utils.Now().Add(warmupPeriod())
+	recordFailure(s, &failures.Failure{Issue: "dial tcp: lookup example.com: no such host", Reason: "lookup"})
+	if len(s.Failures) != 0 || s.CurrentFailureCount != 0 || !s.Online {
+		t.Errorf("Expected the failure to not be recorded during the warmup period, Got %d failures", len(s.Failures))
+	}
+
+	s.warmupUntil = utils.Now().Add(-time.Second)
+	if s.warmingUp() {
+		t.Errorf("Expected the warmup period to be over")
+	}
+}
+
+// TestCheckHttpExpectedSource examines CheckHttp() matching the response with an expected response from a file or URL
+func TestCheckHttpExpectedSource(t *testing.T) {
+	utils.InitEnvs()
+	body := "<html>golden</html>\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/expected" {
+			w.Write([]byte(`golden`))
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	golden, err := ioutil.TempFile("", "statping-golden")
+	if err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	defer os.Remove(golden.Name())
+	golden.WriteString(body)
+	golden.Close()
+
+	s := &Service{
+		Name:           "HTTP Expected Source",
+		Domain:         server.URL,
+		ExpectedStatus: http.StatusOK,
+		Expected:       null.NewNullString(golden.Name()),
+		ExpectedSource: "file",
+		ExpectedExact:  null.NewNullBool(true),
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        2,
+	}
+	CheckHttp(s, false)
+	if !s.Online {
+		t.Errorf("Expected the response to equal the golden file, Got: '%v'", s.LastResponse)
+	}
+
+	ioutil.WriteFile(golden.Name(), []byte("<html>changed</html>\n"), 0644)
+	s.Online = false
+	CheckHttp(s, false)
+	if !s.Online {
+		t.Errorf("Expected the golden file to be cached until it is read again")
+	}
+	s.expectedCache.expires = utils.Now()
+	s.Online = false
+	CheckHttp(s, false)
+	if s.Online || s.expectedCache.body != "<html>changed</html>\n" {
+		t.Errorf("Expected the changed golden file to be read again and not equal the response")
+	}
+
+	s.Expected = null.NewNullString(server.URL + "/expected")
+	s.ExpectedSource = "url"
+	s.ExpectedExact = null.NewNullBool(false)
+	s.Online = false
+	CheckHttp(s, false)
+	if !s.Online {
+		t.Errorf("Expected the response to match the regex from the URL, Got: '%v'", s.LastResponse)
+	}
+
+	s.Expected = null.NewNullString("ftp://example.com/golden")
+	if err := s.validateExpectedSource(); err == nil {
+		t.Errorf("Expected an error for the '%v' URL", s.Expected.String)
+	}
+}
+
+// TestLatencyAnomaly examines latencyAnomaly() detecting latencies over the baseline once it has enough samples
+func TestLatencyAnomaly(t *testing.T) {
+	s := &Service{AnomalyDeviations: 3, AnomalyMinSamples: 5}
+	for i := 0; i < 5; i++ {
+		if reason := s.latencyAnomaly(int64(10000 + i%2*1000)); reason != "" {
+			t.Errorf("Expected no anomaly before the minimum samples, Got: '%v'", reason)
+		}
+	}
+	if reason := s.latencyAnomaly(11500); reason != "" {
+		t.Errorf("Expected no anomaly within the deviations, Got: '%v'", reason)
+	}
+	if reason := s.latencyAnomaly(50000); reason == "" {
+		t.Errorf("Expected a 50 ms response to be an anomaly with a baseline of %v", humanMicro(int64(s.latencyMean)))
+	}
+	if s.latencySamples != 7 {
+		t.Errorf("Expected every latency to be added to the baseline, Got: %d samples", s.latencySamples)
+	}
+
+	disabled := &Service{}
+	if reason := disabled.latencyAnomaly(50000); reason != "" || disabled.latencySamples != 0 {
+		t.Errorf("Expected no detection without AnomalyDeviations")
+	}
+}
+
+// TestCheckHttpPreRequest examines CheckHttp() sending the session cookie of the pre-request with the check
+func TestCheckHttpPreRequest(t *testing.T) {
+	utils.InitEnvs()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			body, _ := ioutil.ReadAll(r.Body)
+			if r.Method != http.MethodPost || string(body) != `{"password": "secret"}` {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc123", Path: "/"})
+			w.Write([]byte("logged in"))
+		default:
+			if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "abc123" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Write([]byte("dashboard"))
+		}
+	}))
+	defer server.Close()
+
+	s := &Service{
+		Name:           "HTTP Pre-Request",
+		Domain:         server.URL + "/dashboard",
+		ExpectedStatus: http.StatusOK,
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        2,
+		PreRequestURL:  server.URL + "/login",
+		PreRequestData: null.NewNullString(`{"password": "secret"}`),
+	}
+	CheckHttp(s, false)
+	if !s.Online || s.LastResponse != "dashboard" {
+		t.Errorf("Expected the check to be sent with the session cookie, Got: %v '%v'", s.LastStatusCode, s.LastResponse)
+	}
+
+	s.Online = false
+	s.PreRequestData = null.NewNullString(`{"password": "wrong"}`)
+	if _, err := CheckHttp(s, false); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected the failed pre-request to return an error, Got: '%v'", err)
+	}
+}
+
+// TestCheckSourceIP examines CheckHttp() and CheckTcp() sending from the SourceIP and failing if it can't be bound
+func TestCheckSourceIP(t *testing.T) {
+	utils.InitEnvs()
+	var remote string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remote, _, _ = net.SplitHostPort(r.RemoteAddr)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	port, _ := strconv.Atoi(server.URL[strings.LastIndex(server.URL, ":")+1:])
+
+	s := &Service{
+		Name:           "Source IP",
+		Domain:         server.URL,
+		ExpectedStatus: http.StatusOK,
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        2,
+		SourceIP:       "127.0.0.1",
+	}
+	CheckHttp(s, false)
+	if !s.Online || remote != "127.0.0.1" {
+		t.Errorf("Expected the request to be sent from %v, Got: '%v'", s.SourceIP, remote)
+	}
+
+	tcp := &Service{Name: "Source IP", Domain: "127.0.0.1", Port: port, Type: "tcp", Timeout: 2, SourceIP: "127.0.0.1"}
+	CheckTcp(tcp, false)
+	if !tcp.Online {
+		t.Errorf("Expected the TCP service to be online from %v", tcp.SourceIP)
+	}
+
+	s.SourceIP = "192.0.2.1"
+	if _, err := CheckHttp(s, false); err == nil {
+		t.Errorf("Expected an error for the source address %v that is not on this host", s.SourceIP)
+	}
+	tcp.SourceIP = "192.0.2.1"
+	if _, err := CheckTcp(tcp, false); err == nil {
+		t.Errorf("Expected an error for the source address %v that is not on this host", tcp.SourceIP)
+	}
+}
+
+// TestCheckAllNow examines CheckAllNow() only making the running services check now
+func TestCheckAllNow(t *testing.T) {
+	running := &Service{Id: 901, Name: "Running"}
+	running.Start()
+	defer running.Close()
+	stopped := &Service{Id: 902, Name: "Stopped"}
+
+	saved := allServices
+	allServices = map[int64]*Service{running.Id: running, stopped.Id: stopped}
+	defer func() { allServices = saved }()
+
+	if count := CheckAllNow(); count != 1 {
+		t.Errorf("Expected 1 running service to be checked, Got: %d", count)
+	}
+	select {
+	case done := <-running.forced:
+		close(done)
+	case <-time.After(time.Second):
+		t.Errorf("Expected the running service to be forced to check")
+	}
+}
+
+// TestCheckHttpExpectedFlags examines CheckHttp() matching the expected regex with the CaseInsensitive and Multiline flags
+func TestCheckHttpExpectedFlags(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Status: OK\nDatabase: UP\n"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		expected        string
+		caseInsensitive bool
+		multiline       bool
+		online          bool
+	}{
+		{"database: up", false, false, false},
+		{"database: up", true, false, true},
+		{"^Database: UP$", false, false, false},
+		{"^Database: UP$", false, true, true},
+		{"^database: up$", true, true, true},
+	}
+	for _, test := range tests {
+		s := &Service{
+			Name:            "HTTP Expected Flags",
+			Domain:          server.URL,
+			ExpectedStatus:  http.StatusOK,
+			Expected:        null.NewNullString(test.expected),
+			CaseInsensitive: null.NewNullBool(test.caseInsensitive),
+			Multiline:       null.NewNullBool(test.multiline),
+			Type:            "http",
+			Method:          "GET",
+			Timeout:         2,
+		}
+		CheckHttp(s, false)
+		if s.Online != test.online {
+			t.Errorf("Expected %v with flags '%v' to match: %v, Got: '%v'", test.expected, s.expectedFlags(), test.online, s.Online)
+		}
+	}
+
+	s := &Service{Type: "http", Expected: null.NewNullString("status: (ok")}
+	if err := s.validateExpectedRegex(); err == nil {
+		t.Errorf("Expected an invalid regex to be rejected at configuration time")
+	}
+	s.CaseInsensitive = null.NewNullBool(true)
+	re, err := s.expectedRegexp("status: ok")
+	if err != nil || re.String() != "(?i)status: ok" {
+		t.Errorf("Expected the regex to be compiled with the case insensitive flag, Got: '%v' '%v'", re, err)
+	}
+	if cached, _ := s.expectedRegexp("status: ok"); cached != re {
+		t.Errorf("Expected the compiled regex to be reused")
+	}
+}
+
+// TestMatchExpected examines the Expected regex being compiled once and reused by the checks
+func TestMatchExpected(t *testing.T) {
+	s := &Service{Name: "TCP Expected", Type: "tcp", Expected: null.NewNullString(`^\+PONG`)}
+	if err := s.validateExpectedRegex(); err != nil {
+		t.Errorf("Expected no error, Got: '%v'", err)
+	}
+	if match, err := s.matchExpected("+PONG\r\n"); !match || err != nil {
+		t.Errorf("Expected the response to match, Got: %v '%v'", match, err)
+	}
+	compiled := s.expectedRegex
+	s.matchExpected("-ERR\r\n")
+	if s.expectedRegex != compiled {
+		t.Errorf("Expected the compiled regex to be reused between checks")
+	}
+
+	s.Expected = null.NewNullString(`^\+PO(NG`)
+	if err := s.validateExpectedRegex(); err == nil {
+		t.Errorf("Expected an invalid regex to be rejected at configuration time")
+	}
+	if match, err := s.matchExpected("+PONG\r\n"); match || err == nil {
+		t.Errorf("Expected an invalid regex to not match, Got: %v '%v'", match, err)
+	}
+
+	s.Type = "grpc"
+	if err := s.validateExpectedRegex(); err != nil {
+		t.Errorf("Expected the expected response of a gRPC service to not be a regex, Got: '%v'", err)
+	}
+}
+
+// TestCheckHttpTLSHealth examines CheckHttp() verifying the certificate chain without failing the check
+func TestCheckHttpTLSHealth(t *testing.T) {
+	utils.InitEnvs()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	s := &Service{
+		Name:           "HTTP TLS Health",
+		Domain:         server.URL,
+		ExpectedStatus: http.StatusOK,
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        2,
+	}
+	CheckHttp(s, false)
+	if !s.Online {
+		t.Fatalf("Expected a self-signed certificate to be online without VerifySSL")
+	}
+	if s.TLSHealth == nil || s.TLSHealth.ChainValid || s.TLSHealth.ChainError == "" {
+		t.Fatalf("Expected the self-signed certificate chain to be invalid, Got: '%+v'", s.TLSHealth)
+	}
+	if s.TLSHealth.Issuer == "" || !strings.Contains(strings.Join(s.TLSHealth.SANs, ","), "127.0.0.1") {
+		t.Errorf("Expected the issuer and the SANs of the certificate, Got: '%v' '%v'", s.TLSHealth.Issuer, s.TLSHealth.SANs)
+	}
+	checked := s.TLSHealth
+	CheckHttp(s, false)
+	if s.TLSHealth != checked {
+		t.Errorf("Expected the unchanged certificate chain to not be verified again")
+	}
+
+	certs := []*x509.Certificate{server.Certificate()}
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	if health := verifyChain(certs, roots, "127.0.0.1", time.Now()); !health.ChainValid {
+		t.Errorf("Expected the chain to be valid with the Root CA, Got: '%v'", health.ChainError)
+	}
+	if health := verifyChain(certs, roots, "statping.invalid", time.Now()); health.ChainValid {
+		t.Errorf("Expected the chain to be invalid for a host that is not in the SANs")
+	}
+	if health := verifyChain(certs, roots, "127.0.0.1", server.Certificate().NotAfter.Add(time.Hour)); health.ChainValid {
+		t.Errorf("Expected the chain to be invalid after the certificate expired")
+	}
+}
+
+// TestIntervalDown examines the interval between checks while a service is offline
+func TestIntervalDown(t *testing.T) {
+	tests := []struct {
+		down     int
+		unit     string
+		backoff  int
+		expected time.Duration
+	}{
+		{0, "", 0, 30 * time.Second},
+		{5, "", 0, 5 * time.Second},
+		{120, "", 0, 120 * time.Second},
+		{500, "ms", 0, 500 * time.Millisecond},
+		{5, "", 60, 60 * time.Second},
+		{300, "", 60, 300 * time.Second},
+	}
+	for _, test := range tests {
+		s := &Service{Interval: 30, IntervalUnit: test.unit, IntervalDown: test.down, FailureThreshold: 1, DownBackoff: test.backoff, CurrentFailureCount: 1}
+		if test.unit == "ms" {
+			s.Interval = 30000
+		}
+		if sleep := s.downBackoff(); sleep != test.expected {
+			t.Errorf("Expected offline interval %v for %d%v, Got: %v", test.expected, test.down, test.unit, sleep)
+		}
+	}
+}
+
+// TestCheckMailbox examines CheckMailbox() reading the greeting and logging in to IMAP and POP3 servers
+func TestCheckMailbox(t *testing.T) {
+	for _, protocol := range []string{"imap", "pop3"} {
+		listener := mailboxServer(t, protocol)
+		defer listener.Close()
+
+		s := &Service{
+			Name:    "Mailbox " + protocol,
+			Domain:  "127.0.0.1",
+			Port:    listener.Addr().(*net.TCPAddr).Port,
+			Type:    protocol,
+			Timeout: 2,
+		}
+		if _, err := CheckMailbox(s, false); err != nil || !s.Online {
+			t.Errorf("Expected %v service to be online, Got: '%v'", protocol, err)
+		}
+		if !strings.Contains(s.LastResponse, "statping ready") {
+			t.Errorf("Expected the %v greeting in the response, Got: '%v'", protocol, s.LastResponse)
+		}
+
+		s.Online = false
+		s.BasicAuthUser = null.NewNullString("statping")
+		s.BasicAuthPass = null.NewNullString("secret")
+		if _, err := CheckMailbox(s, false); err != nil || !s.Online {
+			t.Errorf("Expected %v login to succeed, Got: '%v'", protocol, err)
+		}
+
+		s.Online = false
+		s.BasicAuthPass = null.NewNullString("wrong")
+		if _, err := CheckMailbox(s, false); err == nil || s.Online {
+			t.Errorf("Expected %v login with a wrong password to fail", protocol)
+		}
+	}
+
+	if port, useTLS := (&Service{Type: "imap", VerifySSL: null.NewNullBool(true)}).mailboxPort(); port != 993 || !useTLS {
+		t.Errorf("Expected IMAP with VerifySSL to use TLS on port 993, Got: %d %v", port, useTLS)
+	}
+	if port, useTLS := (&Service{Type: "pop3", Port: 995}).mailboxPort(); port != 995 || !useTLS {
+		t.Errorf("Expected POP3 on port 995 to use TLS, Got: %d %v", port, useTLS)
+	}
+	if imapQuote(`pa"ss\`) != `"pa\"ss\\"` {
+		t.Errorf("Expected the IMAP string to be quoted, Got: %v", imapQuote(`pa"ss\`))
+	}
+}
+
+// mailboxServer will start a minimal IMAP or POP3 server accepting the 'statping' user with the 'secret' password
+func mailboxServer(t *testing.T, protocol string) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				text := textproto.NewConn(conn)
+				if protocol == "imap" {
+					text.PrintfLine("* OK [CAPABILITY IMAP4rev1] statping ready")
+				} else {
+					text.PrintfLine("+OK statping ready")
+				}
+				var user string
+				for {
+					line, err := text.ReadLine()
+					if err != nil {
+						return
+					}
+					fields := strings.Fields(line)
+					switch {
+					case protocol == "imap" && len(fields) == 4 && fields[1] == "LOGIN":
+						if fields[2] == `"statping"` && fields[3] == `"secret"` {
+							text.PrintfLine("* CAPABILITY IMAP4rev1")
+							text.PrintfLine("%s OK LOGIN completed", fields[0])
+						} else {
+							text.PrintfLine("%s NO [AUTHENTICATIONFAILED] Invalid credentials", fields[0])
+						}
+					case protocol == "imap" && len(fields) == 2 && fields[1] == "LOGOUT":
+						text.PrintfLine("* BYE")
+						text.PrintfLine("%s OK LOGOUT completed", fields[0])
+						return
+					case protocol == "pop3" && len(fields) == 2 && fields[0] == "USER":
+						user = fields[1]
+						text.PrintfLine("+OK")
+					case protocol == "pop3" && len(fields) == 2 && fields[0] == "PASS":
+						if user == "statping" && fields[1] == "secret" {
+							text.PrintfLine("+OK logged in")
+						} else {
+							text.PrintfLine("-ERR invalid credentials")
+						}
+					case line == "QUIT":
+						text.PrintfLine("+OK bye")
+						return
+					default:
+						text.PrintfLine("-ERR unknown command")
+					}
+				}
+			}(conn)
+		}
+	}()
+	return listener
+}
+
+// TestPingLatency examines the latency of the ICMP replies selected with the LatencyMode
+func TestPingLatency(t *testing.T) {
+	res := &utils.PingResult{Latency: 30000, First: 50000, Min: 10000}
+	tests := map[string]int64{"": 30000, "avg": 30000, "first": 50000, "min": 10000}
+	for mode, expected := range tests {
+		s := &Service{PingCount: 3, LatencyMode: mode}
+		if latency := s.pingLatency(res); latency != expected {
+			t.Errorf("Expected latency %d for mode '%v', Got: %d", expected, mode, latency)
+		}
+	}
+	if err := (&Service{Name: "ICMP", Domain: "127.0.0.1", Type: "icmp", Interval: 30, LatencyMode: "max"}).Validate(); err == nil {
+		t.Errorf("Expected an unknown latency mode to be rejected")
+	}
+}
+
+// TestExpandEnv examines the environment variables expanded in the expected response and the sent data
+func TestExpandEnv(t *testing.T) {
+	utils.InitEnvs()
+	os.Setenv("STATPING_TEST_VERSION", "1.2.3")
+	defer os.Unsetenv("STATPING_TEST_VERSION")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(`{"version":"1.2.3","sent":"` + string(body) + `"}`))
+	}))
+	defer server.Close()
+
+	s := &Service{
+		Name:           "HTTP Expand Env",
+		Domain:         server.URL,
+		ExpectedStatus: http.StatusOK,
+		Expected:       null.NewNullString(`"version":"${STATPING_TEST_VERSION}","sent":"v${STATPING_TEST_VERSION}"`),
+		PostData:       null.NewNullString(`v${STATPING_TEST_VERSION}`),
+		ExpandEnv:      null.NewNullBool(true),
+		Type:           "http",
+		Method:         "POST",
+		Timeout:        2,
+	}
+	CheckHttp(s, false)
+	if !s.Online {
+		t.Errorf("Expected the expanded response to match, Got: '%v'", s.LastResponse)
+	}
+	if expected := s.expectedValue(); expected != `"version":"1\.2\.3","sent":"v1\.2\.3"` {
+		t.Errorf("Expected the variables to be quoted in the regex, Got: '%v'", expected)
+	}
+
+	s.ExpandEnv = null.NewNullBool(false)
+	if s.postData() != `v${STATPING_TEST_VERSION}` {
+		t.Errorf("Expected ${} to be literal without ExpandEnv, Got: '%v'", s.postData())
+	}
+	s.ExpandEnv = null.NewNullBool(true)
+	s.PostData = null.NewNullString(`v${STATPING_TEST_UNSET}`)
+	if s.postData() != "v" {
+		t.Errorf("Expected an unset variable to be expanded to an empty string, Got: '%v'", s.postData())
+	}
+	s.ExpectedExact = null.NewNullBool(true)
+	if expected := s.expectedValue(); expected != `"version":"1.2.3","sent":"v1.2.3"` {
+		t.Errorf("Expected the variables to not be quoted for an exact match, Got: '%v'", expected)
+	}
+}
+
+// TestCheckHttpResponseSize examines CheckHttp() recording the size and throughput of the response in the hit
+func TestCheckHttpResponseSize(t *testing.T) {
+	utils.InitEnvs()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat([]byte("a"), 3000))
+	}))
+	defer server.Close()
+
+	for _, limit := range []int64{0, 1000} {
+		s := &Service{
+			Name:            "HTTP Response Size",
+			Domain:          server.URL,
+			ExpectedStatus:  http.StatusOK,
+			MaxResponseSize: limit,
+			Type:            "http",
+			Method:          "GET",
+			Timeout:         2,
+		}
+		CheckHttp(s, false)
+		hit := newHit(s)
+		if hit.Size != 3000 {
+			t.Errorf("Expected a response size of 3000 bytes with a limit of %d, Got: %d", limit, hit.Size)
+		}
+		if hit.Throughput <= 0 {
+			t.Errorf("Expected the throughput of the response, Got: %v", hit.Throughput)
+		}
+	}
+	if bps := throughput(5000, 500000); bps != 10000 {
+		t.Errorf("Expected 10000 bytes per second, Got: %v", bps)
+	}
+}
+
+// TestRegisterChecker examines a custom checker being dispatched by the type of the service
+func TestRegisterChecker(t *testing.T) {
+	var checked int
+	RegisterChecker("statping-test", func(s *Service, record bool) {
+		checked++
+		s.Online = true
+		s.LastResponse = "custom"
+	})
+	defer func() {
+		checkersMu.Lock()
+		delete(customCheckers, "statping-test")
+		checkersMu.Unlock()
+	}()
+
+	s := &Service{Name: "Custom", Domain: "custom://statping", Type: "statping-test", Interval: 30}
+	if err := s.Validate(); err != nil {
+		t.Errorf("Expected a registered type to be valid, Got: '%v'", err)
+	}
+	s.runCheck(false)
+	if checked != 1 || !s.Online || s.LastResponse != "custom" || s.LastCheck.IsZero() {
+		t.Errorf("Expected the custom checker to check the service, Got: %d %v '%v'", checked, s.Online, s.LastResponse)
+	}
+
+	RegisterChecker("http", func(s *Service, record bool) { checked++ })
+	if customChecker("http") != nil {
+		t.Errorf("Expected a custom checker to not replace a built-in type")
+	}
+	if err := (&Service{Name: "Unknown", Domain: "localhost", Type: "statping-unknown", Interval: 30}).Validate(); err == nil {
+		t.Errorf("Expected an unregistered type to be rejected")
+	}
+}
+
+// TestOfflineDependency examines the failure notifications being suppressed while a dependency is offline
+func TestOfflineDependency(t *testing.T) {
+	database := &Service{Id: 911, Name: "Database", Status: StatusDown}
+	api := &Service{Id: 912, Name: "API", Status: StatusDown, DependsOn: IdList{911}}
+	web := &Service{Id: 913, Name: "Web", Status: StatusUp, DependsOn: IdList{912, 914}}
+
+	saved := allServices
+	allServices = map[int64]*Service{database.Id: database, api.Id: api, web.Id: web}
+	defer func() { allServices = saved }()
+
+	if dependency := api.offlineDependency(); dependency != database {
+		t.Errorf("Expected the offline database to suppress the API alerts, Got: %v", dependency)
+	}
+	if dependency := web.offlineDependency(); dependency != api {
+		t.Errorf("Expected the offline API to suppress the web alerts, Got: %v", dependency)
+	}
+	database.Status = StatusUp
+	if dependency := api.offlineDependency(); dependency != nil {
+		t.Errorf("Expected no suppression with an online dependency, Got: %v", dependency.Name)
+	}
+
+	// a cycle must not silence the alerts of every service in it
+	database.Status = StatusDown
+	database.DependsOn = IdList{913}
+	web.Status = StatusDown
+	if dependency := api.offlineDependency(); dependency != nil {
+		t.Errorf("Expected a dependency in a cycle to be ignored, Got: %v", dependency.Name)
+	}
+
+	var value IdList
+	if err := value.Scan(`[911,912]`); err != nil || !reflect.DeepEqual(value, IdList{911, 912}) {
+		t.Errorf("Expected the ids to be parsed from the JSON array, Got: %v '%v'", value, err)
+	}
+	if err := (&Service{Id: 911, Name: "Self", Domain: "localhost", Type: "tcp", Port: 80, Interval: 30, DependsOn: IdList{911}}).Validate(); err == nil {
+		t.Errorf("Expected a service depending on itself to be rejected")
+	}
+}
+
+// TestCheckHttpSteps examines CheckHttp() sending the HttpSteps in order with the cookies of the previous steps
+func TestCheckHttpSteps(t *testing.T) {
+	utils.InitEnvs()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			body, _ := ioutil.ReadAll(r.Body)
+			if r.Method != http.MethodPost || string(body) != "user=statping" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "token"})
+			w.Write([]byte("logged in"))
+		case "/account":
+			if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"user":"statping"}`))
+		case "/logout":
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	s := &Service{
+		Name:           "HTTP Steps",
+		Domain:         server.URL,
+		ExpectedStatus: http.StatusOK,
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        2,
+		HttpSteps: HttpSteps{
+			{Method: "POST", URL: "/login", Body: "user=statping"},
+			{Method: "GET", URL: "/account", Expected: `"user":"statping"`},
+			{Method: "POST", URL: "/logout", ExpectedStatus: http.StatusNoContent},
+		},
+	}
+	if _, err := CheckHttp(s, false); err != nil || !s.Online {
+		t.Errorf("Expected every step to pass, Got: '%v' '%v'", err, s.LastResponse)
+	}
+	if s.LastStatusCode != http.StatusNoContent {
+		t.Errorf("Expected the status code of the last step, Got: %d", s.LastStatusCode)
+	}
+
+	s.Online = false
+	s.HttpSteps[0].Body = "user=unknown"
+	CheckHttp(s, false)
+	if s.Online || s.LastStatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected the failed login step to fail the check, Got: %v %d", s.Online, s.LastStatusCode)
+	}
+
+	s.HttpSteps = HttpSteps{{URL: "/account", Expected: "(invalid"}}
+	if err := s.validateSteps(); err == nil {
+		t.Errorf("Expected an invalid step regex to be rejected")
+	}
+	if target, _ := stepURL("https://statping.com/api/", "health"); target != "https://statping.com/api/health" {
+		t.Errorf("Expected the step url to be relative to the domain, Got: %v", target)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// TestHttpClient examines the HTTP client of the service being used before the global client set with SetHttpClient
+func TestHttpClient(t *testing.T) {
+	utils.InitEnvs()
+	mock := func(body string) *http.Client {
+		return &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"text/plain"}},
+				Body:       ioutil.NopCloser(strings.NewReader(body)),
+				Request:    req,
+			}, nil
+		})}
+	}
+
+	s := &Service{
+		Name:           "HTTP Client",
+		Domain:         "http://statping.invalid/health",
+		ExpectedStatus: http.StatusOK,
+		Expected:       null.NewNullString("service"),
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        2,
+		HttpClient:     mock("service client"),
+	}
+	if _, err := CheckHttp(s, false); err != nil || !s.Online {
+		t.Errorf("Expected the service client to send the check, Got: '%v' '%v'", err, s.LastResponse)
+	}
+
+	SetHttpClient(mock("global client"))
+	defer SetHttpClient(nil)
+	if _, err := CheckHttp(s, false); err != nil || s.LastResponse != "service client" {
+		t.Errorf("Expected the service client before the global client, Got: '%v' '%v'", err, s.LastResponse)
+	}
+	s.HttpClient = nil
+	if _, err := CheckHttp(s, false); err != nil || s.LastResponse != "global client" {
+		t.Errorf("Expected the global client to send the check, Got: '%v' '%v'", err, s.LastResponse)
+	}
+}
+
+// TestCheckNtp examines the clock offset of a NTP server, the MaxClockOffset and kiss codes of the response
+func TestCheckNtp(t *testing.T) {
+	clockOffset := 2 * time.Second
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, ntpPacketSize)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < ntpPacketSize {
+				continue
+			}
+			response := make([]byte, ntpPacketSize)
+			response[0] = 0<<6 | 4<<3 | 4
+			response[1] = 2
+			copy(response[24:32], buf[40:48])
+			now := toNtpTime(utils.Now().Add(clockOffset))
+			binary.BigEndian.PutUint64(response[32:], now)
+			binary.BigEndian.PutUint64(response[40:], now)
+			conn.WriteTo(response, addr)
+		}
+	}()
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+
+	s := &Service{
+		Name:    "NTP Server",
+		Domain:  "127.0.0.1",
+		Port:    port,
+		Type:    "ntp",
+		Timeout: 2,
+	}
+	if _, err := CheckNtp(s, false); err != nil || !s.Online {
+		t.Fatalf("Expected the NTP server to be online, Got: %v", err)
+	}
+	offset, err := time.ParseDuration(s.LastResponse)
+	if err != nil || offset < clockOffset-time.Second/2 || offset > clockOffset+time.Second/2 {
+		t.Errorf("Expected a clock offset of about %v, Got: %v", clockOffset, s.LastResponse)
+	}
+
+	s = &Service{Name: "NTP Server", Domain: "127.0.0.1", Port: port, Type: "ntp", Timeout: 2, MaxClockOffset: 1}
+	if _, err := CheckNtp(s, false); err != nil || s.Online {
+		t.Errorf("Expected a clock offset over the max to fail, Got: %v %v", err, s.Online)
+	}
+
+	kiss := make([]byte, ntpPacketSize)
+	kiss[0] = 0<<6 | 4<<3 | 4
+	copy(kiss[12:16], "RATE")
+	if _, err := parseNtpResponse(kiss, utils.Now(), utils.Now()); err == nil || !strings.Contains(err.Error(), "RATE") {
+		t.Errorf("Expected the kiss code to fail the response, Got: %v", err)
+	}
+	if ts := fromNtpTime(toNtpTime(time.Unix(1600000000, 500000000))); !ts.Equal(time.Unix(1600000000, 500000000)) {
+		t.Errorf("Expected the NTP timestamp to convert back to the time, Got: %v", ts)
+	}
+}
+
+// TestCheckHttpExpectedChecksum examines a HTTP service failing when the checksum of the decoded body does not match the ExpectedChecksum
+func TestCheckHttpExpectedChecksum(t *testing.T) {
+	utils.InitEnvs()
+	body := "body { color: #333; }"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte(body))
+		gz.Close()
+	}))
+	defer server.Close()
+
+	s := &Service{
+		Name:             "HTTP Checksum",
+		Domain:           server.URL,
+		ExpectedStatus:   http.StatusOK,
+		ExpectedChecksum: fmt.Sprintf("%X", sha256.Sum256([]byte(body))),
+		Type:             "http",
+		Method:           "GET",
+		Timeout:          2,
+	}
+	if err := s.validateExpectedChecksum(); err != nil {
+		t.Fatalf("Expected an uppercase digest to be valid, Got: '%v'", err)
+	}
+	if _, err := CheckHttp(s, false); err != nil || !s.Online {
+		t.Errorf("Expected the checksum of the decoded body to match, Got: '%v' '%v'", err, s.LastResponse)
+	}
+
+	s = &Service{
+		Name:             "HTTP Checksum",
+		Domain:           server.URL,
+		ExpectedStatus:   http.StatusOK,
+		ExpectedChecksum: fmt.Sprintf("%x", sha256.Sum256([]byte("body {}"))),
+		Type:             "http",
+		Method:           "GET",
+		Timeout:          2,
+	}
+	CheckHttp(s, false)
+	if s.Online {
+		t.Errorf("Expected a different checksum to fail the check")
+	}
+	h := s.checksumDigest()
+	h.Write([]byte(body))
+	if digest, _ := s.matchChecksum(h); digest != fmt.Sprintf("%x", sha256.Sum256([]byte(body))) {
+		t.Errorf("Expected the SHA-256 digest of the body, Got: %v", digest)
+	}
+
+	// the checksum is of the whole body, not the bytes kept with MaxResponseSize
+	s.ExpectedChecksum = fmt.Sprintf("%x", sha256.Sum256([]byte(body)))
+	s.MaxResponseSize = 4
+	if _, err := CheckHttp(s, false); err != nil || !s.Online {
+		t.Errorf("Expected the checksum of a body larger than MaxResponseSize to match, Got: '%v' '%v'", err, s.LastResponse)
+	}
+	if !s.ResponseTruncated {
+		t.Errorf("Expected the response to be truncated at MaxResponseSize")
+	}
+	s.ExpectedChecksum = "d41d8cd98f00b204e9800998ecf8427e"
+	if err := s.validateExpectedChecksum(); err == nil {
+		t.Errorf("Expected a MD5 digest to be rejected")
+	}
+}
+
+// TestCheckTcpPorts examines a TCP service being online only when the port and every additional port are reachable
+func TestCheckTcpPorts(t *testing.T) {
+	listen := func() (net.Listener, int) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Expected no error, Got: '%v'", err)
+		}
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				conn.Close()
+			}
+		}()
+		return ln, ln.Addr().(*net.TCPAddr).Port
+	}
+	first, firstPort := listen()
+	defer first.Close()
+	second, secondPort := listen()
+	defer second.Close()
+	closed, closedPort := listen()
+	closed.Close()
+
+	s := &Service{
+		Name:    "TCP Ports",
+		Domain:  "127.0.0.1",
+		Port:    firstPort,
+		Ports:   PortList{secondPort, firstPort},
+		Type:    "tcp",
+		Timeout: 2,
+	}
+	if ports := s.checkPorts(); !reflect.DeepEqual(ports, []int{firstPort, secondPort}) {
+		t.Errorf("Expected the port and additional ports without duplicates, Got: %v", ports)
+	}
+	if _, err := CheckTcp(s, false); err != nil || !s.Online {
+		t.Errorf("Expected every port to be reachable, Got: '%v'", err)
+	}
+
+	s = &Service{
+		Name:    "TCP Ports",
+		Domain:  "127.0.0.1",
+		Port:    firstPort,
+		Ports:   PortList{closedPort, secondPort},
+		Type:    "tcp",
+		Timeout: 2,
+	}
+	if _, err := CheckTcp(s, false); err == nil || s.Online {
+		t.Errorf("Expected an unreachable port to fail the service, Got: '%v'", err)
+	}
+
+	s.Interval = 30
+	s.Ports = PortList{70000}
+	if err := s.Validate(); err == nil || !strings.Contains(err.Error(), "70000") {
+		t.Errorf("Expected an invalid port to be rejected, Got: '%v'", err)
+	}
+}
+
+// TestServiceLabels examines the validation of the label keys of a service and storing the labels as JSON
+func TestServiceLabels(t *testing.T) {
+	s := &Service{Id: 9501, Name: "Labels", Labels: LabelMap{"team": "ops", "_region": "eu"}}
+	if err := s.validateLabels(); err != nil {
+		t.Errorf("Expected the labels to be valid, Got: '%v'", err)
+	}
+	for _, key := range []string{"1team", "team-name", "__meta", "id", "name"} {
+		s.Labels = LabelMap{key: "ops"}
+		if err := s.validateLabels(); err == nil {
+			t.Errorf("Expected the label '%v' to be rejected", key)
+		}
+	}
+
+	s.Labels = LabelMap{"team": "ops"}
+	value, err := s.Labels.Value()
+	if err != nil || value != `{"team":"ops"}` {
+		t.Errorf("Expected the labels to be stored as JSON, Got: '%v' '%v'", value, err)
+	}
+	var scanned LabelMap
+	if err := scanned.Scan(value); err != nil || !reflect.DeepEqual(scanned, s.Labels) {
+		t.Errorf("Expected the labels to be parsed from JSON, Got: '%v' '%v'", scanned, err)
+	}
+}
+
+// aRecordServer will start a nameserver that answers every query with the A records
+func aRecordServer(t *testing.T, records ...[4]byte) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, Got: '%v'", err)
+	}
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var parser dnsmessage.Parser
+			header, err := parser.Start(buf[:n])
+			if err != nil {
+				continue
+			}
+			question, _ := parser.Question()
+			header.Response = true
+			builder := dnsmessage.NewBuilder(nil, header)
+			builder.StartQuestions()
+			builder.Question(question)
+			builder.StartAnswers()
+			if question.Type == dnsmessage.TypeA {
+				for _, record := range records {
+					builder.AResource(dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: 60}, dnsmessage.AResource{A: record})
+				}
+			}
+			response, _ := builder.Finish()
+			conn.WriteTo(response, addr)
+		}
+	}()
+	return conn
+}
+
+// TestCheckHttpResolvedIPs examines a HTTP service checking every resolved A record and failing when a single backend is sick
+func TestCheckHttpResolvedIPs(t *testing.T) {
+	utils.InitEnvs()
+	var hosts []string
+	var mu sync.Mutex
+	sickStatus := http.StatusInternalServerError
+	handler := func(status *int) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			hosts = append(hosts, r.Host)
+			w.WriteHeader(*status)
+		})
+	}
+	healthyStatus := http.StatusOK
+	healthy := httptest.NewServer(handler(&healthyStatus))
+	defer healthy.Close()
+	port := healthy.Listener.Addr().(*net.TCPAddr).Port
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.2:%d", port))
+	if err != nil {
+		t.Skipf("Could not listen on 127.0.0.2, %v", err)
+	}
+	sick := httptest.NewUnstartedServer(handler(&sickStatus))
+	sick.Listener.Close()
+	sick.Listener = ln
+	sick.Start()
+	defer sick.Close()
+
+	nameserver := aRecordServer(t, [4]byte{127, 0, 0, 1}, [4]byte{127, 0, 0, 2})
+	defer nameserver.Close()
+
+	s := &Service{
+		Name:           "All Resolved IPs",
+		Domain:         fmt.Sprintf("http://backends.statping.test:%d/health", port),
+		ExpectedStatus: http.StatusOK,
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        2,
+		DnsResolver:    null.NewNullString(nameserver.LocalAddr().String()),
+		AllResolvedIPs: null.NewNullBool(true),
+	}
+	ips, err := s.resolvedIPs()
+	if err != nil || len(ips) != 2 {
+		t.Fatalf("Expected both A records to be resolved, Got: %v '%v'", ips, err)
+	}
+	CheckHttp(s, false)
+	if s.Online {
+		t.Errorf("Expected the sick backend to fail the service")
+	}
+	if len(s.ResolvedIPs) != 2 || !s.ResolvedIPs[0].Online || s.ResolvedIPs[1].Online || s.ResolvedIPs[1].IP != "127.0.0.2" {
+		t.Errorf("Expected the result of each resolved IP, Got: %+v", s.ResolvedIPs)
+	}
+	if !strings.Contains(s.ResolvedIPs[1].Issue, "500") {
+		t.Errorf("Expected the issue of the sick backend, Got: %v", s.ResolvedIPs[1].Issue)
+	}
+	mu.Lock()
+	for _, host := range hosts {
+		if !strings.HasPrefix(host, "backends.statping.test") {
+			t.Errorf("Expected the Host header of the domain, Got: %v", host)
+		}
+	}
+
+	sickStatus = http.StatusOK
+	mu.Unlock()
+	if _, err := CheckHttp(s, false); err != nil || !s.Online {
+		t.Errorf("Expected the service to be online with every backend healthy, Got: '%v' %+v", err, s.ResolvedIPs)
+	}
+}
+
+// TestCheckHttpSizeCaps examines the global max request body and retained response sizes of HTTP checks
+func TestCheckHttpSizeCaps(t *testing.T) {
+	utils.InitEnvs()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte("received " + string(body)))
+	}))
+	defer server.Close()
+
+	utils.Params.Set("MAX_REQUEST_SIZE", 8)
+	utils.Params.Set("MAX_RESPONSE_SIZE", 12)
+	defer utils.Params.Set("MAX_REQUEST_SIZE", 0)
+	defer utils.Params.Set("MAX_RESPONSE_SIZE", 0)
+
+	s := &Service{
+		Name:           "HTTP Size Caps",
+		Domain:         server.URL,
+		ExpectedStatus: http.StatusOK,
+		Expected:       null.NewNullString("received small"),
+		Type:           "http",
+		Method:         "POST",
+		PostData:       null.NewNullString("small"),
+		Timeout:        2,
+	}
+	if _, err := CheckHttp(s, false); err != nil || !s.Online {
+		t.Fatalf("Expected the full response to be matched, Got: '%v'", err)
+	}
+	if s.LastResponse != "received sma"+utils.TruncatedBody || !s.ResponseTruncated {
+		t.Errorf("Expected the retained response to be truncated, Got: '%v' %v", s.LastResponse, s.ResponseTruncated)
+	}
+
+	s.Online = false
+	s.PostData = null.NewNullString("a much larger body")
+	if _, err := CheckHttp(s, false); err == nil || !strings.Contains(err.Error(), "larger than the max of 8 bytes") || s.Online {
+		t.Errorf("Expected a request body over the max to not be sent, Got: '%v'", err)
+	}
+
+	utils.Params.Set("MAX_RESPONSE_SIZE", 0)
+	s.PostData = null.NewNullString("small")
+	CheckHttp(s, false)
+	if s.LastResponse != "received small" || s.ResponseTruncated {
+		t.Errorf("Expected the whole response without a max, Got: '%v' %v", s.LastResponse, s.ResponseTruncated)
+	}
+}
+
+// TestCheckHttpExpectedSchema examines a HTTP service failing when the JSON body does not match the ExpectedSchema
+func TestCheckHttpExpectedSchema(t *testing.T) {
+	utils.InitEnvs()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status": "ok", "uptime": "12h"}`))
+	}))
+	defer server.Close()
+
+	s := &Service{
+		Name:           "HTTP Schema",
+		Domain:         server.URL,
+		ExpectedStatus: http.StatusOK,
+		ExpectedSchema: `{"type": "object", "required": ["status"], "properties": {"status": {"type": "string"}}}`,
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        2,
+	}
+	if err := s.validateExpectedSchema(); err != nil {
+		t.Fatalf("Expected the schema to compile, Got: '%v'", err)
+	}
+	if _, err := CheckHttp(s, false); err != nil || !s.Online {
+		t.Errorf("Expected the body to match the schema, Got: '%v' '%v'", err, s.LastResponse)
+	}
+
+	s.Online = false
+	s.ExpectedSchema = `{"type": "object", "required": ["version"], "properties": {"uptime": {"type": "integer"}}}`
+	CheckHttp(s, false)
+	if s.Online {
+		t.Errorf("Expected a body that does not match the schema to fail the check")
+	}
+	err := s.matchSchema([]byte(`{"status": "ok", "uptime": "12h"}`))
+	if err == nil || !strings.Contains(err.Error(), "version") || !strings.Contains(err.Error(), "uptime") {
+		t.Errorf("Expected every validation error to be listed, Got: '%v'", err)
+	}
+
+	s.ExpectedSchema = `{"type": 5}`
+	if err := s.validateExpectedSchema(); err == nil {
+		t.Errorf("Expected an invalid schema to be rejected")
+	}
+}
+
+// TestChecker examines the stats of the checker counting the checks waiting for a slot and their duration
+func TestChecker(t *testing.T) {
+	before := Checker()
+	pool := newCheckPool(1)
+	stop := make(chan bool)
+	pool.acquire(stop)
+	go pool.acquire(stop)
+	time.Sleep(50 * time.Millisecond)
+	if stats := Checker(); stats.Queued != before.Queued+1 {
+		t.Errorf("Expected 1 check waiting for a slot, Got: %d", stats.Queued-before.Queued)
+	}
+	close(stop)
+	time.Sleep(50 * time.Millisecond)
+	if stats := Checker(); stats.Queued != before.Queued {
+		t.Errorf("Expected no checks waiting after the service was stopped, Got: %d", stats.Queued-before.Queued)
+	}
+
+	checkFinished(10 * time.Millisecond)
+	checkFinished(30 * time.Millisecond)
+	stats := Checker()
+	if stats.Checks != before.Checks+2 {
+		t.Errorf("Expected 2 more checks, Got: %d", stats.Checks-before.Checks)
+	}
+	if before.Checks == 0 && stats.AverageDuration != 20 {
+		t.Errorf("Expected an average duration of 20ms, Got: %v", stats.AverageDuration)
+	}
+}
+
+// TestPooledCheck examines the slot of the worker pool and the running checks being released when a check panics
+func TestPooledCheck(t *testing.T) {
+	checkWorkers()
+	pool := checks
+	checks = newCheckPool(1)
+	defer func() { checks = pool }()
+	RegisterChecker("statping-panic", func(s *Service, record bool) {
+		panic("check failed")
+	})
+
+	before := Checker()
+	s := &Service{Name: "Panics", Type: "statping-panic"}
+	func() {
+		defer func() { recover() }()
+		s.pooledCheck(false, nil)
+	}()
+	if stats := Checker(); stats.Running != before.Running {
+		t.Errorf("Expected no running checks after the check panicked, Got: %d", stats.Running-before.Running)
+	}
+	select {
+	case checks.slots <- struct{}{}:
+	default:
+		t.Errorf("Expected the slot to be released after the check panicked")
+	}
+	stop := make(chan bool)
+	close(stop)
+	if _, ok := s.pooledCheck(false, stop); ok {
+		t.Errorf("Expected a stopped service to not be checked while every slot is taken")
+	}
+}
+
+// TestInvertedService examines an Inverted service being online while unreachable and offline when the check succeeds
+func TestInvertedService(t *testing.T) {
+	utils.InitEnvs()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("still here"))
+	}))
+
+	s := &Service{
+		Name:           "Decommissioned",
+		Domain:         server.URL,
+		ExpectedStatus: http.StatusOK,
+		Type:           "http",
+		Method:         "GET",
+		Timeout:        2,
+		Inverted:       null.NewNullBool(true),
+	}
+	s.runCheck(false)
+	if s.Online {
+		t.Errorf("Expected an inverted service that responds to be offline")
+	}
+	if s.LastStatusCode != http.StatusOK || s.LastResponse != "still here" || s.Latency == 0 {
+		t.Errorf("Expected the response of the check to be kept, Got: %d '%v' %d", s.LastStatusCode, s.LastResponse, s.Latency)
+	}
+	if s.probed != nil {
+		t.Errorf("Expected the probe of the inverted check to be removed")
+	}
+
+	server.Close()
+	s.runCheck(false)
+	if !s.Online {
+		t.Errorf("Expected an inverted service that is unreachable to be online")
+	}
+
+	s.ExpectClosed = null.NewNullBool(true)
+	if len(s.Warnings()) == 0 {
+		t.Errorf("Expected a warning for an inverted service that expects a closed port")
+	}
+}
@@ -28,6 +28,7 @@ func Example(online bool) Service {
 		TLSCertRoot:         null.NullString{},
 		Headers:             null.NullString{},
 		Permalink:           null.NewNullString("example-service"),
+		Labels:              LabelMap{"team": "statping", "region": "us-west"},
 		Redirect:            null.NewNullBool(true),
 		CreatedAt:           utils.Now().Add(-23 * time.Hour),
 		UpdatedAt:           utils.Now().Add(-23 * time.Hour),
@@ -42,6 +43,7 @@ func Example(online bool) Service {
 		SleepDuration:       5 * time.Second,
 		LastResponse:        "The example service is hitting this page",
 		NotifyAfter:         0,
+		FailureThreshold:    1,
 		notifyAfterCount:    0,
 		AllowNotifications:  null.NewNullBool(true),
 		UpdateNotify:        null.NewNullBool(true),
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/statping/statping/utils"
+)
+
+var scheduleDays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// scheduleWindow is a daily period the service is expected to be up on the days of the week,
+// the window ends on the next day when end is before start
+type scheduleWindow struct {
+	days  [7]bool
+	start time.Duration
+	end   time.Duration
+}
+
+// parseSchedule parses the ActiveSchedule of a service, windows are separated with a semicolon and
+// have the days and the hours the service is expected to be up, e.g. 'mon-fri 09:00-17:00; sat 10:00-14:00'
+func parseSchedule(schedule string) ([]scheduleWindow, error) {
+	var windows []scheduleWindow
+	for _, entry := range strings.Split(schedule, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("schedule '%v' must have the days and the hours, e.g. 'mon-fri 09:00-17:00'", strings.TrimSpace(entry))
+		}
+		var window scheduleWindow
+		if err := parseScheduleDays(strings.ToLower(fields[0]), &window.days); err != nil {
+			return nil, err
+		}
+		hours := strings.SplitN(fields[1], "-", 2)
+		if len(hours) != 2 {
+			return nil, fmt.Errorf("schedule hours '%v' must be a range, e.g. '09:00-17:00'", fields[1])
+		}
+		var err error
+		if window.start, err = parseScheduleTime(hours[0]); err != nil {
+			return nil, err
+		}
+		if window.end, err = parseScheduleTime(hours[1]); err != nil {
+			return nil, err
+		}
+		if window.start == window.end {
+			return nil, fmt.Errorf("schedule hours '%v' must not start and end at the same time", fields[1])
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+// parseScheduleDays sets the days of a comma delimited list of days and day ranges, '*' is every day
+func parseScheduleDays(value string, days *[7]bool) error {
+	for _, part := range strings.Split(value, ",") {
+		if part == "*" {
+			*days = [7]bool{true, true, true, true, true, true, true}
+			continue
+		}
+		bounds := strings.SplitN(part, "-", 2)
+		first, ok := scheduleDays[bounds[0]]
+		if !ok {
+			return fmt.Errorf("schedule day '%v' must be one of sun, mon, tue, wed, thu, fri, sat", bounds[0])
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, ok = scheduleDays[bounds[1]]; !ok {
+				return fmt.Errorf("schedule day '%v' must be one of sun, mon, tue, wed, thu, fri, sat", bounds[1])
+			}
+		}
+		for day := first; ; day = (day + 1) % 7 {
+			days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// parseScheduleTime returns the time of day of a HH:MM value, 24:00 is the end of the day
+func parseScheduleTime(value string) (time.Duration, error) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) == 2 {
+		hour, herr := strconv.Atoi(parts[0])
+		minute, merr := strconv.Atoi(parts[1])
+		if herr == nil && merr == nil && hour >= 0 && minute >= 0 && minute < 60 && (hour < 24 || (hour == 24 && minute == 0)) {
+			return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute, nil
+		}
+	}
+	return 0, fmt.Errorf("schedule time '%v' must be HH:MM", value)
+}
+
+// scheduleLocation returns the timezone of the ActiveSchedule, ScheduleTimezone or TIMEZONE if not set
+func (s Service) scheduleLocation() (*time.Location, error) {
+	name := s.ScheduleTimezone
+	if name == "" {
+		name = utils.Params.GetString("TIMEZONE")
+	}
+	return time.LoadLocation(name)
+}
+
+// activeSchedule returns true if the time is inside of the service's ActiveSchedule, along with the
+// time the next window starts when it is not. A service without a schedule is always active.
+func (s Service) activeSchedule(now time.Time) (bool, time.Time) {
+	if s.ActiveSchedule == "" {
+		return true, time.Time{}
+	}
+	windows, err := parseSchedule(s.ActiveSchedule)
+	if err != nil || len(windows) == 0 {
+		return true, time.Time{}
+	}
+	location, err := s.scheduleLocation()
+	if err != nil {
+		location = time.UTC
+	}
+	local := now.In(location)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+
+	var next time.Time
+	// yesterday's windows can end today, the next window starts within a week
+	for offset := -1; offset <= 7; offset++ {
+		day := today.AddDate(0, 0, offset)
+		for _, w := range windows {
+			if !w.days[day.Weekday()] {
+				continue
+			}
+			start := scheduleAt(day, w.start)
+			end := scheduleAt(day, w.end)
+			if w.end < w.start {
+				end = scheduleAt(day.AddDate(0, 0, 1), w.end)
+			}
+			if !local.Before(start) && local.Before(end) {
+				return true, time.Time{}
+			}
+			if start.After(local) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+	}
+	return false, next.UTC()
+}
+
+// scheduleAt returns the time of day on the day, as wall clock time so daylight saving changes are kept
+func scheduleAt(day time.Time, at time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, int(at/time.Minute), 0, 0, day.Location())
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// cachedSchema is the compiled ExpectedSchema of a service
+type cachedSchema struct {
+	source string
+	schema *gojsonschema.Schema
+}
+
+// compiledSchema returns the compiled ExpectedSchema of the service, the schema is only compiled again
+// when the ExpectedSchema was changed
+func (s *Service) compiledSchema() (*gojsonschema.Schema, error) {
+	if s.expectedSchema != nil && s.expectedSchema.source == s.ExpectedSchema {
+		return s.expectedSchema.schema, nil
+	}
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(s.ExpectedSchema))
+	if err != nil {
+		return nil, err
+	}
+	s.expectedSchema = &cachedSchema{source: s.ExpectedSchema, schema: schema}
+	return schema, nil
+}
+
+// validateExpectedSchema returns an error if the ExpectedSchema of the service is not a valid JSON Schema
+func (s *Service) validateExpectedSchema() error {
+	if s.ExpectedSchema == "" {
+		return nil
+	}
+	if _, err := s.compiledSchema(); err != nil {
+		return errors.New(fmt.Sprintf("expected schema is not a valid JSON Schema, %v", err))
+	}
+	return nil
+}
+
+// matchSchema returns an error listing every validation error if the JSON body does not match the ExpectedSchema
+func (s *Service) matchSchema(body []byte) error {
+	schema, err := s.compiledSchema()
+	if err != nil {
+		return err
+	}
+	result, err := schema.Validate(gojsonschema.NewBytesLoader(body))
+	if err != nil {
+		return fmt.Errorf("body is not valid JSON, %v", err)
+	}
+	if result.Valid() {
+		return nil
+	}
+	messages := make([]string, len(result.Errors()))
+	for i, desc := range result.Errors() {
+		messages[i] = desc.String()
+	}
+	return fmt.Errorf("did not match the schema: %v", strings.Join(messages, "; "))
+}
@@ -19,6 +19,8 @@ import (
 	pb "google.golang.org/grpc/examples/route_guide/routeguide"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 )
@@ -290,6 +292,25 @@ func TestServices(t *testing.T) {
 		assert.False(t, e.LastCheck.IsZero())
 		assert.NotEqual(t, 0, e.PingTime)
 		assert.NotEqual(t, 0, e.Latency)
+		assert.False(t, e.TLSExpiry.IsZero())
+		assert.Greater(t, e.TLSExpiresIn, float64(0))
+	})
+
+	t.Run("Test TLS HTTP Check Expiring", func(t *testing.T) {
+		e := &Service{
+			Name:             "Example TLS HTTP Expiring",
+			Domain:           "https://localhost:15001",
+			ExpectedStatus:   200,
+			Type:             "http",
+			Method:           "GET",
+			Timeout:          15,
+			VerifySSL:        null.NewNullBool(false),
+			SSLExpiryWarning: 36500,
+		}
+		e, err := CheckHttp(e, false)
+		require.Nil(t, err)
+		assert.False(t, e.Online)
+		assert.Less(t, e.TLSExpiresIn, float64(e.SSLExpiryWarning))
 	})
 
 	t.Run("Test TCP Check", func(t *testing.T) {
@@ -615,4 +636,169 @@ services:
 		require.Nil(t, err)
 		assert.NoFileExists(t, utils.Directory+"/services.yml")
 	})
+	t.Run("Test Force Check", func(t *testing.T) {
+		var mu sync.Mutex
+		var requests, active, maxActive int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			requests++
+			active++
+			if active > maxActive {
+				maxActive = active
+			}
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}))
+		defer server.Close()
+		count := func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return requests
+		}
+
+		e := &Service{
+			Name:           "Example Force Check",
+			Domain:         server.URL,
+			ExpectedStatus: 200,
+			Interval:       3600,
+			Type:           "http",
+			Method:         "GET",
+			Timeout:        2,
+		}
+		e.ForceCheck()
+		assert.Equal(t, 1, count())
+		assert.True(t, e.Online)
+
+		// forced checks of a stopped service wait for each other
+		var forced sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			forced.Add(1)
+			go func() {
+				defer forced.Done()
+				e.ForceCheck()
+			}()
+		}
+		forced.Wait()
+		assert.Equal(t, 4, count())
+		assert.Equal(t, 1, maxActive)
+
+		e.Start()
+		go ServiceCheckQueue(e, false)
+		defer e.Close()
+		e.ForceCheck()
+		before := count()
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				e.ForceCheck()
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, before+3, count())
+		assert.Equal(t, 1, maxActive)
+		assert.True(t, e.Checkpoint.After(utils.Now().Add(59*time.Minute)))
+	})
+
+	t.Run("Test Structured Failure", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		e := &Service{
+			Name:           "Example Structured Failure",
+			Domain:         server.URL,
+			ExpectedStatus: 200,
+			Interval:       30,
+			Type:           "http",
+			Method:         "GET",
+			Timeout:        2,
+		}
+		require.Nil(t, e.Create())
+		defer e.Delete()
+
+		_, err := CheckHttp(e, true)
+		require.Nil(t, err)
+		require.Len(t, e.Failures, 1)
+		assert.Equal(t, "HTTP Status Code 500 did not match 200", e.Failures[0].Issue)
+
+		fail := e.AllFailures().Last()
+		require.NotNil(t, fail)
+		assert.Equal(t, "status_code", fail.Reason)
+		assert.Equal(t, failures.CategoryStatus, fail.Category)
+		assert.Equal(t, "200", fail.Expected)
+		assert.Equal(t, "500", fail.Actual)
+		assert.Equal(t, "", fail.Error)
+		assert.Equal(t, e.Failures[0].Issue, fail.Issue)
+	})
+
+	t.Run("Test HTTP Headers", func(t *testing.T) {
+		e := &Service{
+			Name:     "Example HTTP Headers",
+			Domain:   "https://example.com",
+			Interval: 30,
+			Type:     "http",
+			Method:   "GET",
+			Timeout:  5,
+			HttpHeaders: HttpHeaders{
+				{Key: "Accept", Value: "text/html, application/xml"},
+				{Key: "Cookie", Value: "a=1; b=2"},
+			},
+		}
+		require.Nil(t, e.Create())
+		defer e.Delete()
+
+		var found Service
+		require.Nil(t, db.Where("id = ?", e.Id).Find(&found).Error())
+		assert.Equal(t, e.HttpHeaders, found.HttpHeaders)
+	})
+
+	t.Run("Test Endpoints Quorum", func(t *testing.T) {
+		ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer ok.Close()
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer failing.Close()
+
+		e := &Service{
+			Name:           "Example Endpoints Quorum",
+			Domain:         ok.URL,
+			ExpectedStatus: 200,
+			Interval:       30,
+			Type:           "http",
+			Method:         "GET",
+			Timeout:        2,
+			Endpoints:      StringList{ok.URL, failing.URL, failing.URL},
+			Quorum:         2,
+		}
+		require.Nil(t, e.Create())
+		defer e.Delete()
+
+		var found Service
+		require.Nil(t, db.Where("id = ?", e.Id).Find(&found).Error())
+		assert.Equal(t, e.Endpoints, found.Endpoints)
+
+		_, err := CheckHttp(e, true)
+		require.Nil(t, err)
+		assert.False(t, e.Online)
+		require.Len(t, e.Failures, 1)
+		assert.Equal(t, "quorum", e.Failures[0].Reason)
+		assert.Equal(t, failures.CategoryStatus, e.Failures[0].Category)
+		assert.Contains(t, e.Failures[0].Issue, "1 of 3 endpoints are healthy, 2 required")
+		assert.Contains(t, e.Failures[0].Issue, failing.URL+" (HTTP Status Code 503 did not match 200)")
+		assert.NotContains(t, e.Failures[0].Issue, ok.URL+" (")
+		assert.Equal(t, 0, e.AllFailures().Count()-1)
+
+		e.Quorum = 1
+		_, err = CheckHttp(e, true)
+		require.Nil(t, err)
+		assert.True(t, e.Online)
+		assert.Len(t, e.Failures, 1)
+	})
 }
@@ -252,6 +252,182 @@ func TestServiceNotifications(t *testing.T) {
 		runNotifyTests(t, notif, tests...)
 	})
 
+	t.Run("Failure Threshold - [online, offline after 3 consecutive failures]", func(t *testing.T) {
+		service := Example(true)
+		service.prevOnline = true
+		service.FailureThreshold = 3
+
+		RecordFailure(&service, "test issue", "lookup")
+		assert.True(t, service.Online)
+		assert.Equal(t, 1, service.CurrentFailureCount)
+
+		RecordFailure(&service, "test issue", "lookup")
+		assert.True(t, service.Online)
+
+		RecordFailure(&service, "test issue", "lookup")
+		assert.False(t, service.Online)
+		assert.Equal(t, 3, service.CurrentFailureCount)
+
+		RecordSuccess(&service)
+		assert.True(t, service.Online)
+		assert.Equal(t, 0, service.CurrentFailureCount)
+	})
+
+	t.Run("Recovery - [offline, notify once when back online]", func(t *testing.T) {
+		recoverer := &recoverNotifier{exampleNotifier: notification}
+		allNotifiers[notification.Method] = recoverer
+		defer func() { allNotifiers[notification.Method] = notification }()
+
+		service := Example(true)
+		service.prevOnline = true
+
+		RecordSuccess(&service)
+		assert.Equal(t, 0, recoverer.recovered)
+
+		RecordFailure(&service, "test issue", "lookup")
+		assert.False(t, service.Online)
+		RecordFailure(&service, "test issue", "lookup")
+
+		// a notifier with OnRecover is only sent the recovery, not a success too
+		successes := notification.success
+		RecordSuccess(&service)
+		assert.True(t, service.Online)
+		assert.Equal(t, 1, recoverer.recovered)
+		assert.Equal(t, successes, notification.success)
+		assert.True(t, recoverer.downtime >= 0)
+
+		RecordSuccess(&service)
+		assert.Equal(t, 1, recoverer.recovered)
+	})
+
+	t.Run("Resend Throttle - [offline, identical failures notify once per resend interval]", func(t *testing.T) {
+		allNotifiers[notification.Method] = notification
+		service := Example(true)
+		service.prevOnline = true
+		service.UpdateNotify = null.NewNullBool(true)
+		service.NotifyAfter = 0
+		service.NotifyResend = 30
+		sent := notification.failures
+
+		RecordFailure(&service, "test issue", "lookup")
+		RecordFailure(&service, "test issue", "lookup")
+		RecordFailure(&service, "test issue", "lookup")
+		assert.Equal(t, sent+1, notification.failures)
+
+		RecordFailure(&service, "another issue", "lookup")
+		assert.Equal(t, sent+2, notification.failures)
+
+		service.lastNotified = utils.Now().Add(-31 * time.Minute)
+		RecordFailure(&service, "another issue", "lookup")
+		assert.Equal(t, sent+3, notification.failures)
+
+		RecordSuccess(&service)
+		RecordFailure(&service, "another issue", "lookup")
+		assert.Equal(t, sent+4, notification.failures)
+	})
+
+	t.Run("Flapping - [online, notify once and pause notifications while flapping]", func(t *testing.T) {
+		flapper := &flapNotifier{exampleNotifier: notification}
+		allNotifiers[notification.Method] = flapper
+		defer func() { allNotifiers[notification.Method] = notification }()
+
+		service := Example(true)
+		service.prevOnline = true
+		service.UpdateNotify = null.NewNullBool(false)
+		service.FlapThreshold = 3
+		service.FlapWindow = 600
+		successes, fails := notification.success, notification.failures
+
+		RecordFailure(&service, "test issue", "lookup")
+		RecordSuccess(&service)
+		RecordFailure(&service, "test issue", "lookup")
+		assert.False(t, service.Flapping)
+		assert.Equal(t, successes+1, notification.success)
+		assert.Equal(t, fails+2, notification.failures)
+
+		RecordSuccess(&service)
+		assert.True(t, service.Flapping)
+		assert.Equal(t, 1, flapper.flapping)
+		assert.Equal(t, 4, flapper.changes)
+		assert.Equal(t, successes+1, notification.success)
+
+		RecordFailure(&service, "test issue", "lookup")
+		RecordSuccess(&service)
+		assert.Equal(t, 1, flapper.flapping)
+		assert.Equal(t, successes+1, notification.success)
+		assert.Equal(t, fails+2, notification.failures)
+
+		for i := range service.transitions {
+			service.transitions[i] = utils.Now().Add(-11 * time.Minute)
+		}
+		RecordSuccess(&service)
+		assert.False(t, service.Flapping)
+		assert.Equal(t, successes+2, notification.success)
+
+		RecordFailure(&service, "test issue", "lookup")
+		assert.Equal(t, fails+3, notification.failures)
+	})
+
+	t.Run("Degraded - [slow response, notify the status changes]", func(t *testing.T) {
+		changer := &statusNotifier{exampleNotifier: notification}
+		allNotifiers[notification.Method] = changer
+		defer func() { allNotifiers[notification.Method] = notification }()
+
+		service := Example(true)
+		service.DegradedLatency = 0.5
+		service.Latency = (100 * time.Millisecond).Microseconds()
+		RecordSuccess(&service)
+		assert.Equal(t, StatusUp, service.Status)
+		assert.Empty(t, changer.changes)
+
+		service.Latency = time.Second.Microseconds()
+		RecordSuccess(&service)
+		assert.True(t, service.Online)
+		assert.Equal(t, StatusDegraded, service.Status)
+		assert.Contains(t, service.DegradedReason, "exceeded degraded threshold 0.50s")
+		assert.Equal(t, []string{"up>degraded"}, changer.changes)
+
+		RecordSuccess(&service)
+		assert.Len(t, changer.changes, 1)
+
+		RecordFailure(&service, "test issue", "lookup")
+		assert.Equal(t, StatusDown, service.Status)
+		assert.Equal(t, StatusDown, service.Failures[0].Status)
+		assert.Equal(t, []string{"up>degraded", "degraded>down"}, changer.changes)
+
+		// changes between up and down are only sent as the failure and recover notifications
+		service.Latency = (100 * time.Millisecond).Microseconds()
+		RecordSuccess(&service)
+		assert.Equal(t, StatusUp, service.Status)
+		RecordFailure(&service, "test issue", "lookup")
+		assert.Equal(t, StatusDown, service.Status)
+		assert.Equal(t, []string{"up>degraded", "degraded>down"}, changer.changes)
+	})
+
+	t.Run("Dependency - [upstream offline, no notifications for the dependent]", func(t *testing.T) {
+		changer := &statusNotifier{exampleNotifier: notification}
+		allNotifiers[notification.Method] = changer
+		defer func() { allNotifiers[notification.Method] = notification }()
+
+		upstream := &Service{Id: 990, Name: "Upstream", Status: StatusDown}
+		saved := allServices
+		allServices = map[int64]*Service{upstream.Id: upstream}
+		defer func() { allServices = saved }()
+
+		service := Example(true)
+		service.prevOnline = true
+		service.DependsOn = IdList{upstream.Id}
+		service.setStatus(StatusDegraded, "slow")
+		fails := notification.failures
+
+		RecordFailure(&service, "test issue", "lookup")
+		assert.False(t, service.Online)
+		assert.Equal(t, StatusDown, service.Status)
+		assert.False(t, service.offlineSince.IsZero())
+		assert.Equal(t, fails, notification.failures)
+		assert.Empty(t, changer.changes)
+	})
+
 	t.Run("Test Samples", func(t *testing.T) {
 		require.Nil(t, Samples())
 		assert.Len(t, All(), 11)
@@ -332,3 +508,37 @@ func (e *exampleNotifier) OnTest() (string, error) {
 func (e *exampleNotifier) Valid(form notifications.Values) error {
 	return nil
 }
+
+type recoverNotifier struct {
+	*exampleNotifier
+	recovered int
+	downtime  time.Duration
+}
+
+func (r *recoverNotifier) OnRecover(s Service, downtime time.Duration) (string, error) {
+	r.recovered++
+	r.downtime = downtime
+	return "", nil
+}
+
+type flapNotifier struct {
+	*exampleNotifier
+	flapping int
+	changes  int
+}
+
+func (f *flapNotifier) OnFlapping(s Service, changes int) (string, error) {
+	f.flapping++
+	f.changes = changes
+	return "", nil
+}
+
+type statusNotifier struct {
+	*exampleNotifier
+	changes []string
+}
+
+func (c *statusNotifier) OnStatusChange(s Service, previous, status string) (string, error) {
+	c.changes = append(c.changes, previous+">"+status)
+	return "", nil
+}
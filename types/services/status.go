@@ -0,0 +1,66 @@
+package services
+
+import (
+	"fmt"
+	"time"
+)
+
+// Status of a service, a degraded service is online with an issue that is not a failure
+const (
+	StatusUp       = "up"
+	StatusDegraded = "degraded"
+	StatusDown     = "down"
+)
+
+// markDegraded will mark the successful check as degraded, the first reason of a check is kept
+func (s *Service) markDegraded(reason string) {
+	if s.degraded == "" {
+		s.degraded = reason
+	}
+}
+
+// exceedsDegradedLatency returns true if the latency of the last check is over the DegradedLatency threshold
+func (s *Service) exceedsDegradedLatency() bool {
+	if s.DegradedLatency <= 0 {
+		return false
+	}
+	return time.Duration(s.Latency)*time.Microsecond > time.Duration(s.DegradedLatency*float64(time.Second))
+}
+
+// successStatus returns the status of a successful check, degraded if a checker marked it or the latency
+// was over DegradedLatency, and resets the degraded reason for the next check
+func (s *Service) successStatus() (string, string) {
+	reason := s.degraded
+	s.degraded = ""
+	if reason == "" && s.exceedsDegradedLatency() {
+		latency := (time.Duration(s.Latency) * time.Microsecond).Seconds()
+		reason = fmt.Sprintf("Response time %0.2fs exceeded degraded threshold %0.2fs", latency, s.DegradedLatency)
+	}
+	if reason != "" {
+		return StatusDegraded, reason
+	}
+	return StatusUp, ""
+}
+
+// setStatus will update the status of the service and return the previous status
+func (s *Service) setStatus(status, reason string) string {
+	previous := s.Status
+	s.Status = status
+	s.DegradedReason = reason
+	if status == StatusDegraded && previous != StatusDegraded {
+		log.Warnf("Service %v is degraded: %v", s.Name, reason)
+	}
+	return previous
+}
+
+// notifyStatus will notify the StatusNotifiers if the status changed to or from degraded, nothing is sent for
+// the first status after the service started. Changes between up and down are sent as the failure and
+// recover notifications instead.
+func (s *Service) notifyStatus(previous string) {
+	if previous == "" || previous == s.Status {
+		return
+	}
+	if previous == StatusDegraded || s.Status == StatusDegraded {
+		sendStatusChange(s, previous, s.Status)
+	}
+}
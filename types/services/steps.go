@@ -0,0 +1,167 @@
+package services
+
+import (
+	"crypto/tls"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/statping/statping/types/errors"
+	"github.com/statping/statping/types/failures"
+	"github.com/statping/statping/utils"
+)
+
+// HttpStep is a request of a multi-step HTTP check, such as login, fetch and logout
+type HttpStep struct {
+	Method         string `json:"method"`
+	URL            string `json:"url"`
+	Body           string `json:"body,omitempty"`
+	ExpectedStatus int    `json:"expected_status,omitempty"`
+	Expected       string `json:"expected,omitempty"`
+}
+
+// HttpSteps are the ordered requests of a multi-step HTTP check, stored in the database as a JSON array
+type HttpSteps []HttpStep
+
+// Value will store the steps as a JSON array
+func (h HttpSteps) Value() (driver.Value, error) {
+	if len(h) == 0 {
+		return "", nil
+	}
+	return jsonValue(h)
+}
+
+// Scan will parse the steps from the JSON array in the database
+func (h *HttpSteps) Scan(value interface{}) error {
+	*h = nil
+	return scanJSON(value, h)
+}
+
+// validateSteps returns an error if one of the HttpSteps can't be sent or its expected response is not a valid regex
+func (s *Service) validateSteps() error {
+	for i, step := range s.HttpSteps {
+		if _, err := stepURL(s.Domain, step.URL); err != nil {
+			return errors.New(fmt.Sprintf("step %d url is invalid, %v", i+1, err))
+		}
+		if step.ExpectedStatus < 0 || step.ExpectedStatus > 999 {
+			return errors.New(fmt.Sprintf("step %d expected status must be a HTTP status code", i+1))
+		}
+		if _, err := regexp.Compile(step.Expected); err != nil {
+			return errors.New(fmt.Sprintf("step %d expected response is not a valid regex, %v", i+1, err))
+		}
+	}
+	return nil
+}
+
+// stepURL returns the URL of a step, relative to the domain of the service or the domain if it is empty
+func stepURL(domain, ref string) (string, error) {
+	base, err := url.Parse(domain)
+	if err != nil {
+		return "", err
+	}
+	if ref == "" {
+		return base.String(), nil
+	}
+	target, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(target).String(), nil
+}
+
+// checkHttpSteps will send the HttpSteps in order with the cookies of the previous steps, the service is only
+// online if every step responds with its expected status and body. The failure has the step that failed and
+// its response. The latency is the time of all the steps.
+func (s *Service) checkHttpSteps(record bool, headers []string, contentType string, timeout time.Duration, customTLS *tls.Config, opts utils.HttpOptions, t1 time.Time) (*Service, error) {
+	if opts.Jar == nil {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return s, err
+		}
+		opts.Jar = jar
+	}
+	opts.ReadLimit = 0
+	opts.BodySize = nil
+	var truncated bool
+	opts.Truncated = &truncated
+	var timing utils.HttpTiming
+	for i, step := range s.HttpSteps {
+		method := strings.ToUpper(step.Method)
+		if method == "" {
+			method = http.MethodGet
+		}
+		target, err := stepURL(s.Domain, step.URL)
+		if err != nil {
+			return s, err
+		}
+		var data io.Reader
+		var bodyType interface{}
+		if step.Body != "" && method != http.MethodHead {
+			data = strings.NewReader(s.expandEnv(step.Body, false))
+			bodyType = contentType
+		}
+		opts.Timing = &utils.HttpTiming{}
+		content, res, err := utils.HttpRequestWithOptions(target, method, bodyType, headers, data, timeout, s.verifyTLS(customTLS), customTLS, opts)
+		if i == 0 {
+			timing = *opts.Timing
+			s.RemoteIP = remoteIP(opts.Timing.RemoteAddr)
+		}
+		if err != nil {
+			if record && opts.Context.Err() == nil {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("HTTP Step %d %v %v failed, %v", i+1, method, target, err),
+					Reason:   "connection",
+					Category: errorCategory(err, failures.CategoryConnect),
+					Error:    err.Error(),
+				})
+			}
+			return s, err
+		}
+		s.setLastResponse(content, truncated)
+		s.LastStatusCode = res.StatusCode
+
+		expectedStatus := step.ExpectedStatus
+		if expectedStatus == 0 {
+			expectedStatus = http.StatusOK
+		}
+		if res.StatusCode != expectedStatus {
+			if record {
+				recordFailure(s, &failures.Failure{
+					Issue:    fmt.Sprintf("HTTP Step %d %v %v returned status code %d, expected %d", i+1, method, target, res.StatusCode, expectedStatus),
+					Reason:   "status_code",
+					Expected: strconv.Itoa(expectedStatus),
+					Actual:   strconv.Itoa(res.StatusCode),
+				})
+			}
+			return s, nil
+		}
+		if step.Expected != "" {
+			match, err := regexp.MatchString(s.expandEnv(step.Expected, true), string(content))
+			if err != nil || !match {
+				if record {
+					recordFailure(s, &failures.Failure{
+						Issue:    fmt.Sprintf("HTTP Step %d %v %v response did not match '%v'", i+1, method, target, step.Expected),
+						Reason:   "regex",
+						Expected: step.Expected,
+						Actual:   string(content),
+					})
+				}
+				return s, err
+			}
+		}
+	}
+	s.Latency = utils.Now().Sub(t1).Microseconds()
+	s.timing = timing
+	s.Online = true
+	if record {
+		RecordSuccess(s)
+	}
+	return s, nil
+}
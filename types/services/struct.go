@@ -1,6 +1,9 @@
 package services
 
 import (
+	"net/http"
+	"regexp"
+	"sync"
 	"time"
 
 	"github.com/statping/statping/types/checkins"
@@ -8,6 +11,7 @@ import (
 	"github.com/statping/statping/types/incidents"
 	"github.com/statping/statping/types/messages"
 	"github.com/statping/statping/types/null"
+	"github.com/statping/statping/utils"
 )
 
 // Service is the main struct for Services
@@ -16,45 +20,153 @@ type Service struct {
 	Name                string                `gorm:"column:name" json:"name" yaml:"name"`
 	Domain              string                `gorm:"column:domain" json:"domain" yaml:"domain" private:"true" scope:"user,admin"`
 	Expected            null.NullString       `gorm:"column:expected" json:"expected" yaml:"expected" scope:"user,admin"`
+	ExpectedSource      string                `gorm:"column:expected_source" json:"expected_source" scope:"user,admin" yaml:"expected_source"`
+	ExpectedExact       null.NullBool         `gorm:"default:false;column:expected_exact" json:"expected_exact" scope:"user,admin" yaml:"expected_exact"`
+	ExpandEnv           null.NullBool         `gorm:"default:false;column:expand_env" json:"expand_env" scope:"user,admin" yaml:"expand_env"`
+	CaseInsensitive     null.NullBool         `gorm:"default:false;column:case_insensitive" json:"case_insensitive" scope:"user,admin" yaml:"case_insensitive"`
+	Multiline           null.NullBool         `gorm:"default:false;column:multiline" json:"multiline" scope:"user,admin" yaml:"multiline"`
 	ExpectedStatus      int                   `gorm:"default:200;column:expected_status" json:"expected_status" yaml:"expected_status" scope:"user,admin"`
+	ExpectedStatusCodes string                `gorm:"column:expected_status_codes" json:"expected_status_codes" yaml:"expected_status_codes" scope:"user,admin"`
 	Interval            int                   `gorm:"default:30;column:check_interval" json:"check_interval" yaml:"check_interval"`
+	IntervalUnit        string                `gorm:"default:'s';column:check_interval_unit" json:"check_interval_unit" yaml:"check_interval_unit"`
+	Jitter              int                   `gorm:"default:0;column:jitter" json:"jitter" yaml:"jitter"`
 	Type                string                `gorm:"column:check_type" json:"type" scope:"user,admin" yaml:"type"`
 	Method              string                `gorm:"column:method" json:"method" scope:"user,admin" yaml:"method"`
 	PostData            null.NullString       `gorm:"column:post_data" json:"post_data" scope:"user,admin" yaml:"post_data"`
+	PostDataType        string                `gorm:"default:'application/json';column:post_data_type" json:"post_data_type" scope:"user,admin" yaml:"post_data_type"`
+	PreRequestURL       string                `gorm:"column:pre_request_url" json:"pre_request_url" scope:"user,admin" yaml:"pre_request_url"`
+	PreRequestMethod    string                `gorm:"column:pre_request_method" json:"pre_request_method" scope:"user,admin" yaml:"pre_request_method"`
+	PreRequestData      null.NullString       `gorm:"column:pre_request_data" json:"pre_request_data" scope:"user,admin" yaml:"pre_request_data"`
 	Port                int                   `gorm:"not null;column:port" json:"port" scope:"user,admin" yaml:"port"`
+	Ports               PortList              `gorm:"column:ports;type:text" json:"ports" scope:"user,admin" yaml:"ports"`
+	TcpReadLimit        int                   `gorm:"default:0;column:tcp_read_limit" json:"tcp_read_limit" scope:"user,admin" yaml:"tcp_read_limit"`
+	MaxResponseSize     int64                 `gorm:"default:0;column:max_response_size" json:"max_response_size" scope:"user,admin" yaml:"max_response_size"`
+	ReadLimit           int                   `gorm:"default:0;column:read_limit" json:"read_limit" scope:"user,admin" yaml:"read_limit"`
+	ResponseOnFailure   null.NullBool         `gorm:"default:false;column:response_on_failure" json:"response_on_failure" scope:"user,admin" yaml:"response_on_failure"`
+	IPVersion           string                `gorm:"column:ip_version" json:"ip_version" scope:"user,admin" yaml:"ip_version"`
+	SourceIP            string                `gorm:"column:source_ip" json:"source_ip" scope:"user,admin" yaml:"source_ip"`
 	Timeout             int                   `gorm:"default:30;column:timeout" json:"timeout" scope:"user,admin" yaml:"timeout"`
+	ConnectTimeout      int                   `gorm:"default:0;column:connect_timeout" json:"connect_timeout" scope:"user,admin" yaml:"connect_timeout"`
+	PingCount           int                   `gorm:"default:1;column:ping_count" json:"ping_count" scope:"user,admin" yaml:"ping_count"`
+	LatencyMode         string                `gorm:"column:latency_mode" json:"latency_mode" scope:"user,admin" yaml:"latency_mode"`
+	MaxPacketLoss       float64               `gorm:"default:0;column:max_packet_loss" json:"max_packet_loss" scope:"user,admin" yaml:"max_packet_loss"`
+	Privileged          null.NullBool         `gorm:"default:false;column:privileged" json:"privileged" scope:"user,admin" yaml:"privileged"`
+	MaxLatency          float64               `gorm:"default:0;column:max_latency" json:"max_latency" scope:"user,admin" yaml:"max_latency"`
+	MaxClockOffset      float64               `gorm:"default:0;column:max_clock_offset" json:"max_clock_offset" scope:"user,admin" yaml:"max_clock_offset"`
+	DegradedLatency     float64               `gorm:"default:0;column:degraded_latency" json:"degraded_latency" scope:"user,admin" yaml:"degraded_latency"`
+	LatencyAlpha        float64               `gorm:"default:0;column:latency_alpha" json:"latency_alpha" scope:"user,admin" yaml:"latency_alpha"`
+	AnomalyDeviations   float64               `gorm:"default:0;column:anomaly_deviations" json:"anomaly_deviations" scope:"user,admin" yaml:"anomaly_deviations"`
+	AnomalyMinSamples   int                   `gorm:"default:0;column:anomaly_min_samples" json:"anomaly_min_samples" scope:"user,admin" yaml:"anomaly_min_samples"`
+	ExpectClosed        null.NullBool         `gorm:"default:false;column:expect_closed" json:"expect_closed" scope:"user,admin" yaml:"expect_closed"`
+	Inverted            null.NullBool         `gorm:"default:false;column:inverted" json:"inverted" scope:"user,admin" yaml:"inverted"`
+	RetryCount          int                   `gorm:"default:0;column:retry_count" json:"retry_count" scope:"user,admin" yaml:"retry_count"`
+	RetryStatuses       string                `gorm:"column:retry_statuses" json:"retry_statuses" scope:"user,admin" yaml:"retry_statuses"`
+	RetryInterval       int                   `gorm:"default:0;column:retry_interval" json:"retry_interval" scope:"user,admin" yaml:"retry_interval"`
+	DownBackoff         int                   `gorm:"default:0;column:down_backoff" json:"down_backoff" scope:"user,admin" yaml:"down_backoff"`
+	DownBackoffMax      int                   `gorm:"default:0;column:down_backoff_max" json:"down_backoff_max" scope:"user,admin" yaml:"down_backoff_max"`
+	IntervalDown        int                   `gorm:"default:0;column:interval_down" json:"interval_down" scope:"user,admin" yaml:"interval_down"`
+	HitSampleRate       int                   `gorm:"default:1;column:hit_sample_rate" json:"hit_sample_rate" scope:"user,admin" yaml:"hit_sample_rate"`
+	HitMinInterval      int                   `gorm:"default:0;column:hit_min_interval" json:"hit_min_interval" scope:"user,admin" yaml:"hit_min_interval"`
 	Order               int                   `gorm:"default:0;column:order_id" json:"order_id" yaml:"order_id"`
 	VerifySSL           null.NullBool         `gorm:"default:false;column:verify_ssl" json:"verify_ssl" scope:"user,admin" yaml:"verify_ssl"`
 	GrpcHealthCheck     null.NullBool         `gorm:"default:false;column:grpc_health_check" json:"grpc_health_check" scope:"user,admin" yaml:"grpc_health_check"`
+	GrpcService         string                `gorm:"column:grpc_service" json:"grpc_service" scope:"user,admin" yaml:"grpc_service"`
+	GrpcMode            string                `gorm:"column:grpc_mode" json:"grpc_mode" scope:"user,admin" yaml:"grpc_mode"`
+	GrpcMetadata        HttpHeaders           `gorm:"column:grpc_metadata;type:text" json:"grpc_metadata" scope:"user,admin" yaml:"grpc_metadata"`
+	DnsRecordType       string                `gorm:"column:dns_record_type" json:"dns_record_type" scope:"user,admin" yaml:"dns_record_type"`
+	RequireDNSSEC       null.NullBool         `gorm:"default:false;column:require_dnssec" json:"require_dnssec" scope:"user,admin" yaml:"require_dnssec"`
+	DnsResolver         null.NullString       `gorm:"column:dns_resolver" json:"dns_resolver" scope:"user,admin" yaml:"dns_resolver"`
+	DnsCacheTTL         int                   `gorm:"default:0;column:dns_cache_ttl" json:"dns_cache_ttl" scope:"user,admin" yaml:"dns_cache_ttl"`
 	Public              null.NullBool         `gorm:"default:true;column:public" json:"public" yaml:"public"`
 	GroupId             int                   `gorm:"default:0;column:group_id" json:"group_id" yaml:"group_id"`
 	TLSCert             null.NullString       `gorm:"column:tls_cert" json:"tls_cert" scope:"user,admin" yaml:"tls_cert"`
 	TLSCertKey          null.NullString       `gorm:"column:tls_cert_key" json:"tls_cert_key" scope:"user,admin" yaml:"tls_cert_key"`
 	TLSCertRoot         null.NullString       `gorm:"column:tls_cert_root" json:"tls_cert_root" scope:"user,admin" yaml:"tls_cert_root"`
 	Headers             null.NullString       `gorm:"column:headers" json:"headers" scope:"user,admin" yaml:"headers"`
+	HttpHeaders         HttpHeaders           `gorm:"column:http_headers;type:text" json:"http_headers" scope:"user,admin" yaml:"http_headers"`
+	BasicAuthUser       null.NullString       `gorm:"column:basic_auth_user" json:"basic_auth_user" scope:"user,admin" yaml:"basic_auth_user"`
+	BasicAuthPass       null.NullString       `gorm:"column:basic_auth_pass" json:"basic_auth_pass" scope:"user,admin" yaml:"basic_auth_pass"`
+	UserAgent           string                `gorm:"column:user_agent" json:"user_agent" scope:"user,admin" yaml:"user_agent"`
+	BearerToken         null.NullString       `gorm:"column:bearer_token" json:"bearer_token" scope:"user,admin" yaml:"bearer_token"`
+	ExpectedJSONPath    string                `gorm:"column:expected_json_path" json:"expected_json_path" scope:"user,admin" yaml:"expected_json_path"`
+	ExpectedJSONValue   string                `gorm:"column:expected_json_value" json:"expected_json_value" scope:"user,admin" yaml:"expected_json_value"`
+	ExpectedChecksum    string                `gorm:"column:expected_checksum" json:"expected_checksum" scope:"user,admin" yaml:"expected_checksum"`
+	ExpectedSchema      string                `gorm:"column:expected_schema;type:text" json:"expected_schema" scope:"user,admin" yaml:"expected_schema"`
+	ExpectedHeaders     null.NullString       `gorm:"column:expected_headers" json:"expected_headers" scope:"user,admin" yaml:"expected_headers"`
+	ExpectedRedirect    string                `gorm:"column:expected_redirect" json:"expected_redirect" scope:"user,admin" yaml:"expected_redirect"`
+	MqttSubscribe       string                `gorm:"column:mqtt_subscribe" json:"mqtt_subscribe" scope:"user,admin" yaml:"mqtt_subscribe"`
+	MqttPublish         string                `gorm:"column:mqtt_publish" json:"mqtt_publish" scope:"user,admin" yaml:"mqtt_publish"`
+	ExpectedPatterns    null.NullString       `gorm:"column:expected_patterns" json:"expected_patterns" scope:"user,admin" yaml:"expected_patterns"`
+	ExpectedMatch       string                `gorm:"column:expected_match" json:"expected_match" scope:"user,admin" yaml:"expected_match"`
+	MinContentLength    int                   `gorm:"default:0;column:min_content_length" json:"min_content_length" scope:"user,admin" yaml:"min_content_length"`
 	Permalink           null.NullString       `gorm:"column:permalink" json:"permalink" yaml:"permalink"`
+	Proxy               string                `gorm:"column:proxy" json:"proxy" scope:"user,admin" yaml:"proxy"`
 	Redirect            null.NullBool         `gorm:"default:false;column:redirect" json:"redirect" scope:"user,admin" yaml:"redirect"`
+	ForceH2C            null.NullBool         `gorm:"default:false;column:force_h2c" json:"force_h2c" scope:"user,admin" yaml:"force_h2c"`
+	MaintenanceStart    time.Time             `gorm:"column:maintenance_start" json:"maintenance_start" scope:"user,admin" yaml:"maintenance_start"`
+	MaintenanceEnd      time.Time             `gorm:"column:maintenance_end" json:"maintenance_end" scope:"user,admin" yaml:"maintenance_end"`
+	MaintenanceRepeat   string                `gorm:"column:maintenance_repeat" json:"maintenance_repeat" scope:"user,admin" yaml:"maintenance_repeat"`
+	ActiveSchedule      string                `gorm:"column:active_schedule" json:"active_schedule" scope:"user,admin" yaml:"active_schedule"`
+	ScheduleTimezone    string                `gorm:"column:schedule_timezone" json:"schedule_timezone" scope:"user,admin" yaml:"schedule_timezone"`
+	MaintenanceStatus   int                   `gorm:"default:0;column:maintenance_status" json:"maintenance_status" scope:"user,admin" yaml:"maintenance_status"`
+	MaintenanceExpected string                `gorm:"column:maintenance_expected" json:"maintenance_expected" scope:"user,admin" yaml:"maintenance_expected"`
+	SSLExpiryWarning    int                   `gorm:"default:0;column:ssl_expiry_warning" json:"ssl_expiry_warning" scope:"user,admin" yaml:"ssl_expiry_warning"`
+	ExpectedProtocol    string                `gorm:"column:expected_protocol" json:"expected_protocol" scope:"user,admin" yaml:"expected_protocol"`
+	MinTLSVersion       string                `gorm:"column:min_tls_version" json:"min_tls_version" scope:"user,admin" yaml:"min_tls_version"`
+	TLSServerName       string                `gorm:"column:tls_server_name" json:"tls_server_name" scope:"user,admin" yaml:"tls_server_name"`
+	TLSSkipHostname     null.NullBool         `gorm:"default:false;column:tls_skip_hostname" json:"tls_skip_hostname" scope:"user,admin" yaml:"tls_skip_hostname"`
+	HostHeader          string                `gorm:"column:host_header" json:"host_header" scope:"user,admin" yaml:"host_header"`
+	Endpoints           StringList            `gorm:"column:endpoints;type:text" json:"endpoints" scope:"user,admin" yaml:"endpoints"`
+	DependsOn           IdList                `gorm:"column:depends_on;type:text" json:"depends_on" scope:"user,admin" yaml:"depends_on"`
+	Labels              LabelMap              `gorm:"column:labels;type:text" json:"labels" scope:"user,admin" yaml:"labels"`
+	HttpSteps           HttpSteps             `gorm:"column:http_steps;type:text" json:"http_steps" scope:"user,admin" yaml:"http_steps"`
+	Quorum              int                   `gorm:"default:0;column:quorum" json:"quorum" scope:"user,admin" yaml:"quorum"`
+	AllResolvedIPs      null.NullBool         `gorm:"default:false;column:all_resolved_ips" json:"all_resolved_ips" scope:"user,admin" yaml:"all_resolved_ips"`
 	CreatedAt           time.Time             `gorm:"column:created_at" json:"created_at" yaml:"-"`
 	UpdatedAt           time.Time             `gorm:"column:updated_at" json:"updated_at" yaml:"-"`
 	Online              bool                  `gorm:"-" json:"online" yaml:"-"`
+	InMaintenance       bool                  `gorm:"-" json:"in_maintenance" yaml:"-"`
+	OffSchedule         bool                  `gorm:"-" json:"off_schedule" yaml:"-"`
+	CheckOverrun        bool                  `gorm:"-" json:"check_overrun" yaml:"-" scope:"user,admin"`
 	Latency             int64                 `gorm:"-" json:"latency" yaml:"-"`
 	PingTime            int64                 `gorm:"-" json:"ping_time" yaml:"-"`
+	PacketLoss          float64               `gorm:"-" json:"packet_loss" yaml:"-"`
 	Online24Hours       float32               `gorm:"-" json:"online_24_hours" yaml:"-"`
 	Online7Days         float32               `gorm:"-" json:"online_7_days" yaml:"-"`
 	AvgResponse         int64                 `gorm:"-" json:"avg_response" yaml:"-"`
+	LatencyP50          int64                 `gorm:"-" json:"latency_p50" yaml:"-"`
+	LatencyP95          int64                 `gorm:"-" json:"latency_p95" yaml:"-"`
+	LatencyP99          int64                 `gorm:"-" json:"latency_p99" yaml:"-"`
+	SmoothedLatency     int64                 `gorm:"-" json:"smoothed_latency" yaml:"-"`
 	FailuresLast24Hours int                   `gorm:"-" json:"failures_24_hours" yaml:"-"`
 	Running             chan bool             `gorm:"-" json:"-" yaml:"-"`
 	Checkpoint          time.Time             `gorm:"-" json:"-" yaml:"-"`
 	SleepDuration       time.Duration         `gorm:"-" json:"-" yaml:"-"`
 	LastResponse        string                `gorm:"-" json:"-" yaml:"-"`
+	ResponseTruncated   bool                  `gorm:"-" json:"response_truncated,omitempty" yaml:"-"`
 	NotifyAfter         int64                 `gorm:"column:notify_after" json:"notify_after" yaml:"notify_after" scope:"user,admin"`
+	FailureThreshold    int                   `gorm:"default:1;column:failure_threshold" json:"failure_threshold" yaml:"failure_threshold" scope:"user,admin"`
+	CurrentFailureCount int                   `gorm:"-" json:"current_failure_count" yaml:"-"`
 	AllowNotifications  null.NullBool         `gorm:"default:true;column:allow_notifications" json:"allow_notifications" yaml:"allow_notifications" scope:"user,admin"`
+	NotifyResend        int                   `gorm:"default:0;column:notify_resend" json:"notify_resend" yaml:"notify_resend" scope:"user,admin"`
+	FlapThreshold       int                   `gorm:"default:0;column:flap_threshold" json:"flap_threshold" yaml:"flap_threshold" scope:"user,admin"`
+	FlapWindow          int                   `gorm:"default:0;column:flap_window" json:"flap_window" yaml:"flap_window" scope:"user,admin"`
+	Flapping            bool                  `gorm:"-" json:"flapping" yaml:"-"`
+	Status              string                `gorm:"-" json:"status" yaml:"-"`
+	DegradedReason      string                `gorm:"-" json:"degraded_reason,omitempty" yaml:"-"`
 	UpdateNotify        null.NullBool         `gorm:"default:true;column:notify_all_changes" json:"notify_all_changes" yaml:"notify_all_changes" scope:"user,admin"` // This Variable is a simple copy of `core.CoreApp.UpdateNotify.Bool`
 	DownText            string                `gorm:"-" json:"-" yaml:"-"`                                                                                           // Contains the current generated Downtime Text 	// Is 'true' if the user has already be informed that the Services now again available // Is 'true' if the user has already be informed that the Services now again available
 	LastStatusCode      int                   `gorm:"-" json:"status_code" yaml:"-"`
+	RemoteIP            string                `gorm:"-" json:"remote_ip,omitempty" yaml:"-" scope:"user,admin"`
+	Protocol            string                `gorm:"-" json:"protocol,omitempty" yaml:"-"`
 	LastLookupTime      int64                 `gorm:"-" json:"-" yaml:"-"`
 	LastLatency         int64                 `gorm:"-" json:"-" yaml:"-"`
 	LastCheck           time.Time             `gorm:"-" json:"-" yaml:"-"`
+	TLSExpiry           time.Time             `gorm:"-" json:"tls_expiry,omitempty" yaml:"-"`
+	TLSExpiresIn        float64               `gorm:"-" json:"tls_expires_in,omitempty" yaml:"-"`
+	TLSHealth           *TLSHealth            `gorm:"-" json:"tls_health,omitempty" yaml:"-"`
+	ResolvedIPs         []ResolvedIP          `gorm:"-" json:"resolved_ips,omitempty" yaml:"-"`
+	HttpClient          *http.Client          `gorm:"-" json:"-" yaml:"-"`
 	LastOnline          time.Time             `gorm:"-" json:"last_success" yaml:"-"`
 	LastOffline         time.Time             `gorm:"-" json:"last_error" yaml:"-"`
 	Stats               *Stats                `gorm:"-" json:"stats,omitempty" yaml:"-"`
@@ -63,8 +175,38 @@ type Service struct {
 	Checkins            []*checkins.Checkin   `gorm:"foreignkey:service;association_foreignkey:id" json:"checkins,omitempty" yaml:"-" scope:"user,admin"`
 	Failures            []*failures.Failure   `gorm:"-" json:"failures,omitempty" yaml:"-" scope:"user,admin"`
 
-	notifyAfterCount int64 `gorm:"-" json:"-" yaml:"-"`
-	prevOnline       bool  `gorm:"-" json:"-" yaml:"-"`
+	notifyAfterCount int64            `gorm:"-" json:"-" yaml:"-"`
+	prevOnline       bool             `gorm:"-" json:"-" yaml:"-"`
+	offlineSince     time.Time        `gorm:"-" json:"-" yaml:"-"`
+	lastNotified     time.Time        `gorm:"-" json:"-" yaml:"-"`
+	lastNotifyIssue  string           `gorm:"-" json:"-" yaml:"-"`
+	responses        *responseHistory `gorm:"-" json:"-" yaml:"-"`
+	timing           utils.HttpTiming `gorm:"-" json:"-" yaml:"-"`
+	responseSize     int64            `gorm:"-" json:"-" yaml:"-"`
+	dnsCacheHost     string           `gorm:"-" json:"-" yaml:"-"`
+	dnsCacheExpires  time.Time        `gorm:"-" json:"-" yaml:"-"`
+	dnsCacheLookup   int64            `gorm:"-" json:"-" yaml:"-"`
+	dnsCached        bool             `gorm:"-" json:"-" yaml:"-"`
+	maintenanceResp  bool             `gorm:"-" json:"-" yaml:"-"`
+	forced           chan chan bool   `gorm:"-" json:"-" yaml:"-"`
+	hitsSkipped      int              `gorm:"-" json:"-" yaml:"-"`
+	warmupUntil      time.Time        `gorm:"-" json:"-" yaml:"-"`
+	expectedCache    *cachedExpected  `gorm:"-" json:"-" yaml:"-"`
+	expectedRegex    *regexp.Regexp   `gorm:"-" json:"-" yaml:"-"`
+	redirectRegex    *regexp.Regexp   `gorm:"-" json:"-" yaml:"-"`
+	expectedSchema   *cachedSchema    `gorm:"-" json:"-" yaml:"-"`
+	tlsHealthLeaf    []byte           `gorm:"-" json:"-" yaml:"-"`
+	latencyMean      float64          `gorm:"-" json:"-" yaml:"-"`
+	latencyVariance  float64          `gorm:"-" json:"-" yaml:"-"`
+	latencySamples   int              `gorm:"-" json:"-" yaml:"-"`
+	lastHitSaved     time.Time        `gorm:"-" json:"-" yaml:"-"`
+	transitions      []time.Time      `gorm:"-" json:"-" yaml:"-"`
+	probed           *probeResult     `gorm:"-" json:"-" yaml:"-"`
+	dialIP           string           `gorm:"-" json:"-" yaml:"-"`
+	degraded         string           `gorm:"-" json:"-" yaml:"-"`
+	checking         *sync.Mutex      `gorm:"-" json:"-" yaml:"-"`
+	exportedName     string           `gorm:"-" json:"-" yaml:"-"`
+	latencies        []hitLatency     `gorm:"-" json:"-" yaml:"-"`
 }
 
 // ServiceOrder will reorder the services based on 'order_id' (Order)
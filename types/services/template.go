@@ -0,0 +1,40 @@
+package services
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+// envReference matches the ${VAR} references that are expanded when ExpandEnv is set
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv returns the value with the ${VAR} references replaced by the environment variables when ExpandEnv
+// is set, the variables are quoted for values used as a regex. An unset variable is logged and expanded to an
+// empty string.
+func (s *Service) expandEnv(value string, quote bool) string {
+	if !s.ExpandEnv.Bool || !strings.Contains(value, "${") {
+		return value
+	}
+	return envReference.ReplaceAllStringFunc(value, func(reference string) string {
+		name := envReference.FindStringSubmatch(reference)[1]
+		env, ok := os.LookupEnv(name)
+		if !ok {
+			log.Errorf("Service %v references the environment variable %v which is not set", s.Name, name)
+		}
+		if quote {
+			return regexp.QuoteMeta(env)
+		}
+		return env
+	})
+}
+
+// expectedValue returns the inline Expected response with the environment variables expanded
+func (s *Service) expectedValue() string {
+	return s.expandEnv(s.Expected.String, s.usesExpectedRegex())
+}
+
+// postData returns the PostData with the environment variables expanded
+func (s *Service) postData() string {
+	return s.expandEnv(s.PostData.String, false)
+}
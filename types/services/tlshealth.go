@@ -0,0 +1,92 @@
+package services
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"time"
+
+	"github.com/statping/statping/utils"
+)
+
+// tlsHealthRefresh is how often an unchanged certificate chain is verified again
+const tlsHealthRefresh = time.Hour
+
+// TLSHealth is the certificate chain of a HTTPS service, the chain is verified even when VerifySSL is
+// disabled and an invalid chain does not fail the check
+type TLSHealth struct {
+	ChainValid bool      `json:"chain_valid"`
+	ChainError string    `json:"chain_error,omitempty"`
+	Subject    string    `json:"subject"`
+	Issuer     string    `json:"issuer"`
+	SANs       []string  `json:"sans"`
+	NotBefore  time.Time `json:"not_before"`
+	NotAfter   time.Time `json:"not_after"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// updateTLSHealth will verify the certificate chain of the connection for the host, the last result is kept
+// until the leaf certificate changes or it is older than tlsHealthRefresh
+func (s *Service) updateTLSHealth(state *tls.ConnectionState, host string) {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		s.TLSHealth = nil
+		s.tlsHealthLeaf = nil
+		return
+	}
+	leaf := state.PeerCertificates[0]
+	now := utils.Now()
+	if s.TLSHealth != nil && bytes.Equal(s.tlsHealthLeaf, leaf.Raw) && now.Sub(s.TLSHealth.CheckedAt) < tlsHealthRefresh {
+		return
+	}
+	health := verifyChain(state.PeerCertificates, s.tlsHealthRoots(), host, now)
+	if !health.ChainValid && (s.TLSHealth == nil || s.TLSHealth.ChainValid) {
+		log.Warnf("Service %v certificate chain would not validate: %v", s.Name, health.ChainError)
+	}
+	s.TLSHealth = health
+	s.tlsHealthLeaf = leaf.Raw
+}
+
+// tlsHealthRoots returns the Root CA pool of the service, or nil to verify with the system roots
+func (s *Service) tlsHealthRoots() *x509.CertPool {
+	if s.TLSCertRoot.String == "" {
+		return nil
+	}
+	config, err := s.LoadTLSCert()
+	if err != nil || config == nil {
+		return nil
+	}
+	return config.RootCAs
+}
+
+// verifyChain returns the TLS health of the peer certificates, the first certificate is the leaf and the
+// others are used as intermediates
+func verifyChain(certs []*x509.Certificate, roots *x509.CertPool, host string, now time.Time) *TLSHealth {
+	leaf := certs[0]
+	health := &TLSHealth{
+		Subject:   leaf.Subject.String(),
+		Issuer:    leaf.Issuer.String(),
+		SANs:      append([]string{}, leaf.DNSNames...),
+		NotBefore: leaf.NotBefore,
+		NotAfter:  leaf.NotAfter,
+		CheckedAt: now,
+	}
+	for _, ip := range leaf.IPAddresses {
+		health.SANs = append(health.SANs, ip.String())
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+	})
+	if err != nil {
+		health.ChainError = err.Error()
+		return health
+	}
+	health.ChainValid = true
+	return health
+}
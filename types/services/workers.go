@@ -0,0 +1,163 @@
+package services
+
+import (
+	"github.com/statping/statping/types/metrics"
+	"github.com/statping/statping/utils"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// checkPool limits the amount of checks that run at the same time across all services
+type checkPool struct {
+	slots chan struct{}
+}
+
+var (
+	checks     *checkPool
+	checksOnce sync.Once
+	// checkLocksMu guards creating the lock of each service
+	checkLocksMu sync.Mutex
+)
+
+// checkLock returns the lock held while the service is checked and its stats are updated, so the scheduled,
+// forced and manual checks of a service never run at the same time
+func (s *Service) checkLock() *sync.Mutex {
+	checkLocksMu.Lock()
+	defer checkLocksMu.Unlock()
+	if s.checking == nil {
+		s.checking = &sync.Mutex{}
+	}
+	return s.checking
+}
+
+// checkNow will check the service in a slot of the worker pool and update its stats while holding its checkLock
+func (s *Service) checkNow(record bool) {
+	lock := s.checkLock()
+	lock.Lock()
+	defer lock.Unlock()
+	if _, ok := s.pooledCheck(record, nil); !ok {
+		return
+	}
+	s.UpdateStats()
+}
+
+// pooledCheck will wait for a slot in the global worker pool and check the service, it returns the duration
+// of the check or false if the service was stopped while waiting. The checkLock must be held so a check
+// waiting for the lock never holds a slot, and the slot is released even if the check panics.
+func (s *Service) pooledCheck(record bool, stop <-chan bool) (time.Duration, bool) {
+	pool := checkWorkers()
+	if !pool.acquire(stop) {
+		return 0, false
+	}
+	defer pool.release()
+	addCheckerGauge(&checkerRunning, "running", 1)
+	defer addCheckerGauge(&checkerRunning, "running", -1)
+	checkStart := utils.Now()
+	s.CheckService(record)
+	checkDuration := utils.Now().Sub(checkStart)
+	checkFinished(checkDuration)
+	return checkDuration, true
+}
+
+// CheckerStats is the health of the checker itself, returned from the API to tell if the checker is the
+// bottleneck instead of the monitored services
+type CheckerStats struct {
+	Routines        int64   `json:"routines"`
+	Running         int64   `json:"running"`
+	Queued          int64   `json:"queued"`
+	MaxConcurrent   int     `json:"max_concurrent"`
+	Checks          int64   `json:"checks"`
+	AverageDuration float64 `json:"average_duration"`
+}
+
+var (
+	checkerRoutines int64
+	checkerRunning  int64
+	checkerQueued   int64
+	checkerChecks   int64
+	checkerDuration int64
+)
+
+// Checker returns the go routines checking a service, the checks running and waiting for a slot, and the
+// average duration of the checks in milliseconds
+func Checker() CheckerStats {
+	stats := CheckerStats{
+		Routines:      atomic.LoadInt64(&checkerRoutines),
+		Running:       atomic.LoadInt64(&checkerRunning),
+		Queued:        atomic.LoadInt64(&checkerQueued),
+		MaxConcurrent: cap(checkWorkers().slots),
+		Checks:        atomic.LoadInt64(&checkerChecks),
+	}
+	if stats.Checks > 0 {
+		average := time.Duration(atomic.LoadInt64(&checkerDuration) / stats.Checks)
+		stats.AverageDuration = float64(average) / float64(time.Millisecond)
+	}
+	return stats
+}
+
+// addCheckerGauge will add delta to the counter and update its Prometheus gauge
+func addCheckerGauge(counter *int64, method string, delta int64) {
+	metrics.CheckerGauge(method, float64(atomic.AddInt64(counter, delta)))
+}
+
+// checkFinished will count a check that took duration
+func checkFinished(duration time.Duration) {
+	atomic.AddInt64(&checkerChecks, 1)
+	atomic.AddInt64(&checkerDuration, int64(duration))
+	metrics.CheckerTimer().Observe(duration.Seconds())
+}
+
+// newCheckPool returns a checkPool with size slots, there is no limit if size is 0 or less
+func newCheckPool(size int) *checkPool {
+	if size <= 0 {
+		return &checkPool{}
+	}
+	return &checkPool{slots: make(chan struct{}, size)}
+}
+
+// checkWorkers returns the global checkPool sized from MAX_CONCURRENT_CHECKS
+func checkWorkers() *checkPool {
+	checksOnce.Do(func() {
+		size := 0
+		if utils.Params != nil {
+			size = utils.Params.GetInt("MAX_CONCURRENT_CHECKS")
+		}
+		checks = newCheckPool(size)
+	})
+	return checks
+}
+
+// acquire will wait for an available slot, returns false if the service was stopped while waiting
+func (p *checkPool) acquire(stop <-chan bool) bool {
+	if p.slots == nil {
+		return true
+	}
+	addCheckerGauge(&checkerQueued, "queued", 1)
+	defer addCheckerGauge(&checkerQueued, "queued", -1)
+	select {
+	case p.slots <- struct{}{}:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// release will free the slot taken by acquire
+func (p *checkPool) release() {
+	if p.slots == nil {
+		return
+	}
+	<-p.slots
+}
+
+// nextCheckpoint returns the next scheduled check after now, checks that were missed while
+// waiting for a slot are skipped so the service stays on its schedule instead of catching up
+func nextCheckpoint(checkpoint, now time.Time, interval time.Duration) time.Time {
+	checkpoint = checkpoint.Add(interval)
+	if interval <= 0 || checkpoint.After(now) {
+		return checkpoint
+	}
+	missed := now.Sub(checkpoint)/interval + 1
+	return checkpoint.Add(missed * interval)
+}
@@ -59,6 +59,16 @@ func InitEnvs() {
 	Params.SetDefault("LOGS_MAX_AGE", 28)
 	Params.SetDefault("LOGS_MAX_SIZE", 16)
 	Params.SetDefault("DISABLE_COLORS", false)
+	Params.SetDefault("CHECK_JITTER", 0)
+	Params.SetDefault("RESPONSE_HISTORY", 10)
+	Params.SetDefault("RESPONSE_ON_FAILURE", false)
+	Params.SetDefault("LATENCY_WINDOW", 1*time.Hour)
+	Params.SetDefault("MAX_CONCURRENT_CHECKS", 100)
+	Params.SetDefault("DNS_RESOLVER", "")
+	Params.SetDefault("TIMEZONE", "UTC")
+	Params.SetDefault("WARMUP_PERIOD", 30*time.Second)
+	Params.SetDefault("MAX_REQUEST_SIZE", 0)
+	Params.SetDefault("MAX_RESPONSE_SIZE", 0)
 
 	dbConn := Params.GetString("DB_CONN")
 	dbInt := Params.GetInt("DB_PORT")
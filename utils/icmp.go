@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+var (
+	// ErrPingUnavailable is returned by PingCount when the system ping command is missing or not permitted to run
+	ErrPingUnavailable = errors.New("ping command is not available")
+	// ErrPingPermission is returned by PingICMP when the ICMP socket could not be opened because of missing privileges
+	ErrPingPermission = errors.New("permission denied opening ICMP socket, run as root, grant CAP_NET_RAW or add the group to net.ipv4.ping_group_range")
+)
+
+// PingICMP will send count ICMP echo requests to the address from within the process and return the average,
+// first and minimum latency and the packet loss. Privileged uses a raw socket which requires root or CAP_NET_RAW, otherwise an
+// unprivileged UDP ICMP socket is used, which on Linux requires the group to be in net.ipv4.ping_group_range
+func PingICMP(address string, count, secondsTimeout int, privileged bool) (*PingResult, error) {
+	return PingICMPFrom(address, "", count, secondsTimeout, privileged)
+}
+
+// PingICMPFrom is the same as PingICMP with the socket bound to the source address, any address is used if empty
+func PingICMPFrom(address, source string, count, secondsTimeout int, privileged bool) (*PingResult, error) {
+	if count < 1 {
+		count = 1
+	}
+	ipAddr, err := net.ResolveIPAddr("ip", address)
+	if err != nil {
+		return nil, err
+	}
+
+	network, listen, protocol := "udp4", "0.0.0.0", 1
+	var echoType icmp.Type = ipv4.ICMPTypeEcho
+	var replyType icmp.Type = ipv4.ICMPTypeEchoReply
+	if ipAddr.IP.To4() == nil {
+		network, listen, protocol = "udp6", "::", 58
+		echoType, replyType = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+	if source != "" {
+		ip := net.ParseIP(source)
+		if ip == nil || (ip.To4() == nil) != (protocol == 58) {
+			return nil, fmt.Errorf("source address '%v' is not an address of the same IP version as %v", source, ipAddr)
+		}
+		listen = ip.String()
+	}
+	var dst net.Addr = &net.UDPAddr{IP: ipAddr.IP, Zone: ipAddr.Zone}
+	if privileged {
+		network = map[string]string{"udp4": "ip4:icmp", "udp6": "ip6:ipv6-icmp"}[network]
+		dst = ipAddr
+	}
+
+	conn, err := icmp.ListenPacket(network, listen)
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			return nil, fmt.Errorf("%w: %v", ErrPingPermission, err)
+		}
+		return nil, err
+	}
+	defer conn.Close()
+
+	// the kernel replaces the ID with the local port for unprivileged sockets, so it is only compared on raw sockets
+	id := os.Getpid() & 0xffff
+	matchID := -1
+	if privileged {
+		matchID = id
+	}
+	timeout := time.Duration(secondsTimeout) * time.Second
+	var received int
+	var total, first, min time.Duration
+	for seq := 0; seq < count; seq++ {
+		msg := icmp.Message{
+			Type: echoType,
+			Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("statping")},
+		}
+		data, err := msg.Marshal(nil)
+		if err != nil {
+			return nil, err
+		}
+		sent := time.Now()
+		if _, err := conn.WriteTo(data, dst); err != nil {
+			if errors.Is(err, os.ErrPermission) {
+				return nil, fmt.Errorf("%w: %v", ErrPingPermission, err)
+			}
+			return nil, err
+		}
+		if readEchoReply(conn, protocol, replyType, matchID, seq, sent.Add(timeout)) {
+			rtt := time.Since(sent)
+			if received == 0 {
+				first, min = rtt, rtt
+			} else if rtt < min {
+				min = rtt
+			}
+			received++
+			total += rtt
+		}
+	}
+	if received == 0 {
+		return nil, errors.New("destination host unreachable")
+	}
+	return &PingResult{
+		Latency:    (total / time.Duration(received)).Microseconds(),
+		First:      first.Microseconds(),
+		Min:        min.Microseconds(),
+		PacketLoss: float64(count-received) / float64(count) * 100,
+	}, nil
+}
+
+// readEchoReply will wait until the deadline for the echo reply of seq, returns false if none was received.
+// The ID of the reply is not compared if id is less than 0
+func readEchoReply(conn *icmp.PacketConn, protocol int, replyType icmp.Type, id, seq int, deadline time.Time) bool {
+	buf := make([]byte, 1500)
+	conn.SetReadDeadline(deadline)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return false
+		}
+		reply, err := icmp.ParseMessage(protocol, buf[:n])
+		if err != nil || reply.Type != replyType {
+			continue
+		}
+		if echo, ok := reply.Body.(*icmp.Echo); ok && echo.Seq == seq && (id < 0 || echo.ID == id) {
+			return true
+		}
+	}
+}
@@ -1,18 +1,29 @@
 package utils
 
 import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"github.com/statping/statping/types/metrics"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/proxy"
+	"hash"
 	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"os"
 	"os/exec"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -154,6 +165,331 @@ func DurationReadable(d time.Duration) string {
 	return d.String()
 }
 
+// PingResult is the summary of sending multiple ICMP packets with PingCount
+type PingResult struct {
+	// Latency is the average round trip time in microseconds
+	Latency int64
+	// First is the round trip time of the first reply in microseconds
+	First int64
+	// Min is the shortest round trip time in microseconds
+	Min int64
+	// PacketLoss is the percent of packets that did not receive a reply
+	PacketLoss float64
+}
+
+// HttpOptions are additional settings for HttpRequestWithOptions
+type HttpOptions struct {
+	// ConnectTimeout is the timeout for dialing the connection, defaults to the request timeout
+	ConnectTimeout time.Duration
+	// FollowRedirects will follow HTTP redirects and return the final response
+	FollowRedirects bool
+	// Context will cancel the request when it is done, such as when a service is stopped
+	Context context.Context
+	// Proxy is a http://, https:// or socks5:// URL to send the request through
+	Proxy string
+	// Timing will be filled with the duration of each phase of the request when it is set
+	Timing *HttpTiming
+	// MaxBodySize is the max amount of bytes read from the response body, there is no limit if 0
+	MaxBodySize int64
+	// Client sends the request instead of the built-in client when it is set, such as to mock responses or
+	// use another transport. The options of the built-in transport, such as the Proxy, are not used with it,
+	// and its connections can be reused so the latency may not include the connect and TLS handshake
+	Client *http.Client
+	// BodySize will be set to the length of the decoded response body when it is set, the bytes over the
+	// MaxBodySize are read and discarded so they are included in the length
+	BodySize *int64
+	// Truncated will be set to true when it is set and the response body was cut at the MaxBodySize, the
+	// returned body is never marked with TruncatedBody so it can be compared to the expected response
+	Truncated *bool
+	// Digest will be written the whole decoded response body while it is read when it is set, including the
+	// bytes over the MaxBodySize, so the checksum of a large body can be compared
+	Digest hash.Hash
+	// ForceH2C will send http:// requests as cleartext HTTP/2 with prior knowledge (h2c)
+	ForceH2C bool
+	// UserAgent is sent as the User-Agent header unless one is set in the headers, defaults to "Statping"
+	UserAgent string
+	// HostHeader is sent as the Host header instead of the host of the endpoint or a Host in the headers
+	HostHeader string
+	// ServerName is sent as the TLS server name (SNI) and verified against the certificate, it defaults to
+	// the Host header, which is the host of the endpoint unless it is overridden
+	ServerName string
+	// SkipHostnameVerify will verify the certificate chain against the trusted roots without requiring the
+	// certificate to match the hostname, such as when checking by IP. It has no effect if verifySSL is false
+	SkipHostnameVerify bool
+	// ReadLimit will stop reading the response body after this amount of bytes, or once ReadUntil matches,
+	// so streaming responses that never end are not read until the timeout. The body is read until EOF if 0
+	ReadLimit int64
+	// ReadUntil will stop reading the response body once it matches, it is only used with a ReadLimit
+	ReadUntil *regexp.Regexp
+	// Jar will store the cookies of the responses and send them with the requests, such as to keep a session
+	Jar http.CookieJar
+	// LocalAddr is the local address the connections are sent from, the system chooses the address if nil
+	LocalAddr net.Addr
+	// MaxRequestSize is the max amount of bytes sent as the request body, the request is not sent if the
+	// body is larger. There is no limit if 0
+	MaxRequestSize int64
+	// DialIP is the IP address to connect to instead of resolving the host of the URL, the Host header and
+	// TLS server name are still the host of the URL
+	DialIP string
+}
+
+// verifyCertificateChain returns a VerifyPeerCertificate function that verifies the certificate chain against
+// the roots, or the system roots if nil, without verifying the hostname of the leaf certificate
+func verifyCertificateChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("tls: server did not send a certificate")
+		}
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs[i] = cert
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+		})
+		return err
+	}
+}
+
+// TruncatedBody is appended to a response body that was cut at a max size when it is stored or displayed
+const TruncatedBody = "...[truncated]"
+
+// decodeBody will decompress a gzip or deflate response body that was not already decoded by the transport
+func decodeBody(resp *http.Response) (io.Reader, error) {
+	if resp.Uncompressed || emptyBody(resp) {
+		return resp.Body, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		body, err := gzip.NewReader(resp.Body)
+		if err == io.EOF {
+			// the encoded body is empty, such as a response without a Content-Length
+			return resp.Body, nil
+		}
+		return body, err
+	case "deflate":
+		// deflate should be zlib wrapped, but some servers send the raw deflate stream
+		body := bufio.NewReader(resp.Body)
+		header, err := body.Peek(2)
+		if err == io.EOF {
+			return body, nil
+		}
+		if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			return zlib.NewReader(body)
+		}
+		return flate.NewReader(body), nil
+	}
+	return resp.Body, nil
+}
+
+// emptyBody returns true if the response has no body, such as the response of a HEAD request or a 204 and
+// 304 response, which can still have the Content-Encoding of the resource
+func emptyBody(resp *http.Response) bool {
+	if resp.ContentLength == 0 || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return true
+	}
+	return resp.Request != nil && resp.Request.Method == http.MethodHead
+}
+
+// readBody reads the decoded response body, cutting it at maxSize bytes and returning true if it was larger.
+// When size or digest is set, the rest of a truncated body is read and discarded to count the length of the
+// whole body and write it to the digest
+func readBody(resp *http.Response, maxSize int64, size *int64, digest hash.Hash) ([]byte, bool, error) {
+	body, err := decodeBody(resp)
+	if err != nil {
+		return nil, false, err
+	}
+	if digest != nil {
+		body = io.TeeReader(body, digest)
+	}
+	if maxSize <= 0 {
+		contents, err := ioutil.ReadAll(body)
+		if size != nil {
+			*size = int64(len(contents))
+		}
+		return contents, false, err
+	}
+	contents, err := ioutil.ReadAll(io.LimitReader(body, maxSize+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if size != nil {
+		*size = int64(len(contents))
+	}
+	if int64(len(contents)) <= maxSize {
+		return contents, false, nil
+	}
+	if size != nil || digest != nil {
+		discarded, err := io.Copy(ioutil.Discard, body)
+		if err != nil && digest != nil {
+			return nil, false, err
+		}
+		if size != nil {
+			*size += discarded
+		}
+	}
+	return contents[:maxSize], true, nil
+}
+
+// readStream reads the decoded response body until limit bytes were read or until the body matches,
+// the connection is closed without reading the rest of the stream
+func readStream(resp *http.Response, limit int64, until *regexp.Regexp, digest hash.Hash) ([]byte, error) {
+	body, err := decodeBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	if digest != nil {
+		body = io.TeeReader(body, digest)
+	}
+	var contents []byte
+	buf := make([]byte, 4096)
+	for {
+		n, err := body.Read(buf)
+		contents = append(contents, buf[:n]...)
+		if int64(len(contents)) >= limit {
+			return contents[:limit], nil
+		}
+		if until != nil && n > 0 && until.Match(contents) {
+			return contents, nil
+		}
+		if err == io.EOF {
+			return contents, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// HttpTiming is the breakdown of where the time was spent during a HTTP request
+type HttpTiming struct {
+	DNS       time.Duration
+	Connect   time.Duration
+	TLS       time.Duration
+	FirstByte time.Duration
+	// RemoteAddr is the address of the connection used for the request, this is the proxy when one is used
+	RemoteAddr string
+}
+
+// trace returns a httptrace.ClientTrace that will record the durations into HttpTiming,
+// the hooks can be called from the transport's dialing goroutines so they are guarded by a mutex
+func (t *HttpTiming) trace() *httptrace.ClientTrace {
+	var mu sync.Mutex
+	var start, dnsStart, connStart, tlsStart time.Time
+	record := func(fn func()) {
+		mu.Lock()
+		defer mu.Unlock()
+		fn()
+	}
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			record(func() { start = time.Now() })
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			record(func() { t.RemoteAddr = info.Conn.RemoteAddr().String() })
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			record(func() { dnsStart = time.Now() })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			record(func() { t.DNS = time.Since(dnsStart) })
+		},
+		ConnectStart: func(string, string) {
+			record(func() { connStart = time.Now() })
+		},
+		ConnectDone: func(string, string, error) {
+			record(func() { t.Connect = time.Since(connStart) })
+		},
+		TLSHandshakeStart: func() {
+			record(func() { tlsStart = time.Now() })
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			record(func() { t.TLS = time.Since(tlsStart) })
+		},
+		GotFirstResponseByte: func() {
+			record(func() { t.FirstByte = time.Since(start) })
+		},
+	}
+}
+
+// ProxyError is returned when the connection to a proxy has failed, rather than to the endpoint
+type ProxyError struct {
+	Proxy string
+	Err   error
+}
+
+func (e *ProxyError) Error() string {
+	return fmt.Sprintf("proxy %v: %v", e.Proxy, e.Err)
+}
+
+func (e *ProxyError) Unwrap() error {
+	return e.Err
+}
+
+// IsProxyError returns true if the error was caused by connecting to or through a proxy
+func IsProxyError(err error) bool {
+	var proxyErr *ProxyError
+	if errors.As(err, &proxyErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "proxyconnect"
+}
+
+// proxyDialer will tag any errors while connecting to the proxy as a ProxyError
+type proxyDialer struct {
+	proxy  string
+	dialer *net.Dialer
+}
+
+func (d proxyDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d proxyDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := d.dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, &ProxyError{Proxy: d.proxy, Err: err}
+	}
+	return conn, nil
+}
+
+// setProxy will configure the transport to send requests through the proxy URL
+func setProxy(transport *http.Transport, dialer *net.Dialer, proxyAddr string) error {
+	proxyUrl, err := url.Parse(proxyAddr)
+	if err != nil {
+		return err
+	}
+	forward := proxyDialer{proxy: proxyUrl.Host, dialer: dialer}
+	switch proxyUrl.Scheme {
+	case "socks5", "socks5h":
+		socks, err := proxy.FromURL(proxyUrl, forward)
+		if err != nil {
+			return err
+		}
+		contextDialer, ok := socks.(proxy.ContextDialer)
+		if !ok {
+			return fmt.Errorf("proxy %v does not support contexts", proxyUrl.Host)
+		}
+		transport.Proxy = nil
+		transport.DialContext = contextDialer.DialContext
+	case "http", "https":
+		transport.Proxy = http.ProxyURL(proxyUrl)
+		transport.DialContext = forward.DialContext
+	default:
+		return fmt.Errorf("unsupported proxy scheme '%v'", proxyUrl.Scheme)
+	}
+	return nil
+}
+
 // HttpRequest is a global function to send a HTTP request
 // // url - The URL for HTTP request
 // // method - GET, POST, DELETE, PATCH
@@ -163,17 +499,42 @@ func DurationReadable(d time.Duration) string {
 // // timeout - Specific duration to timeout on. time.Duration(30 * time.Seconds)
 // // You can use a HTTP Proxy if you HTTP_PROXY environment variable
 func HttpRequest(endpoint, method string, contentType interface{}, headers []string, body io.Reader, timeout time.Duration, verifySSL bool, customTLS *tls.Config) ([]byte, *http.Response, error) {
+	return HttpRequestWithOptions(endpoint, method, contentType, headers, body, timeout, verifySSL, customTLS, HttpOptions{})
+}
+
+// HttpRequestWithOptions is the same as HttpRequest but accepts additional HttpOptions for the request
+func HttpRequestWithOptions(endpoint, method string, contentType interface{}, headers []string, body io.Reader, timeout time.Duration, verifySSL bool, customTLS *tls.Config, opts HttpOptions) ([]byte, *http.Response, error) {
 	var err error
 	var req *http.Request
 	if method == "" {
 		method = "GET"
 	}
 	t1 := Now()
+	if body != nil && opts.MaxRequestSize > 0 {
+		data, err := ioutil.ReadAll(io.LimitReader(body, opts.MaxRequestSize+1))
+		if err != nil {
+			return nil, nil, err
+		}
+		if int64(len(data)) > opts.MaxRequestSize {
+			return nil, nil, fmt.Errorf("request body is larger than the max of %d bytes", opts.MaxRequestSize)
+		}
+		body = bytes.NewReader(data)
+	}
 	if req, err = http.NewRequest(method, endpoint, body); err != nil {
 		return nil, nil, err
 	}
+	if opts.Context != nil {
+		req = req.WithContext(opts.Context)
+	}
+	if opts.Timing != nil {
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), opts.Timing.trace()))
+	}
 	// set default headers so end user can overwrite them if needed
-	req.Header.Set("User-Agent", "Statping")
+	userAgent := opts.UserAgent
+	if userAgent == "" {
+		userAgent = "Statping"
+	}
+	req.Header.Set("User-Agent", userAgent)
 	req.Header.Set("Statping-Version", Params.GetString("VERSION"))
 	if contentType != nil {
 		req.Header.Set("Content-Type", contentType.(string))
@@ -193,13 +554,29 @@ func HttpRequest(endpoint, method string, contentType interface{}, headers []str
 			}
 		}
 	}
+	if opts.HostHeader != "" {
+		req.Host = opts.HostHeader
+		verifyHost = req.Host
+	}
+	if opts.ServerName != "" {
+		verifyHost = opts.ServerName
+	}
+
+	connectTimeout := opts.ConnectTimeout
+	if connectTimeout <= 0 {
+		connectTimeout = timeout
+	}
 
 	var resp *http.Response
 	dialer := &net.Dialer{
-		Timeout:   timeout,
+		Timeout:   connectTimeout,
 		KeepAlive: timeout,
+		LocalAddr: opts.LocalAddr,
 	}
 
+	// every request has its own transport without keep-alives, so connections of the built-in transport are
+	// never reused between checks and the latency includes the DNS lookup, TCP connect and TLS handshake.
+	// This does not hold for an opts.Client, which replaces the transport and can reuse its connections
 	transport := &http.Transport{
 		TLSClientConfig: &tls.Config{
 			InsecureSkipVerify: !verifySSL,
@@ -213,6 +590,9 @@ func HttpRequest(endpoint, method string, contentType interface{}, headers []str
 		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
 			// redirect all connections to host specified in url
 			addr = strings.Split(req.URL.Host, ":")[0] + addr[strings.LastIndex(addr, ":"):]
+			if opts.DialIP != "" {
+				addr = net.JoinHostPort(opts.DialIP, addr[strings.LastIndex(addr, ":")+1:])
+			}
 			return dialer.DialContext(ctx, network, addr)
 		},
 	}
@@ -223,27 +603,76 @@ func HttpRequest(endpoint, method string, contentType interface{}, headers []str
 		}
 		transport.Proxy = http.ProxyURL(proxyUrl)
 	}
+	if opts.Proxy != "" {
+		if err := setProxy(transport, dialer, opts.Proxy); err != nil {
+			return nil, nil, err
+		}
+	}
 	if customTLS != nil {
 		transport.TLSClientConfig.RootCAs = customTLS.RootCAs
 		transport.TLSClientConfig.Certificates = customTLS.Certificates
 	}
+	if verifySSL && opts.SkipHostnameVerify {
+		// the default verification is replaced with verifying the chain without the hostname
+		transport.TLSClientConfig.InsecureSkipVerify = true
+		transport.TLSClientConfig.VerifyPeerCertificate = verifyCertificateChain(transport.TLSClientConfig.RootCAs)
+	}
+	var roundTripper http.RoundTripper = transport
+	if opts.ForceH2C && req.URL.Scheme == "http" {
+		roundTripper = &http2.Transport{
+			AllowHTTP: true,
+			// prior knowledge HTTP/2 is sent over a plaintext connection instead of TLS
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return transport.DialContext(req.Context(), network, addr)
+			},
+		}
+	}
 	client := &http.Client{
-		Transport: transport,
+		Transport: roundTripper,
 		Timeout:   timeout,
+		Jar:       opts.Jar,
+	}
+	if opts.Client != nil {
+		// the client is copied so the redirect policy and cookie jar of the request don't change it
+		custom := *opts.Client
+		if custom.Timeout == 0 {
+			custom.Timeout = timeout
+		}
+		if opts.Jar != nil {
+			custom.Jar = opts.Jar
+		}
+		client = &custom
+	}
+
+	// the 'Redirect=true' header is still accepted for backwards compatibility
+	if req.Header.Get("Redirect") == "true" {
+		opts.FollowRedirects = true
 	}
+	req.Header.Del("Redirect")
 
-	if req.Header.Get("Redirect") != "true" {
+	if !opts.FollowRedirects {
 		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
 		}
-		req.Header.Del("Redirect")
 	}
 
 	if resp, err = client.Do(req); err != nil {
 		return nil, resp, err
 	}
 	defer resp.Body.Close()
-	contents, err := ioutil.ReadAll(resp.Body)
+	var contents []byte
+	if opts.ReadLimit > 0 {
+		contents, err = readStream(resp, opts.ReadLimit, opts.ReadUntil, opts.Digest)
+		if err == nil && opts.BodySize != nil {
+			*opts.BodySize = int64(len(contents))
+		}
+	} else {
+		var truncated bool
+		contents, truncated, err = readBody(resp, opts.MaxBodySize, opts.BodySize, opts.Digest)
+		if opts.Truncated != nil {
+			*opts.Truncated = truncated
+		}
+	}
 	if err != nil {
 		return nil, resp, err
 	}
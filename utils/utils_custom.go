@@ -4,6 +4,7 @@ package utils
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 	"regexp"
@@ -38,26 +39,61 @@ func DirWritable(path string) (bool, error) {
 }
 
 func Ping(address string, secondsTimeout int) (int64, error) {
-	ping, err := exec.LookPath("ping")
+	res, err := PingCount(address, 1, secondsTimeout)
 	if err != nil {
 		return 0, err
 	}
-	out, _, err := Command(ping, address, "-c", "1", "-W", strconv.Itoa(secondsTimeout))
+	return res.Latency, nil
+}
+
+// PingCount will send count ICMP packets to the address and return the average, first and minimum latency and the packet loss
+func PingCount(address string, count, secondsTimeout int) (*PingResult, error) {
+	ping, err := exec.LookPath("ping")
 	if err != nil {
-		return 0, err
+		return nil, fmt.Errorf("%w: %v", ErrPingUnavailable, err)
+	}
+	if count < 1 {
+		count = 1
+	}
+	out, errOut, err := Command(ping, address, "-c", strconv.Itoa(count), "-W", strconv.Itoa(secondsTimeout))
+	if err != nil {
+		if strings.Contains(errOut, "Operation not permitted") || strings.Contains(errOut, "Permission denied") {
+			return nil, fmt.Errorf("%w: %v", ErrPingUnavailable, strings.TrimSpace(errOut))
+		}
+		return nil, err
 	}
 	if strings.Contains(out, "Unknown host") {
-		return 0, errors.New("unknown host")
+		return nil, errors.New("unknown host")
+	}
+
+	res := &PingResult{}
+	if loss := regexp.MustCompile(`([\d.]+)% packet loss`).FindStringSubmatch(out); len(loss) == 2 {
+		res.PacketLoss, _ = strconv.ParseFloat(loss[1], 64)
 	}
-	if strings.Contains(out, "100.0% packet loss") {
-		return 0, errors.New("destination host unreachable")
+	if res.PacketLoss >= 100 {
+		return nil, errors.New("destination host unreachable")
 	}
 
-	r := regexp.MustCompile(`time=(.*) ms`)
-	strs := r.FindStringSubmatch(out)
-	if len(strs) < 2 {
-		return 0, errors.New("could not parse ping duration")
+	// use the 'min/avg/max' summary, or the time of the first reply
+	first := regexp.MustCompile(`time=([\d.]+) ms`).FindStringSubmatch(out)
+	strs := regexp.MustCompile(`= ([\d.]+)/([\d.]+)/`).FindStringSubmatch(out)
+	if len(strs) < 3 && len(first) == 2 {
+		strs = []string{first[0], first[1], first[1]}
+	}
+	if len(strs) < 3 {
+		return nil, errors.New("could not parse ping duration")
 	}
-	f, _ := strconv.ParseFloat(strs[1], 64)
-	return int64(f * 1000), nil
+	res.Latency = parsePingMs(strs[2])
+	res.Min = parsePingMs(strs[1])
+	res.First = res.Latency
+	if len(first) == 2 {
+		res.First = parsePingMs(first[1])
+	}
+	return res, nil
+}
+
+// parsePingMs returns the milliseconds of the ping output in microseconds
+func parsePingMs(value string) int64 {
+	f, _ := strconv.ParseFloat(value, 64)
+	return int64(f * 1000)
 }
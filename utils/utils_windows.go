@@ -2,6 +2,7 @@ package utils
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 	"regexp"
@@ -27,22 +28,57 @@ func DirWritable(path string) (bool, error) {
 }
 
 func Ping(address string, secondsTimeout int) (int64, error) {
-	ping, err := exec.LookPath("ping")
+	res, err := PingCount(address, 1, secondsTimeout)
 	if err != nil {
 		return 0, err
 	}
-	out, _, err := Command(ping, address, "-n", "1", "-w", strconv.Itoa(secondsTimeout*1000))
+	return res.Latency, nil
+}
+
+// PingCount will send count ICMP packets to the address and return the average, first and minimum latency and the packet loss
+func PingCount(address string, count, secondsTimeout int) (*PingResult, error) {
+	ping, err := exec.LookPath("ping")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPingUnavailable, err)
+	}
+	if count < 1 {
+		count = 1
+	}
+	out, _, err := Command(ping, address, "-n", strconv.Itoa(count), "-w", strconv.Itoa(secondsTimeout*1000))
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	if strings.Contains(out, "Destination Host Unreachable") {
-		return 0, errors.New("destination host unreachable")
+		return nil, errors.New("destination host unreachable")
 	}
+
+	res := &PingResult{}
+	if loss := regexp.MustCompile(`\((\d+)% loss\)`).FindStringSubmatch(out); len(loss) == 2 {
+		res.PacketLoss, _ = strconv.ParseFloat(loss[1], 64)
+	}
+	if res.PacketLoss >= 100 {
+		return nil, errors.New("destination host unreachable")
+	}
+
 	r := regexp.MustCompile(`Average = (.*)ms`)
 	strs := r.FindStringSubmatch(out)
 	if len(strs) < 2 {
-		return 0, errors.New("could not parse ping duration")
+		return nil, errors.New("could not parse ping duration")
 	}
-	f, _ := strconv.ParseFloat(strs[1], 64)
-	return int64(f * 1000), nil
+	res.Latency = parsePingMs(strs[1])
+	res.Min = res.Latency
+	if min := regexp.MustCompile(`Minimum = (\d+)ms`).FindStringSubmatch(out); len(min) == 2 {
+		res.Min = parsePingMs(min[1])
+	}
+	res.First = res.Latency
+	if first := regexp.MustCompile(`time[=<](\d+)ms`).FindStringSubmatch(out); len(first) == 2 {
+		res.First = parsePingMs(first[1])
+	}
+	return res, nil
+}
+
+// parsePingMs returns the milliseconds of the ping output in microseconds
+func parsePingMs(value string) int64 {
+	f, _ := strconv.ParseFloat(value, 64)
+	return int64(f * 1000)
 }